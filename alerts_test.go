@@ -0,0 +1,278 @@
+package main
+
+// Notifier tests run against local stand-in servers:
+//
+//	go test main.go alerts_test.go
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAlert is a firing alert for one endpoint test
+func testAlert() Alert {
+	return Alert{
+		Rule:      "region-down",
+		Kind:      AlertRuleDown,
+		Service:   "Tokyo, JP [AWS] - HTTP",
+		Endpoint:  CloudEndpoint{Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS", Hostname: "s3.ap-northeast-1.amazonaws.com"},
+		TestType:  "HTTP",
+		Status:    "FIRING",
+		Message:   "Tokyo, JP [AWS] - HTTP has been down for 3 checks",
+		Value:     3,
+		Timestamp: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// capturedRequest is what a stand-in HTTP server received
+type capturedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// standIn starts an HTTP server that records one request and answers with status
+func standIn(t *testing.T, status int) (*httptest.Server, <-chan capturedRequest) {
+	t.Helper()
+	requests := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// notify builds a notifier from config and sends the test alert
+func notify(t *testing.T, config NotifierConfig) error {
+	t.Helper()
+	notifier, err := newNotifier(config)
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+	return notifier.Notify(testAlert())
+}
+
+func TestWebhookNotifierPostsAlertJSON(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	config := NotifierConfig{Name: "hook", Type: "webhook", URL: server.URL, Headers: map[string]string{"X-Token": "secret"}}
+	if err := notify(t, config); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	request := <-requests
+	if got := request.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := request.header.Get("X-Token"); got != "secret" {
+		t.Errorf("X-Token = %q, want the configured header", got)
+	}
+	var alert Alert
+	if err := json.Unmarshal(request.body, &alert); err != nil {
+		t.Fatalf("payload is not an alert: %v", err)
+	}
+	if alert.Rule != "region-down" || alert.Service != "Tokyo, JP [AWS] - HTTP" || alert.Status != "FIRING" {
+		t.Errorf("payload = %+v", alert)
+	}
+}
+
+func TestWebhookNotifierReportsHTTPErrors(t *testing.T) {
+	server, _ := standIn(t, http.StatusInternalServerError)
+	err := notify(t, NotifierConfig{Name: "hook", Type: "webhook", URL: server.URL})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Notify error = %v, want the 500 status", err)
+	}
+}
+
+func TestSlackNotifierSendsText(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	if err := notify(t, NotifierConfig{Name: "chat", Type: "slack", URL: server.URL}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal((<-requests).body, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	want := "[FIRING] region-down: Tokyo, JP [AWS] - HTTP\nTokyo, JP [AWS] - HTTP has been down for 3 checks"
+	if payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+}
+
+func TestTeamsNotifierSendsMessageCard(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	if err := notify(t, NotifierConfig{Name: "teams", Type: "teams", URL: server.URL}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal((<-requests).body, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload["@type"] != "MessageCard" || payload["themeColor"] != "D32F2F" || payload["title"] != alertSubject(testAlert()) {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+// smtpStandIn accepts one mail transaction and returns the envelope and message
+func smtpStandIn(t *testing.T) (addr string, mail <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		var lines []string
+		reply("220 stand-in ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 stand-in")
+			case strings.HasPrefix(command, "MAIL FROM:"), strings.HasPrefix(command, "RCPT TO:"):
+				lines = append(lines, line)
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 end with .")
+				for {
+					data, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lines = append(lines, data)
+				}
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestEmailNotifierSendsMail(t *testing.T) {
+	addr, mail := smtpStandIn(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNumber, _ := net.LookupPort("tcp", port)
+
+	config := NotifierConfig{Name: "oncall", Type: "email", SMTPHost: host, SMTPPort: portNumber,
+		From: "monitor@example.com", To: []string{"oncall@example.com", "lead@example.com"}}
+	if err := notify(t, config); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-mail:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the SMTP stand-in received no mail")
+	}
+	text := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<monitor@example.com>",
+		"RCPT TO:<oncall@example.com>",
+		"RCPT TO:<lead@example.com>",
+		"Subject: [FIRING] region-down: Tokyo, JP [AWS] - HTTP",
+		"To: oncall@example.com, lead@example.com",
+		testAlert().Message,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("mail is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestExecNotifierPassesAlert(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert")
+	config := NotifierConfig{Name: "script", Type: "exec", Command: "sh",
+		Args: []string{"-c", `{ echo "$ALERT_STATUS $ALERT_RULE"; cat; } > "$0"`, out}}
+	if err := notify(t, config); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body, _ := strings.Cut(string(data), "\n")
+	if status != "FIRING region-down" {
+		t.Errorf("environment = %q", status)
+	}
+	var alert Alert
+	if err := json.Unmarshal([]byte(body), &alert); err != nil || alert.Service != testAlert().Service {
+		t.Errorf("stdin = %q (%v)", body, err)
+	}
+}
+
+func TestExecNotifierReportsFailures(t *testing.T) {
+	err := notify(t, NotifierConfig{Name: "script", Type: "exec", Command: "sh", Args: []string{"-c", "echo broken >&2; exit 3"}})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Notify error = %v, want the command's output", err)
+	}
+}
+
+func TestNewAlertEngineSkipsInvalidRulesAndNotifiers(t *testing.T) {
+	slos := []SLOConfig{{Name: "s3-availability", Objective: "availability", Target: 99.9}}
+	config := AlertConfig{
+		Rules: []AlertRule{
+			{Name: "region-down", Kind: AlertRuleDown, Consecutive: 3},
+			{Name: "fast-burn", Kind: AlertRuleBurn, SLO: "s3-availabilty", BurnWindows: []Duration{Duration(time.Hour)}, Threshold: 14.4},
+			{Name: "slow-burn", Kind: AlertRuleBurn, SLO: "s3-availability", BurnWindows: []Duration{Duration(6 * time.Hour)}, Threshold: 6},
+			{Name: "typo", Kind: "dwon"},
+		},
+		Notifiers: []NotifierConfig{
+			{Name: "ops", Type: "webhook", URL: "http://127.0.0.1:9/hook"},
+			{Name: "chat", Type: "slack"},
+		},
+	}
+
+	engine, problems := NewAlertEngine(config, slos)
+	if len(problems) != 3 {
+		t.Errorf("problems = %v, want the misspelled SLO, the unknown kind and the notifier without URL", problems)
+	}
+	var rules []string
+	for _, rule := range engine.rules {
+		rules = append(rules, rule.Name)
+	}
+	if strings.Join(rules, ",") != "region-down,slow-burn" {
+		t.Errorf("rules = %v, want region-down and slow-burn", rules)
+	}
+	if len(engine.notifiers) != 1 || engine.notifiers[0].Name() != "ops" {
+		t.Errorf("notifiers = %v, want ops", engine.notifiers)
+	}
+}
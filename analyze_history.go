@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,101 @@ import (
 type DataPoint struct {
 	Timestamp    time.Time
 	ResponseTime int64 // nanoseconds
+	Failed       bool  // failed checks carry no latency
+}
+
+// successfulPoints returns the points of checks that succeeded
+func successfulPoints(points []DataPoint) []DataPoint {
+	var kept []DataPoint
+	for _, point := range points {
+		if !point.Failed {
+			kept = append(kept, point)
+		}
+	}
+	return kept
+}
+
+// SLOStatus mirrors an entry of slo_status.json written by the monitor
+type SLOStatus struct {
+	Name                 string
+	Objective            string
+	Target               float64
+	ThresholdMs          float64
+	Window               string
+	Good                 int
+	Total                int
+	SLI                  float64
+	ErrorBudgetRemaining float64
+	BurnRates            []struct {
+		Window string
+		Rate   float64
+	}
+	Compliant bool
+	UpdatedAt time.Time
+}
+
+// formatWindow renders a Go duration string such as "720h0m0s" as "30d"
+func formatWindow(window string) string {
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return window
+	}
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
+
+// printSLOReport prints the SLO compliance table from slo_status.json, if present
+func printSLOReport() {
+	data, err := os.ReadFile("slo_status.json")
+	if err != nil {
+		return
+	}
+
+	var statuses []SLOStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		fmt.Printf("Error parsing slo_status.json: %v\n", err)
+		return
+	}
+	if len(statuses) == 0 {
+		return
+	}
+
+	fmt.Println("\n╔════════════════════════════════════════════════════════════════════════════════════════╗")
+	fmt.Println("║                         SERVICE LEVEL OBJECTIVES                                       ║")
+	fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════════╝")
+	fmt.Printf("\nEvaluated: %s\n\n", statuses[0].UpdatedAt.Format("2006-01-02 15:04:05"))
+
+	fmt.Printf("%-30s %-13s %8s %6s %10s %9s %12s  %s\n",
+		"SLO", "OBJECTIVE", "TARGET", "WINDOW", "SLI", "CHECKS", "BUDGET LEFT", "BURN RATE")
+	fmt.Println("────────────────────────────────────────────────────────────────────────────────────────────────────────")
+
+	for _, s := range statuses {
+		objective := s.Objective
+		if s.ThresholdMs > 0 {
+			objective = fmt.Sprintf("< %.0fms", s.ThresholdMs)
+		}
+
+		status := "✓"
+		if !s.Compliant {
+			status = "✗"
+		}
+
+		var burns []string
+		for _, burn := range s.BurnRates {
+			burns = append(burns, fmt.Sprintf("%s %.2fx", formatWindow(burn.Window), burn.Rate))
+		}
+
+		fmt.Printf("%-30s %-13s %7.3g%% %6s %9.3f%% %9d %11.1f%%  %s %s\n",
+			s.Name, objective, s.Target, formatWindow(s.Window), s.SLI, s.Total,
+			s.ErrorBudgetRemaining, strings.Join(burns, ", "), status)
+	}
+
+	fmt.Println("────────────────────────────────────────────────────────────────────────────────────────────────────────")
 }
 
 func main() {
@@ -28,6 +124,9 @@ func main() {
 		fmt.Printf("Error parsing JSON: %v\n", err)
 		os.Exit(1)
 	}
+	for service, points := range history {
+		history[service] = successfulPoints(points)
+	}
 
 	// Calculate statistics for each service
 	type ServiceStats struct {
@@ -169,5 +268,7 @@ func main() {
 		fmt.Printf("Collection period: %v\n", duration.Round(time.Second))
 	}
 
+	printSLOReport()
+
 	fmt.Println()
 }
@@ -1,34 +1,228 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"embed"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+// Templates, scripts and styles are compiled into the binary so the
+// dashboard works offline; nothing is loaded from a CDN
+//
+//go:embed web/templates/*.html
+var templateFS embed.FS
+
+//go:embed web/static
+var staticFS embed.FS
+
 type DataPoint struct {
 	Timestamp    time.Time
 	ResponseTime int64
+	Failed       bool // failed checks carry no latency
+}
+
+// successfulPoints returns the points of checks that succeeded
+func successfulPoints(points []DataPoint) []DataPoint {
+	var kept []DataPoint
+	for _, point := range points {
+		if !point.Failed {
+			kept = append(kept, point)
+		}
+	}
+	return kept
+}
+
+// ArchiveRecord is one test result from the monitor's latency_archive directory
+type ArchiveRecord struct {
+	Timestamp       time.Time
+	Service         string
+	Location        string
+	Region          string
+	Provider        string
+	Hostname        string
+	Labels          map[string]string
+	Coordinates     *Coordinates
+	TestType        string
+	Online          bool
+	Degraded        bool
+	ResponseTime    int64
+	ResolvedIP      string
+	Error           string
+	ErrorCategory   string
+	Trend           string
+	Loss            float64
+	Maintenance     string
+	Cycle           string
+	StatusCode      int
+	Diagnosis       string
+	DiagnosisReason string
+	Fields          map[string]float64
+	Details         map[string]string
+}
+
+// Coordinates is a position in decimal degrees
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Valid reports whether the coordinates are on the globe
+func (c Coordinates) Valid() bool {
+	return c.Latitude >= -90 && c.Latitude <= 90 && c.Longitude >= -180 && c.Longitude <= 180
+}
+
+// VantagePoint mirrors the Vantage section of monitor_config.json: where the
+// monitor runs
+type VantagePoint struct {
+	Name string
+	Coordinates
+}
+
+// DiagnosisSummary is the latest root-cause verdict for one endpoint
+type DiagnosisSummary struct {
+	Location  string
+	Provider  string
+	Verdict   string
+	Reason    string
+	Timestamp string
+}
+
+// SLOStatus mirrors an entry of slo_status.json written by the monitor
+type SLOStatus struct {
+	Name                 string
+	Objective            string
+	Target               float64
+	ThresholdMs          float64
+	Window               string
+	Good                 int
+	Total                int
+	SLI                  float64
+	ErrorBudgetRemaining float64
+	BurnRates            []struct {
+		Window string
+		Rate   float64
+	}
+	Compliant bool
+	UpdatedAt time.Time
+	Match     EndpointMatcher
+}
+
+// EndpointMatcher mirrors the monitor's matcher: empty fields match
+// everything and values may use shell-style wildcards
+type EndpointMatcher struct {
+	Location string
+	Provider string
+	Region   string
+	Hostname string
+	TestType string
+	Labels   map[string]string
+}
+
+// matchField compares a single matcher value against a record field
+func matchField(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && matched
+}
+
+// Matches reports whether the matcher selects the test that produced record
+func (m EndpointMatcher) Matches(record ArchiveRecord) bool {
+	for key, pattern := range m.Labels {
+		if !matchField(pattern, record.Labels[key]) {
+			return false
+		}
+	}
+
+	return matchField(m.Location, record.Location) &&
+		matchField(m.Provider, record.Provider) &&
+		matchField(m.Region, record.Region) &&
+		matchField(m.Hostname, record.Hostname) &&
+		matchField(m.TestType, record.TestType)
 }
 
 type DashboardData struct {
 	LastUpdate     string
 	TotalEndpoints int
 	Summary        []EndpointSummary
-	TimeSeriesJSON template.JS
+	Diagnoses      []DiagnosisSummary
+	Locations      []string // "Location [Provider]" for the layer comparison chart
+	Live           bool     // the page streams updates from /api/events
+	FastestPingMs  int64
+	SlowestPingMs  int64
+	AvgPingMs      int64
+	Charts         DashboardCharts
+	ChartsJSON     template.JS
+
+	// Time-series selection for the no-JavaScript fallback
+	Ranges         []string
+	SeriesService  string
+	SeriesRange    string
+	LayersLocation string
+	Map            WorldMap
+
+	// The endpoints table: the rows of Summary chosen by the page URL
+	Options   FilterOptions
+	Filter    EndpointFilter
+	Endpoints []EndpointSummary
+	Columns   []TableColumn `json:"-"`
+}
+
+// ChartBar is one bar or slice of a summary chart
+type ChartBar struct {
+	Label string
+	Value float64
+}
+
+// DashboardCharts holds the summary charts, drawn by charts.js or, without
+// JavaScript, as server-side SVG
+type DashboardCharts struct {
+	Region []ChartBar // average latency of the first 15 AWS locations
+	Layers []ChartBar // average latency per test layer
 }
 
 type EndpointSummary struct {
+	ID           string // endpoint page, shared by every layer of a location
 	Name         string
 	Location     string
 	Provider     string
+	Region       string
+	Continent    string
+	Hostname     string
+	Labels       map[string]string
 	TestType     string
+	Health       string // UP, DEGRADED, DOWN, MAINTENANCE or UNKNOWN, from the latest archived result
 	LatestMs     int64
 	AvgMs        int64
 	MinMs        int64
@@ -36,56 +230,693 @@ type EndpointSummary struct {
 	Count        int
 	Status       string
 	TrendPercent float64
+	Diagnosis    string
+	LastSeen     time.Time
 }
 
+// live relays the monitor's live feed when -live is set
+var live *LiveRelay
+
+// archiveDir is the monitor's result archive, History.ArchiveDir in the
+// configuration
+var archiveDir = "latency_archive"
+
 func main() {
-	http.HandleFunc("/", dashboardHandler)
-	http.HandleFunc("/api/data", dataAPIHandler)
+	liveSource := flag.String("live", "", `monitor live feed, e.g. http://127.0.0.1:9106/events or unix:/tmp/cloud_latency.sock`)
+	configFile := flag.String("config", "monitor_config.json", "configuration file with an optional Dashboard section")
+	listen := flag.String("listen", "", `listen address (default ":8080")`)
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; serves HTTPS together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	basePath := flag.String("base-path", "", `URL path the dashboard is served under behind a reverse proxy, e.g. /latency/`)
+	trustedProxies := flag.String("trusted-proxies", "", "comma-separated proxy addresses or CIDRs whose X-Forwarded-For header is trusted")
+	statusOnly := flag.Bool("status-page", false, "serve only the public status page")
+	flag.Parse()
+
+	config, err := loadDashboardConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Could not load %s: %v", *configFile, err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			config.Listen = *listen
+		case "tls-cert":
+			config.TLSCert = *tlsCert
+		case "tls-key":
+			config.TLSKey = *tlsKey
+		case "base-path":
+			config.BasePath = *basePath
+		case "trusted-proxies":
+			config.TrustedProxies = strings.Split(*trustedProxies, ",")
+		case "status-page":
+			config.StatusPage.Public = *statusOnly
+		}
+	})
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		log.Fatal("TLS needs both a certificate and a key")
+	}
+	archiveDir = config.ArchiveDir
+	proxies, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth, err := NewAuthenticator(config.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	statusPage, err := NewStatusPage(config.StatusPage, config.BasePath, archiveDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/static/", staticHandler)
+	handler := auth.Wrap(withBasePath(config.BasePath, mux))
+	if config.StatusPage.Public {
+		// Only the notes API asks for credentials
+		mux.HandleFunc("/", statusPage.pageHandler)
+		statusPage.Register(mux, auth)
+		handler = withBasePath(config.BasePath, mux)
+	} else {
+		mux.HandleFunc("/", dashboardHandler)
+		mux.HandleFunc("/api/data", dataAPIHandler)
+		mux.HandleFunc("/api/reload", reloadHandler)
+		mux.HandleFunc("/api/series", seriesAPIHandler)
+		mux.HandleFunc("/chart/series.svg", seriesSVGHandler)
+		mux.HandleFunc("/endpoint/", endpointHandler)
+		mux.HandleFunc("/heatmap", heatmapHandler)
+		mux.HandleFunc("/api/heatmap", heatmapAPIHandler)
+		if *liveSource != "" {
+			live = NewLiveRelay(*liveSource)
+			go live.Run()
+			mux.Handle("/api/events", live)
+			fmt.Println("📡 Streaming live results from", *liveSource)
+		}
+		mux.HandleFunc("/slo", sloHandler)
+		mux.HandleFunc("/api/slo", sloAPIHandler)
+		statusPage.Register(mux, nil)
+	}
 
+	// Request contexts end on shutdown, which closes open event streams
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := &http.Server{
+		Addr:              config.Listen,
+		Handler:           withTrustedProxies(proxies, handler),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      2 * time.Minute, // lifted for /api/events
+		IdleTimeout:       2 * time.Minute,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	scheme := "http"
+	if config.TLSCert != "" {
+		scheme = "https"
+	}
+	host := config.Listen
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
 	fmt.Println("🌐 Cloud Latency Dashboard starting...")
-	fmt.Println("📊 Open your browser to: http://localhost:8080")
+	fmt.Printf("📊 Open your browser to: %s://%s%s\n", scheme, host, cleanBasePath(config.BasePath))
+	switch {
+	case config.StatusPage.Public:
+		fmt.Println("📣 Serving the public status page only")
+		if auth == nil {
+			fmt.Println("⚠️  Incident notes can't be posted until Dashboard.Auth is configured")
+		}
+	case auth != nil:
+		fmt.Printf("🔒 Authentication required (%d users, %d API tokens)\n", len(config.Auth.Users), len(config.Auth.Tokens))
+	}
 	fmt.Println("Press Ctrl+C to stop")
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	errs := make(chan error, 1)
+	go func() {
+		if config.TLSCert != "" {
+			errs <- server.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		log.Fatal(err)
+	case <-stop:
+	}
+
+	fmt.Println("\nShutting down...")
+	cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown: %v", err)
+	}
+}
+
+// DashboardConfig is the Dashboard section of monitor_config.json; the
+// command-line flags override it
+type DashboardConfig struct {
+	Listen         string
+	TLSCert        string
+	TLSKey         string
+	BasePath       string   // e.g. "/latency/" when a reverse proxy serves the dashboard on a sub-path
+	TrustedProxies []string // addresses or CIDRs of reverse proxies
+	Auth           AuthConfig
+	StatusPage     StatusPageConfig
+	ArchiveDir     string `json:"-"` // from the History section
+}
+
+// AuthConfig turns on authentication when it lists any users or tokens
+type AuthConfig struct {
+	Users  map[string]string // basic auth user name to password, or "sha256:" and the password's hex SHA-256
+	Tokens []APIToken        // bearer tokens
+}
+
+// APIToken is a bearer token for scripts and other API clients
+type APIToken struct {
+	Name     string
+	Token    string // the token, or "sha256:" and its hex SHA-256
+	ReadOnly bool   // may only GET
+}
+
+// loadDashboardConfig reads the Dashboard section and the archive directory
+// of the configuration, returning defaults when the file is absent
+func loadDashboardConfig(filename string) (DashboardConfig, error) {
+	var config struct {
+		Dashboard DashboardConfig
+		History   struct{ ArchiveDir string }
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return config.Dashboard, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config.Dashboard, err
+		}
+	}
+	if config.Dashboard.Listen == "" {
+		config.Dashboard.Listen = ":8080"
+	}
+	config.Dashboard.ArchiveDir = config.History.ArchiveDir
+	if config.Dashboard.ArchiveDir == "" {
+		config.Dashboard.ArchiveDir = "latency_archive"
+	}
+	return config.Dashboard, nil
+}
+
+// cleanBasePath normalizes a base path to "/" or "/prefix/"
+func cleanBasePath(base string) string {
+	base = strings.Trim(base, "/")
+	if base == "" {
+		return "/"
+	}
+	return "/" + base + "/"
+}
+
+// withBasePath serves the dashboard under a base path. Every page links
+// relatively, so the proxy may forward the prefix or strip it.
+func withBasePath(base string, next http.Handler) http.Handler {
+	prefix := strings.TrimSuffix(cleanBasePath(base), "/")
+	if prefix == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == prefix:
+			// Relative links need the trailing slash
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, prefix+"/"):
+			http.StripPrefix(prefix, next).ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// parseTrustedProxies parses proxy addresses and CIDRs
+func parseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusted reports whether an address belongs to a trusted proxy
+func trusted(proxies []*net.IPNet, addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// withTrustedProxies takes the client address, used in the logs, from
+// X-Forwarded-For on requests from a trusted proxy. The header is read right
+// to left, skipping the trusted proxies, so a client can't spoof its address
+// by sending the header itself. X-Forwarded-Proto and X-Forwarded-Host from
+// a trusted proxy set the scheme and host of the links the feeds carry.
+func withTrustedProxies(proxies []*net.IPNet, next http.Handler) http.Handler {
+	if len(proxies) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !trusted(proxies, r.RemoteAddr) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop := strings.TrimSpace(hops[i])
+				if net.ParseIP(hop) == nil {
+					break
+				}
+				r.RemoteAddr = net.JoinHostPort(hop, "0")
+				if !trusted(proxies, hop) {
+					break
+				}
+			}
+		}
+
+		// With a chain of proxies, the first value is from the one the client reached
+		first := func(header string) string {
+			value, _, _ := strings.Cut(r.Header.Get(header), ",")
+			return strings.TrimSpace(value)
+		}
+		if proto := strings.ToLower(first("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			r.URL.Scheme = proto
+		}
+		if host := first("X-Forwarded-Host"); host != "" && !strings.ContainsAny(host, "/\\@?# ") {
+			r.Host = host
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authenticator checks basic auth credentials and bearer tokens. A nil
+// Authenticator lets every request through.
+type Authenticator struct {
+	users  map[string]string
+	tokens []APIToken
+	realm  string
+}
+
+// NewAuthenticator returns nil when no users or tokens are configured
+func NewAuthenticator(config AuthConfig) (*Authenticator, error) {
+	if len(config.Users) == 0 && len(config.Tokens) == 0 {
+		return nil, nil
+	}
+	for user, password := range config.Users {
+		if password == "" {
+			return nil, fmt.Errorf("user %q has no password", user)
+		}
+	}
+	for i, token := range config.Tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("API token %d (%s) is empty", i+1, token.Name)
+		}
+	}
+	return &Authenticator{users: config.Users, tokens: config.Tokens, realm: "Cloud Latency Dashboard"}, nil
+}
+
+// secretMatches compares a presented secret with a configured one, which may
+// be stored as "sha256:" and a hex digest, in constant time
+func secretMatches(configured, presented string) bool {
+	if digest, ok := strings.CutPrefix(configured, "sha256:"); ok {
+		sum := sha256.Sum256([]byte(presented))
+		presented, configured = hex.EncodeToString(sum[:]), strings.ToLower(digest)
+	}
+	return subtle.ConstantTimeCompare([]byte(configured), []byte(presented)) == 1
+}
+
+// authenticate returns who made the request and whether they may only read
+func (a *Authenticator) authenticate(r *http.Request) (name string, readOnly, ok bool) {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		for _, candidate := range a.tokens {
+			if secretMatches(candidate.Token, strings.TrimSpace(token)) {
+				return "token " + candidate.Name, candidate.ReadOnly, true
+			}
+		}
+		return "", false, false
+	}
+	if user, password, found := r.BasicAuth(); found {
+		configured, exists := a.users[user]
+		if exists && secretMatches(configured, password) {
+			return user, false, true
+		}
+	}
+	return "", false, false
+}
+
+// Wrap requires credentials on every request. Read-only tokens are refused
+// anything but GET and HEAD.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, readOnly, ok := a.authenticate(r)
+		if !ok {
+			if r.Header.Get("Authorization") != "" {
+				log.Printf("Authentication failed for %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			}
+			if len(a.users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+a.realm+`", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+a.realm+`"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if readOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			log.Printf("Read-only %s refused %s %s", name, r.Method, r.URL.Path)
+			http.Error(w, "Forbidden: read-only token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authNameKey{}, name)))
+	})
+}
+
+// authNameKey holds the authenticated user or token name in a request context
+type authNameKey struct{}
+
+// authenticatedName returns who made the request, or "" when authentication
+// is off
+func authenticatedName(r *http.Request) string {
+	name, _ := r.Context().Value(authNameKey{}).(string)
+	return name
+}
+
+// Page templates are parsed once at startup
+var (
+	dashboardTemplate = parsePage("dashboard.html", template.FuncMap{
+		"lower":      strings.ToLower,
+		"asset":      assetURL,
+		"barsSVG":    barsSVG,
+		"mapSVG":     mapSVG,
+		"endpointID": endpointID,
+	})
+	sloTemplate = parsePage("slo.html", template.FuncMap{
+		"window": formatWindow,
+		"width":  budgetWidth,
+		"asset":  assetURL,
+	})
+	endpointTemplate = parsePage("endpoint.html", template.FuncMap{
+		"lower":     strings.ToLower,
+		"asset":     func(name string) string { return "../" + assetURL(name) }, // pages are one level down
+		"histogram": histogramSVG,
+		"window":    formatWindow,
+		"json": func(v interface{}) (template.JS, error) {
+			data, err := json.Marshal(v)
+			return template.JS(data), err
+		},
+	})
+	heatmapTemplate = parsePage("heatmap.html", template.FuncMap{
+		"asset":     assetURL,
+		"heatColor": heatColor,
+	})
+	statusTemplate = parsePage("status.html", template.FuncMap{
+		"asset":       assetURL,
+		"lower":       strings.ToLower,
+		"statusLabel": statusLabel,
+		"uptimeClass": uptimeClass,
+	})
+)
+
+// parsePage parses one embedded page template
+func parsePage(name string, funcs template.FuncMap) *template.Template {
+	return template.Must(template.New(name).Funcs(funcs).ParseFS(templateFS, "web/templates/"+name))
+}
+
+// DashboardModel caches the dashboard data built from latency_history.json,
+// the newest archive file and the configuration. Handlers share immutable
+// snapshots; a snapshot is rebuilt when one of the files changes or the
+// monitor pushes a reload.
+type DashboardModel struct {
+	mu      sync.Mutex // serializes rebuilds
+	current atomic.Pointer[modelSnapshot]
+	stale   atomic.Bool
+}
+
+// modelSnapshot is one build of the model and the file versions it saw
+type modelSnapshot struct {
+	data  *DashboardData
+	stamp string
+}
+
+// model serves every handler that needs the dashboard data
+var model = &DashboardModel{}
+
+// stamp fingerprints the files the model is built from by size and
+// modification time
+func (m *DashboardModel) stamp() string {
+	files := []string{"latency_history.json", "monitor_config.json"}
+	if days, err := filepath.Glob(filepath.Join(archiveDir, "*.jsonl")); err == nil && len(days) > 0 {
+		sort.Strings(days)
+		files = append(files, days[len(days)-1])
+	}
+
+	var b strings.Builder
+	for _, name := range files {
+		if info, err := os.Stat(name); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// Snapshot returns the current dashboard data, rebuilding it first if the
+// files changed. The returned data must not be modified.
+func (m *DashboardModel) Snapshot() (*DashboardData, error) {
+	stamp := m.stamp()
+	if current := m.current.Load(); current != nil && current.stamp == stamp && !m.stale.Load() {
+		return current.data, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Another request may have rebuilt it while this one waited
+	stamp = m.stamp()
+	if current := m.current.Load(); current != nil && current.stamp == stamp && !m.stale.Load() {
+		return current.data, nil
+	}
+
+	m.stale.Store(false)
+	data, err := buildDashboardData()
+	if err != nil {
+		return nil, err
+	}
+	m.current.Store(&modelSnapshot{data: data, stamp: stamp})
+	return data, nil
+}
+
+// Invalidate makes the next Snapshot rebuild even if no file looks changed,
+// e.g. when a write landed within the file system's timestamp resolution
+func (m *DashboardModel) Invalidate() {
+	m.stale.Store(true)
+}
+
+// withLiveLastSeen returns a copy of the data with each row's last-seen time
+// advanced to the latest result relayed from the monitor's live feed
+func withLiveLastSeen(snapshot *DashboardData) *DashboardData {
+	data := *snapshot
+	lastSeen := live.LastSeen()
+	if len(lastSeen) == 0 {
+		return &data
+	}
+	data.Summary = append([]EndpointSummary(nil), snapshot.Summary...)
+	for i := range data.Summary {
+		if t := lastSeen[data.Summary[i].Name]; t.After(data.Summary[i].LastSeen) {
+			data.Summary[i].LastSeen = t
+		}
+	}
+	return &data
 }
 
 func dashboardHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := loadDashboardData()
+	snapshot, err := model.Snapshot()
 	if err != nil {
 		log.Printf("Error loading data: %v", err)
 		http.Error(w, "Error loading data: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	data := withLiveLastSeen(snapshot)
 
-	funcMap := template.FuncMap{
-		"lower": strings.ToLower,
+	// Chart selection used by the no-JavaScript fallback
+	query := r.URL.Query()
+	data.Ranges = []string{"1h", "24h", "7d", "30d"}
+	data.SeriesRange = "24h"
+	if value := query.Get("range"); value != "" {
+		data.SeriesRange = value
 	}
-
-	tmpl, err := template.New("dashboard").Funcs(funcMap).Parse(dashboardHTML)
-	if err != nil {
-		log.Printf("Template parse error: %v", err)
-		http.Error(w, "Template error", http.StatusInternalServerError)
-		return
+	data.SeriesService = query.Get("series")
+	if data.SeriesService == "" && len(data.Summary) > 0 {
+		data.SeriesService = data.Summary[0].Name
+	}
+	data.LayersLocation = query.Get("layers")
+	if data.LayersLocation == "" && len(data.Locations) > 0 {
+		data.LayersLocation = data.Locations[0]
 	}
 
-	if err := tmpl.Execute(w, data); err != nil {
+	data.Filter = parseEndpointFilter(query)
+	data.Endpoints = data.Filter.Apply(data.Summary)
+	data.Columns = tableColumns(query, data.Filter)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
 		log.Printf("Template execute error: %v", err)
 		http.Error(w, "Template execution error", http.StatusInternalServerError)
 	}
 }
 
 func dataAPIHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := loadDashboardData()
+	data, err := model.Snapshot()
 	if err != nil {
 		http.Error(w, "Error loading data", http.StatusInternalServerError)
 		return
 	}
 
+	data = withLiveLastSeen(data)
+	data.Filter = parseEndpointFilter(r.URL.Query())
+	data.Endpoints = data.Filter.Apply(data.Summary)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
 
-func loadDashboardData() (*DashboardData, error) {
+// reloadHandler serves POST /api/reload, which rebuilds the dashboard model
+// at once; the monitor's live feed does the same at the end of every cycle
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	model.Invalidate()
+	if _, err := model.Snapshot(); err != nil {
+		log.Printf("Error loading data: %v", err)
+		http.Error(w, "Error loading data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadSLOStatus reads the SLO evaluation written by the monitor
+func loadSLOStatus() ([]SLOStatus, error) {
+	data, err := os.ReadFile("slo_status.json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var statuses []SLOStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// formatWindow renders a Go duration string such as "720h0m0s" as "30d"
+func formatWindow(window string) string {
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return window
+	}
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
+
+// budgetWidth clamps the remaining error budget to a 0-100 bar width
+func budgetWidth(remaining float64) float64 {
+	if remaining < 0 {
+		return 0
+	}
+	if remaining > 100 {
+		return 100
+	}
+	return remaining
+}
+
+func sloHandler(w http.ResponseWriter, r *http.Request) {
+	statuses, err := loadSLOStatus()
+	if err != nil {
+		log.Printf("Error loading SLO status: %v", err)
+		http.Error(w, "Error loading SLO status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		LastUpdate string
+		SLOs       []SLOStatus
+	}{
+		LastUpdate: time.Now().Format("2006-01-02 15:04:05"),
+		SLOs:       statuses,
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := sloTemplate.Execute(w, data); err != nil {
+		log.Printf("Template execute error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+func sloAPIHandler(w http.ResponseWriter, r *http.Request) {
+	statuses, err := loadSLOStatus()
+	if err != nil {
+		http.Error(w, "Error loading SLO status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// buildDashboardData computes the dashboard from the monitor's files; the
+// DashboardModel caches the result
+func buildDashboardData() (*DashboardData, error) {
 	fileData, err := os.ReadFile("latency_history.json")
 	if err != nil {
 		return nil, err
@@ -98,7 +929,8 @@ func loadDashboardData() (*DashboardData, error) {
 
 	var summary []EndpointSummary
 
-	for serviceName, dataPoints := range history {
+	for serviceName, points := range history {
+		dataPoints := successfulPoints(points)
 		if len(dataPoints) == 0 {
 			continue
 		}
@@ -122,6 +954,7 @@ func loadDashboardData() (*DashboardData, error) {
 
 		avgMs := totalMs / int64(len(dataPoints))
 		latestMs := dataPoints[len(dataPoints)-1].ResponseTime / 1000000
+		seen := points[len(points)-1].Timestamp
 		firstMs := dataPoints[0].ResponseTime / 1000000
 
 		trendPct := 0.0
@@ -137,6 +970,7 @@ func loadDashboardData() (*DashboardData, error) {
 		}
 
 		summary = append(summary, EndpointSummary{
+			ID:           endpointID(location, provider),
 			Name:         serviceName,
 			Location:     location,
 			Provider:     provider,
@@ -148,6 +982,7 @@ func loadDashboardData() (*DashboardData, error) {
 			Count:        len(dataPoints),
 			Status:       status,
 			TrendPercent: trendPct,
+			LastSeen:     seen,
 		})
 	}
 
@@ -158,293 +993,3262 @@ func loadDashboardData() (*DashboardData, error) {
 		return summary[i].Location < summary[j].Location
 	})
 
-	// Don't use template.JS - just pass the raw JSON string
-	timeSeriesBytes, err := json.Marshal(summary)
+	// The newest check of each service, even if it was not checked today yet
+	recent, err := latestRecords(archiveDir, time.Now(), latestRecordWindow)
 	if err != nil {
-		return nil, err
+		log.Printf("Could not read archive: %v", err)
 	}
+	records := make([]ArchiveRecord, 0, len(recent))
+	for _, record := range recent {
+		records = append(records, record)
+	}
+	diagnoses := latestDiagnoses(records)
 
-	return &DashboardData{
-		LastUpdate:     time.Now().Format("2006-01-02 15:04:05"),
-		TotalEndpoints: len(summary),
-		Summary:        summary,
-		TimeSeriesJSON: template.JS(timeSeriesBytes), // Pass bytes directly, not string
-	}, nil
-}
-
-func parseServiceName(name string) (location, provider, testType string) {
-	testType = "other"
-	provider = "N/A"
-	location = name
+	verdicts := make(map[string]string)
+	for _, d := range diagnoses {
+		verdicts[d.Location+"|"+d.Provider] = d.Verdict
+	}
+	for i := range summary {
+		summary[i].Diagnosis = verdicts[summary[i].Location+"|"+summary[i].Provider]
 
-	if idx := strings.LastIndex(name, " - "); idx >= 0 {
-		testType = strings.ToLower(name[idx+3:])
-		name = name[:idx]
+		record, ok := recent[summary[i].Name]
+		if !ok {
+			summary[i].Health = "UNKNOWN"
+			continue
+		}
+		summary[i].Region = record.Region
+		summary[i].Hostname = record.Hostname
+		summary[i].Labels = record.Labels
+		if record.Coordinates != nil && record.Coordinates.Valid() {
+			summary[i].Continent = continentOf(*record.Coordinates)
+		}
+		switch {
+		case record.Maintenance != "":
+			summary[i].Health = "MAINTENANCE"
+		case record.Degraded:
+			summary[i].Health = "DEGRADED"
+		case !record.Online:
+			summary[i].Health = "DOWN"
+		default:
+			summary[i].Health = "UP"
+		}
 	}
 
-	if start := strings.Index(name, "["); start >= 0 {
-		if end := strings.Index(name[start:], "]"); end >= 0 {
-			provider = name[start+1 : start+end]
-			location = strings.TrimSpace(name[:start])
+	seen := make(map[string]bool)
+	var locations []string
+	for _, endpoint := range summary {
+		name := endpoint.Location + " [" + endpoint.Provider + "]"
+		if endpoint.Provider != "N/A" && !seen[name] {
+			seen[name] = true
+			locations = append(locations, name)
 		}
 	}
 
-	return location, provider, testType
-}
+	// The page is as fresh as the history file it was built from
+	lastUpdate := time.Now()
+	if info, err := os.Stat("latency_history.json"); err == nil {
+		lastUpdate = info.ModTime()
+	}
 
-const dashboardHTML = `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Cloud Latency Dashboard</title>
-    <script src="https://cdn.jsdelivr.net/npm/chart.js@4.4.0/dist/chart.umd.min.js"></script>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: #333;
-            min-height: 100vh;
-            padding: 20px;
-        }
-        .container { max-width: 1400px; margin: 0 auto; }
-        header {
-            background: white;
-            padding: 30px;
-            border-radius: 15px;
-            box-shadow: 0 10px 30px rgba(0,0,0,0.2);
-            margin-bottom: 30px;
-        }
-        h1 { color: #667eea; font-size: 2.5em; margin-bottom: 10px; }
-        .subtitle { color: #666; font-size: 1.1em; }
-        .stats-grid {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(250px, 1fr));
-            gap: 20px;
-            margin-bottom: 30px;
-        }
-        .stat-card {
-            background: white;
-            padding: 25px;
-            border-radius: 12px;
-            box-shadow: 0 5px 15px rgba(0,0,0,0.1);
-        }
-        .stat-label {
-            color: #666;
-            font-size: 0.9em;
-            text-transform: uppercase;
-            letter-spacing: 1px;
-            margin-bottom: 10px;
-        }
-        .stat-value { font-size: 2.5em; font-weight: bold; color: #667eea; }
-        .chart-grid {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(500px, 1fr));
-            gap: 30px;
-            margin-bottom: 30px;
-        }
-        .chart-container {
-            background: white;
-            padding: 25px;
-            border-radius: 12px;
-            box-shadow: 0 5px 15px rgba(0,0,0,0.1);
-        }
-        .chart-title { font-size: 1.3em; margin-bottom: 20px; color: #333; }
-        .table-container {
-            background: white;
-            padding: 25px;
-            border-radius: 12px;
-            box-shadow: 0 5px 15px rgba(0,0,0,0.1);
-            overflow-x: auto;
-        }
-        table { width: 100%; border-collapse: collapse; }
-        th {
-            background: #667eea;
-            color: white;
-            padding: 15px;
-            text-align: left;
-            font-weight: 600;
-        }
-        td { padding: 12px 15px; border-bottom: 1px solid #e0e0e0; }
-        tr:hover { background: #f5f5f5; }
-        .status-badge {
-            padding: 5px 12px;
-            border-radius: 20px;
-            font-size: 0.85em;
-            font-weight: 600;
-            display: inline-block;
-        }
-        .status-fast { background: #4caf50; color: white; }
-        .status-slow { background: #f44336; color: white; }
-        .status-steady { background: #ff9800; color: white; }
-        .test-type-ping { color: #2196f3; font-weight: 600; }
-        .test-type-dns { color: #4caf50; font-weight: 600; }
-        .test-type-http { color: #ff9800; font-weight: 600; }
-        .refresh-info { text-align: center; color: white; margin-top: 20px; font-size: 0.9em; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <header>
-            <h1>🌐 Cloud Infrastructure Latency Dashboard</h1>
-            <p class="subtitle">Real-time monitoring of AWS global endpoints | Last update: {{.LastUpdate}}</p>
-        </header>
-        
-        <div class="stats-grid">
-            <div class="stat-card">
-                <div class="stat-label">Total Endpoints</div>
-                <div class="stat-value">{{.TotalEndpoints}}</div>
-            </div>
-            <div class="stat-card">
-                <div class="stat-label">Fastest Ping</div>
-                <div class="stat-value" id="fastest-ping">--</div>
-            </div>
-            <div class="stat-card">
-                <div class="stat-label">Slowest Ping</div>
-                <div class="stat-value" id="slowest-ping">--</div>
-            </div>
-            <div class="stat-card">
-                <div class="stat-label">Avg Latency</div>
-                <div class="stat-value" id="avg-latency">--</div>
-            </div>
-        </div>
-        
-        <div class="chart-grid">
-            <div class="chart-container">
-                <h3 class="chart-title">Latency by Region</h3>
-                <canvas id="regionChart"></canvas>
-            </div>
-            <div class="chart-container">
-                <h3 class="chart-title">Test Type Comparison</h3>
-                <canvas id="testTypeChart"></canvas>
-            </div>
-        </div>
-        
-        <div class="chart-container" style="margin-bottom: 30px;">
-            <h3 class="chart-title">Geographic Distribution</h3>
-            <canvas id="geoChart"></canvas>
-        </div>
-        
-        <div class="table-container">
-            <h3 class="chart-title">All Endpoints</h3>
-            <table>
-                <thead>
-                    <tr>
-                        <th>Location</th><th>Provider</th><th>Test Type</th>
-                        <th>Latest (ms)</th><th>Avg (ms)</th><th>Min (ms)</th><th>Max (ms)</th>
-                        <th>Samples</th><th>Trend</th><th>Status</th>
-                    </tr>
-                </thead>
-                <tbody>
-                    {{range .Summary}}
-                    <tr>
-                        <td>{{.Location}}</td>
-                        <td>{{.Provider}}</td>
-                        <td class="test-type-{{.TestType}}">{{.TestType}}</td>
-                        <td>{{.LatestMs}}</td>
-                        <td>{{.AvgMs}}</td>
-                        <td>{{.MinMs}}</td>
-                        <td>{{.MaxMs}}</td>
-                        <td>{{.Count}}</td>
-                        <td>{{printf "%.1f" .TrendPercent}}%</td>
-                        <td><span class="status-badge status-{{.Status}}">{{.Status}}</span></td>
-                    </tr>
-                    {{end}}
-                </tbody>
-            </table>
-        </div>
-        
-        <div class="refresh-info">Dashboard auto-refreshes every 30 seconds</div>
-    </div>
-    
-    <script>
-        let timeSeriesData = [];
-        try {
-            timeSeriesData = {{.TimeSeriesJSON}};
-            console.log('Loaded data points:', timeSeriesData.length);
-        } catch (e) {
-            console.error('Failed to parse data:', e);
-        }
-        
-        const pingData = timeSeriesData.filter(d => d.TestType === 'ping');
-        if (pingData.length > 0) {
-            const latencies = pingData.map(d => d.AvgMs);
-            document.getElementById('fastest-ping').textContent = Math.min(...latencies) + 'ms';
-            document.getElementById('slowest-ping').textContent = Math.max(...latencies) + 'ms';
-            document.getElementById('avg-latency').textContent = Math.round(latencies.reduce((a,b) => a+b, 0) / latencies.length) + 'ms';
-        }
-        
-        const awsData = timeSeriesData.filter(d => d.Provider === 'AWS');
-        const regionData = {};
-        awsData.forEach(d => {
-            if (!regionData[d.Location]) regionData[d.Location] = [];
-            regionData[d.Location].push(d.AvgMs);
-        });
-        
-        const regionLabels = Object.keys(regionData).sort().slice(0, 15);
-        const regionValues = regionLabels.map(loc => Math.round(regionData[loc].reduce((a,b) => a+b, 0) / regionData[loc].length));
-        
-        new Chart(document.getElementById('regionChart'), {
-            type: 'bar',
-            data: {
-                labels: regionLabels,
-                datasets: [{ label: 'Avg Latency (ms)', data: regionValues, backgroundColor: 'rgba(102, 126, 234, 0.8)' }]
-            },
-            options: {
-                responsive: true,
-                plugins: { legend: { display: false } },
-                scales: { y: { beginAtZero: true }, x: { ticks: { maxRotation: 45, minRotation: 45, font: { size: 10 } } } }
-            }
-        });
-        
-        const testTypes = { ping: [], dns: [], http: [] };
-        awsData.forEach(d => { if (testTypes[d.TestType]) testTypes[d.TestType].push(d.AvgMs); });
-        const testAvgs = Object.keys(testTypes).map(t => {
-            const v = testTypes[t];
-            return v.length > 0 ? Math.round(v.reduce((a,b) => a+b, 0) / v.length) : 0;
-        });
-        
-        new Chart(document.getElementById('testTypeChart'), {
-            type: 'doughnut',
-            data: {
-                labels: ['PING', 'DNS', 'HTTP'],
-                datasets: [{ data: testAvgs, backgroundColor: ['rgba(33,150,243,0.8)', 'rgba(76,175,80,0.8)', 'rgba(255,152,0,0.8)'] }]
-            },
-            options: { responsive: true, plugins: { legend: { position: 'bottom' } } }
-        });
-        
-        const continents = {
-            'North America': ['Ashburn', 'Columbus', 'San Jose', 'Portland', 'Montreal'],
-            'South America': ['Paulo'],
-            'Europe': ['London', 'Paris', 'Frankfurt', 'Stockholm', 'Milan'],
-            'Middle East': ['Dubai', 'Riyadh'],
-            'Asia': ['Mumbai', 'Hyderabad', 'Singapore', 'Jakarta', 'Tokyo', 'Seoul', 'Osaka'],
-            'Africa': ['Cape Town'],
-            'Oceania': ['Sydney', 'Melbourne']
-        };
-        
-        const continentAvgs = {};
-        Object.keys(continents).forEach(cont => {
-            const vals = awsData.filter(d => continents[cont].some(c => d.Location.includes(c))).map(d => d.AvgMs);
-            if (vals.length > 0) continentAvgs[cont] = Math.round(vals.reduce((a,b) => a+b, 0) / vals.length);
-        });
-        
-        new Chart(document.getElementById('geoChart'), {
-            type: 'bar',
-            data: {
-                labels: Object.keys(continentAvgs),
-                datasets: [{ label: 'Avg Latency (ms)', data: Object.values(continentAvgs), backgroundColor: 'rgba(118, 75, 162, 0.8)' }]
-            },
-            options: {
-                responsive: true,
-                indexAxis: 'y',
-                plugins: { legend: { display: false } },
-                scales: { x: { beginAtZero: true } }
-            }
-        });
-        
-        setTimeout(() => location.reload(), 30000);
-    </script>
-</body>
-</html>`
+	data := &DashboardData{
+		LastUpdate:     lastUpdate.Format("2006-01-02 15:04:05"),
+		TotalEndpoints: len(summary),
+		Summary:        summary,
+		Diagnoses:      diagnoses,
+		Locations:      locations,
+		Live:           live != nil,
+		Charts:         buildCharts(summary),
+		Map:            buildWorldMap(summary, recent, loadVantage("monitor_config.json")),
+		Options:        buildFilterOptions(summary),
+	}
+
+	var pingTotal int64
+	var pings int64
+	for _, endpoint := range summary {
+		if endpoint.TestType != "ping" {
+			continue
+		}
+		if pings == 0 || endpoint.AvgMs < data.FastestPingMs {
+			data.FastestPingMs = endpoint.AvgMs
+		}
+		if endpoint.AvgMs > data.SlowestPingMs {
+			data.SlowestPingMs = endpoint.AvgMs
+		}
+		pingTotal += endpoint.AvgMs
+		pings++
+	}
+	if pings > 0 {
+		data.AvgPingMs = pingTotal / pings
+	}
+
+	chartBytes, err := json.Marshal(data.Charts)
+	if err != nil {
+		return nil, err
+	}
+	data.ChartsJSON = template.JS(chartBytes)
+
+	return data, nil
+}
+
+// continentOf places coordinates on a continent. The boxes are coarse but
+// separate every region the monitor knows.
+func continentOf(c Coordinates) string {
+	switch {
+	case c.Longitude < -25:
+		if c.Latitude >= 13 {
+			return "North America"
+		}
+		return "South America"
+	case c.Latitude >= 12 && c.Latitude < 42 && c.Longitude >= 34.5 && c.Longitude < 63:
+		return "Middle East"
+	case c.Latitude >= 36 && c.Longitude < 45:
+		return "Europe"
+	case c.Longitude < 52:
+		return "Africa"
+	case c.Latitude < -10 && c.Longitude >= 110:
+		return "Oceania"
+	}
+	return "Asia"
+}
+
+// EndpointFilter selects and orders the rows of the endpoints table. It is
+// read from the page URL, so a view can be bookmarked and shared. Fields
+// match case-insensitively and may use shell-style wildcards, like SLO
+// matchers.
+type EndpointFilter struct {
+	Provider  string
+	Region    string
+	Continent string
+	TestType  string
+	Status    string   // health: up, degraded, down, maintenance or unknown
+	Labels    []string // "key:value"
+	Query     string   // free text searched in names, regions, hostnames and labels
+	Sort      string   // a key of endpointColumns
+	Desc      bool
+}
+
+// parseEndpointFilter reads the filter from a URL query:
+// ?provider=AWS&continent=Europe&type=http&status=down&label=env:prod&q=paris&sort=avg&order=desc
+func parseEndpointFilter(query url.Values) EndpointFilter {
+	filter := EndpointFilter{
+		Provider:  query.Get("provider"),
+		Region:    query.Get("region"),
+		Continent: query.Get("continent"),
+		TestType:  query.Get("type"),
+		Status:    query.Get("status"),
+		Query:     strings.TrimSpace(query.Get("q")),
+		Sort:      query.Get("sort"),
+		Desc:      query.Get("order") == "desc",
+	}
+	for _, label := range query["label"] {
+		if label != "" {
+			filter.Labels = append(filter.Labels, label)
+		}
+	}
+	if endpointColumn(filter.Sort) == nil {
+		filter.Sort = ""
+	}
+	return filter
+}
+
+// Active reports whether the filter hides any rows
+func (f EndpointFilter) Active() bool {
+	return f.Provider != "" || f.Region != "" || f.Continent != "" || f.TestType != "" ||
+		f.Status != "" || len(f.Labels) > 0 || f.Query != ""
+}
+
+// HasLabel reports whether the filter requires the "key:value" label
+func (f EndpointFilter) HasLabel(label string) bool {
+	for _, l := range f.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the filter selects the endpoint
+func (f EndpointFilter) Matches(endpoint EndpointSummary) bool {
+	if !matchField(f.Provider, endpoint.Provider) ||
+		!matchField(f.Region, endpoint.Region) ||
+		!matchField(f.Continent, endpoint.Continent) ||
+		!matchField(f.TestType, endpoint.TestType) ||
+		!matchField(f.Status, endpoint.Health) {
+		return false
+	}
+
+	for _, label := range f.Labels {
+		key, value, _ := strings.Cut(label, ":")
+		if _, ok := endpoint.Labels[key]; !ok || !matchField(value, endpoint.Labels[key]) {
+			return false
+		}
+	}
+
+	if f.Query == "" {
+		return true
+	}
+	text := []string{endpoint.Name, endpoint.Region, endpoint.Continent, endpoint.Hostname, endpoint.Health, endpoint.Diagnosis}
+	for key, value := range endpoint.Labels {
+		text = append(text, key+":"+value)
+	}
+	return strings.Contains(strings.ToLower(strings.Join(text, "\n")), strings.ToLower(f.Query))
+}
+
+// Apply returns the matching endpoints in the filter's order, leaving
+// endpoints untouched
+func (f EndpointFilter) Apply(endpoints []EndpointSummary) []EndpointSummary {
+	rows := []EndpointSummary{}
+	for _, endpoint := range endpoints {
+		if f.Matches(endpoint) {
+			rows = append(rows, endpoint)
+		}
+	}
+
+	if column := endpointColumn(f.Sort); column != nil {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if f.Desc {
+				return column.compare(rows[j], rows[i]) < 0
+			}
+			return column.compare(rows[i], rows[j]) < 0
+		})
+	}
+	return rows
+}
+
+// sortColumn is a sortable column of the endpoints table
+type sortColumn struct {
+	Key     string // the sort parameter
+	Label   string
+	compare func(a, b EndpointSummary) int
+}
+
+// endpointColumns are the columns of the endpoints table, in order
+var endpointColumns = []sortColumn{
+	{"location", "Location", func(a, b EndpointSummary) int { return strings.Compare(a.Location, b.Location) }},
+	{"provider", "Provider", func(a, b EndpointSummary) int { return strings.Compare(a.Provider, b.Provider) }},
+	{"region", "Region", func(a, b EndpointSummary) int { return strings.Compare(a.Region, b.Region) }},
+	{"type", "Test Type", func(a, b EndpointSummary) int { return strings.Compare(a.TestType, b.TestType) }},
+	{"latest", "Latest (ms)", func(a, b EndpointSummary) int { return cmp.Compare(a.LatestMs, b.LatestMs) }},
+	{"avg", "Avg (ms)", func(a, b EndpointSummary) int { return cmp.Compare(a.AvgMs, b.AvgMs) }},
+	{"min", "Min (ms)", func(a, b EndpointSummary) int { return cmp.Compare(a.MinMs, b.MinMs) }},
+	{"max", "Max (ms)", func(a, b EndpointSummary) int { return cmp.Compare(a.MaxMs, b.MaxMs) }},
+	{"samples", "Samples", func(a, b EndpointSummary) int { return cmp.Compare(a.Count, b.Count) }},
+	{"trend", "Trend", func(a, b EndpointSummary) int { return cmp.Compare(a.TrendPercent, b.TrendPercent) }},
+	{"status", "Status", func(a, b EndpointSummary) int { return strings.Compare(a.Health, b.Health) }},
+	{"diagnosis", "Diagnosis", func(a, b EndpointSummary) int { return strings.Compare(a.Diagnosis, b.Diagnosis) }},
+	{"seen", "Last Seen", func(a, b EndpointSummary) int { return a.LastSeen.Compare(b.LastSeen) }},
+}
+
+// endpointColumn looks up a sortable column by key
+func endpointColumn(key string) *sortColumn {
+	for i := range endpointColumns {
+		if endpointColumns[i].Key == key {
+			return &endpointColumns[i]
+		}
+	}
+	return nil
+}
+
+// TableColumn is a header of the endpoints table, linking to the same view
+// sorted by that column
+type TableColumn struct {
+	Label string
+	Href  string
+	Order string // "asc" or "desc" when the table is sorted by this column
+}
+
+// tableColumns builds the table headers. Clicking the sorted column again
+// reverses the order; every other filter parameter is kept.
+func tableColumns(query url.Values, filter EndpointFilter) []TableColumn {
+	columns := make([]TableColumn, 0, len(endpointColumns))
+	for _, column := range endpointColumns {
+		params := url.Values{}
+		for key, values := range query {
+			params[key] = values
+		}
+		params.Set("sort", column.Key)
+		params.Del("order")
+
+		header := TableColumn{Label: column.Label}
+		if filter.Sort == column.Key {
+			header.Order = "asc"
+			if filter.Desc {
+				header.Order = "desc"
+			} else {
+				params.Set("order", "desc")
+			}
+		}
+		header.Href = "?" + params.Encode()
+		columns = append(columns, header)
+	}
+	return columns
+}
+
+// FilterOptions are the values offered by the endpoints table's filters
+type FilterOptions struct {
+	Providers  []string
+	Regions    []string
+	Continents []string
+	TestTypes  []string
+	Statuses   []string
+	Labels     []string // "key:value"
+}
+
+// buildFilterOptions collects the distinct values present in the summary
+func buildFilterOptions(summary []EndpointSummary) FilterOptions {
+	providers := make(map[string]bool)
+	regions := make(map[string]bool)
+	continents := make(map[string]bool)
+	testTypes := make(map[string]bool)
+	statuses := make(map[string]bool)
+	labels := make(map[string]bool)
+	for _, endpoint := range summary {
+		providers[endpoint.Provider] = true
+		testTypes[endpoint.TestType] = true
+		statuses[endpoint.Health] = true
+		if endpoint.Region != "" {
+			regions[endpoint.Region] = true
+		}
+		if endpoint.Continent != "" {
+			continents[endpoint.Continent] = true
+		}
+		for key, value := range endpoint.Labels {
+			labels[key+":"+value] = true
+		}
+	}
+
+	return FilterOptions{
+		Providers:  sortedKeys(providers),
+		Regions:    sortedKeys(regions),
+		Continents: sortedKeys(continents),
+		TestTypes:  sortedKeys(testTypes),
+		Statuses:   sortedKeys(statuses),
+		Labels:     sortedKeys(labels),
+	}
+}
+
+// mean averages a list of millisecond values
+func mean(values []int64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var total int64
+	for _, v := range values {
+		total += v
+	}
+	return float64(total) / float64(len(values))
+}
+
+// buildCharts computes the summary charts from the AWS endpoints
+func buildCharts(summary []EndpointSummary) DashboardCharts {
+	var charts DashboardCharts
+
+	byLocation := make(map[string][]int64)
+	byLayer := make(map[string][]int64)
+	for _, endpoint := range summary {
+		if endpoint.Provider != "AWS" {
+			continue
+		}
+		byLocation[endpoint.Location] = append(byLocation[endpoint.Location], endpoint.AvgMs)
+		byLayer[endpoint.TestType] = append(byLayer[endpoint.TestType], endpoint.AvgMs)
+	}
+
+	locations := make([]string, 0, len(byLocation))
+	for location := range byLocation {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	if len(locations) > 15 {
+		locations = locations[:15]
+	}
+	for _, location := range locations {
+		charts.Region = append(charts.Region, ChartBar{Label: location, Value: math.Round(mean(byLocation[location]))})
+	}
+
+	for _, layer := range []string{"ping", "dns", "http"} {
+		charts.Layers = append(charts.Layers, ChartBar{Label: strings.ToUpper(layer), Value: math.Round(mean(byLayer[layer]))})
+	}
+
+	return charts
+}
+
+// WorldMap places every endpoint with known coordinates on a world map
+type WorldMap struct {
+	Vantage  *VantagePoint
+	Markers  []MapMarker
+	Unplaced []string // endpoints without coordinates
+}
+
+// MapMarker is one location on the world map, covering all of its layers
+type MapMarker struct {
+	ID   string
+	Name string // "Paris, FR [AWS]", the service key prefix
+	Coordinates
+	AvgMs    float64  // average latency over the location's layers
+	Status   string   // UP, DEGRADED or DOWN, from the latest result of each layer
+	Down     []string // layers whose latest result failed
+	Degraded []string
+}
+
+// loadVantage reads the vantage point from the monitor's configuration,
+// returning nil when none is configured
+func loadVantage(filename string) *VantagePoint {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var config struct{ Vantage *VantagePoint }
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Could not read vantage point from %s: %v", filename, err)
+		return nil
+	}
+	if config.Vantage != nil && !config.Vantage.Valid() {
+		return nil
+	}
+	return config.Vantage
+}
+
+// buildWorldMap places the endpoints at the coordinates of their latest
+// archive records; status comes from the latest result of each layer
+func buildWorldMap(summary []EndpointSummary, latest map[string]ArchiveRecord, vantage *VantagePoint) WorldMap {
+	worldMap := WorldMap{Vantage: vantage}
+
+	markers := make(map[string]*MapMarker)
+	for _, record := range latest {
+		if record.Coordinates == nil || !record.Coordinates.Valid() {
+			continue
+		}
+		id := endpointID(record.Location, record.Provider)
+		marker, ok := markers[id]
+		if !ok {
+			marker = &MapMarker{ID: id, Name: record.Location + " [" + record.Provider + "]", Coordinates: *record.Coordinates, Status: "UP"}
+			markers[id] = marker
+		}
+		switch {
+		case record.Maintenance != "":
+		case record.Degraded:
+			marker.Degraded = append(marker.Degraded, record.TestType)
+		case !record.Online:
+			marker.Down = append(marker.Down, record.TestType)
+		}
+	}
+
+	latencies := make(map[string][]int64)
+	unplaced := make(map[string]bool)
+	for _, endpoint := range summary {
+		if markers[endpoint.ID] == nil {
+			if endpoint.Provider != "Home" && endpoint.Provider != "N/A" {
+				unplaced[endpoint.Location+" ["+endpoint.Provider+"]"] = true
+			}
+			continue
+		}
+		latencies[endpoint.ID] = append(latencies[endpoint.ID], endpoint.AvgMs)
+	}
+
+	for id, marker := range markers {
+		marker.AvgMs = math.Round(mean(latencies[id]))
+		sort.Strings(marker.Down)
+		sort.Strings(marker.Degraded)
+		if len(marker.Down) > 0 {
+			marker.Status = "DOWN"
+		} else if len(marker.Degraded) > 0 {
+			marker.Status = "DEGRADED"
+		}
+		worldMap.Markers = append(worldMap.Markers, *marker)
+	}
+	sort.Slice(worldMap.Markers, func(i, j int) bool { return worldMap.Markers[i].Name < worldMap.Markers[j].Name })
+
+	for name := range unplaced {
+		worldMap.Unplaced = append(worldMap.Unplaced, name)
+	}
+	sort.Strings(worldMap.Unplaced)
+
+	return worldMap
+}
+
+// worldOutline is the coastline drawn under the map markers, projected once
+// from web/static/world.json
+var worldOutline struct {
+	once  sync.Once
+	land  string
+	lakes string
+}
+
+// outlineRing is one closed coastline of web/static/world.json
+type outlineRing struct {
+	Name string
+	Ring [][2]float64 // longitude, latitude
+}
+
+// The map is an equirectangular projection cropped to the inhabited latitudes
+const (
+	mapWidth  = 1000.0
+	mapNorth  = 84.0
+	mapSouth  = -58.0
+	mapHeight = mapWidth * (mapNorth - mapSouth) / 360
+)
+
+// project converts coordinates to map pixels
+func project(c Coordinates) (x, y float64) {
+	return (c.Longitude + 180) / 360 * mapWidth, (mapNorth - c.Latitude) / (mapNorth - mapSouth) * mapHeight
+}
+
+// outlinePaths projects the rings of web/static/world.json into SVG paths
+func outlinePaths() (land, lakes string) {
+	worldOutline.once.Do(func() {
+		data, err := staticFS.ReadFile("web/static/world.json")
+		if err != nil {
+			log.Printf("Could not read world outline: %v", err)
+			return
+		}
+		var outline struct {
+			Land, Lakes []outlineRing
+		}
+		if err := json.Unmarshal(data, &outline); err != nil {
+			log.Printf("Could not parse world outline: %v", err)
+			return
+		}
+		path := func(rings []outlineRing) string {
+			var b strings.Builder
+			for _, ring := range rings {
+				for i, point := range ring.Ring {
+					x, y := project(Coordinates{Latitude: point[1], Longitude: point[0]})
+					command := "L"
+					if i == 0 {
+						command = "M"
+					}
+					fmt.Fprintf(&b, "%s%.1f %.1f", command, x, y)
+				}
+				b.WriteString("Z")
+			}
+			return b.String()
+		}
+		worldOutline.land = path(outline.Land)
+		worldOutline.lakes = path(outline.Lakes)
+	})
+	return worldOutline.land, worldOutline.lakes
+}
+
+// greatCircle interpolates the shortest path between two points, split
+// where it crosses the antimeridian so no segment spans the whole map
+func greatCircle(from, to Coordinates, steps int) [][]Coordinates {
+	toVector := func(c Coordinates) [3]float64 {
+		lat, lon := c.Latitude*math.Pi/180, c.Longitude*math.Pi/180
+		return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+	}
+	a, b := toVector(from), toVector(to)
+	angle := math.Acos(math.Max(-1, math.Min(1, a[0]*b[0]+a[1]*b[1]+a[2]*b[2])))
+	if angle < 1e-6 || math.Pi-angle < 1e-6 {
+		return nil // same point, or antipodes with no single shortest path
+	}
+
+	var segments [][]Coordinates
+	var segment []Coordinates
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		wa, wb := math.Sin((1-t)*angle)/math.Sin(angle), math.Sin(t*angle)/math.Sin(angle)
+		x, y, z := wa*a[0]+wb*b[0], wa*a[1]+wb*b[1], wa*a[2]+wb*b[2]
+		point := Coordinates{
+			Latitude:  math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi,
+			Longitude: math.Atan2(y, x) * 180 / math.Pi,
+		}
+		if n := len(segment); n > 0 && math.Abs(point.Longitude-segment[n-1].Longitude) > 180 {
+			segments = append(segments, segment)
+			segment = nil
+		}
+		segment = append(segment, point)
+	}
+	return append(segments, segment)
+}
+
+// latencyColor buckets a latency for the map markers and legend
+func latencyColor(ms float64) string {
+	switch {
+	case ms < 50:
+		return "#4caf50"
+	case ms < 150:
+		return "#cddc39"
+	case ms < 300:
+		return "#ff9800"
+	default:
+		return "#e65100"
+	}
+}
+
+// mapLegend lists the marker colors shown under the map
+var mapLegend = []struct {
+	Label string
+	Color string
+}{
+	{"< 50ms", latencyColor(0)},
+	{"50-150ms", latencyColor(50)},
+	{"150-300ms", latencyColor(150)},
+	{"300ms+", latencyColor(300)},
+	{"down", "#424242"},
+}
+
+// mapSVG renders the world map with a marker per location and, when a
+// vantage point is configured, great-circle lines from it. Markers link to
+// the endpoint pages; dashboard.js recolors them as live results arrive.
+func mapSVG(worldMap WorldMap) template.HTML {
+	land, lakes := outlinePaths()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="world-map" viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="11">`, mapWidth, mapHeight+30)
+	fmt.Fprintf(&b, `<rect width="%.0f" height="%.0f" fill="#eaf2fb"/>`, mapWidth, mapHeight)
+	fmt.Fprintf(&b, `<path d="%s" fill="#d7dce3" stroke="#b8c0cc" stroke-width="0.5"/>`, land)
+	fmt.Fprintf(&b, `<path d="%s" fill="#eaf2fb" stroke="#b8c0cc" stroke-width="0.5"/>`, lakes)
+
+	if vantage := worldMap.Vantage; vantage != nil {
+		for _, marker := range worldMap.Markers {
+			for _, segment := range greatCircle(vantage.Coordinates, marker.Coordinates, 48) {
+				var points []string
+				for _, point := range segment {
+					x, y := project(point)
+					points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+				}
+				fmt.Fprintf(&b, `<polyline class="map-line" data-endpoint="%s" points="%s" fill="none" stroke="%s" stroke-width="1.2" stroke-opacity="0.5"/>`,
+					svgEscape(marker.Name), strings.Join(points, " "), latencyColor(marker.AvgMs))
+			}
+		}
+	}
+
+	for _, marker := range worldMap.Markers {
+		x, y := project(marker.Coordinates)
+		detail := fmt.Sprintf("%.0fms avg", marker.AvgMs)
+		if len(marker.Down) > 0 {
+			detail += ", down: " + strings.Join(marker.Down, ", ")
+		}
+		if len(marker.Degraded) > 0 {
+			detail += ", degraded: " + strings.Join(marker.Degraded, ", ")
+		}
+		fmt.Fprintf(&b, `<a href="endpoint/%s" class="map-marker marker-%s" data-endpoint="%s" data-down="%s">`,
+			marker.ID, strings.ToLower(marker.Status), svgEscape(marker.Name), svgEscape(strings.Join(marker.Down, ",")))
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="6" fill="%s" stroke="white" stroke-width="1.5"/>`, x, y, latencyColor(marker.AvgMs))
+		fmt.Fprintf(&b, `<title>%s: %s</title></a>`, svgEscape(marker.Name), svgEscape(detail))
+	}
+
+	if vantage := worldMap.Vantage; vantage != nil {
+		x, y := project(vantage.Coordinates)
+		name := vantage.Name
+		if name == "" {
+			name = "Monitor"
+		}
+		fmt.Fprintf(&b, `<g class="map-vantage"><rect x="%.1f" y="%.1f" width="10" height="10" transform="rotate(45 %.1f %.1f)" fill="#667eea" stroke="white" stroke-width="1.5"/>`, x-5, y-5, x, y)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="#333" font-weight="bold">%s</text><title>%s (vantage point)</title></g>`, x+9, y+4, svgEscape(name), svgEscape(name))
+	}
+
+	x := 10.0
+	for _, entry := range mapLegend {
+		fmt.Fprintf(&b, `<circle cx="%.0f" cy="%.0f" r="5" fill="%s"/><text x="%.0f" y="%.0f" fill="#666">%s</text>`,
+			x+5, mapHeight+16, entry.Color, x+14, mapHeight+20, svgEscape(entry.Label))
+		x += 100
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// sseEvent is one Server-Sent Event relayed from the monitor
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// liveResult holds the fields of a relayed result event the dashboard needs
+type liveResult struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+}
+
+// LiveRelay keeps one connection to the monitor's live feed and fans its
+// events out to every browser on /api/events
+type LiveRelay struct {
+	source    string
+	client    *http.Client
+	url       string
+	clients   map[chan sseEvent]bool
+	latest    map[string]sseEvent  // latest result event by service key
+	lastSeen  map[string]time.Time // by service key
+	connected bool
+	mu        sync.Mutex
+}
+
+// NewLiveRelay prepares a relay for an http(s) feed URL or a unix:/path socket
+func NewLiveRelay(source string) *LiveRelay {
+	relay := &LiveRelay{
+		source:   source,
+		client:   &http.Client{},
+		url:      source,
+		clients:  make(map[chan sseEvent]bool),
+		latest:   make(map[string]sseEvent),
+		lastSeen: make(map[string]time.Time),
+	}
+	if socket, ok := strings.CutPrefix(source, "unix:"); ok {
+		relay.url = "http://monitor/events"
+		relay.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	return relay
+}
+
+// Run reads the feed forever, reconnecting with backoff when the monitor goes away
+func (lr *LiveRelay) Run() {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := lr.consume()
+		lr.setConnected(false)
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("Live feed %s: %v; retrying in %v", lr.source, err, backoff)
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// consume reads events from one connection until it fails
+func (lr *LiveRelay) consume() error {
+	resp, err := lr.client.Get(lr.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	lr.setConnected(true)
+
+	var event sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.Data != "" {
+				lr.dispatch(event)
+			}
+			event = sseEvent{}
+		case strings.HasPrefix(line, ":"):
+			// keep-alive comment
+		case strings.HasPrefix(line, "event:"):
+			event.Name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			event.Data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// broadcast sends an event to every browser, dropping any that have fallen too far behind
+func (lr *LiveRelay) broadcast(event sseEvent) {
+	for client := range lr.clients {
+		select {
+		case client <- event:
+		default:
+			delete(lr.clients, client)
+			close(client)
+		}
+	}
+}
+
+func (lr *LiveRelay) dispatch(event sseEvent) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	switch event.Name {
+	case "result":
+		var result liveResult
+		if err := json.Unmarshal([]byte(event.Data), &result); err == nil && result.Service != "" {
+			lr.latest[result.Service] = event
+			lr.lastSeen[result.Service] = result.Time
+		}
+	case "cycle":
+		// The monitor has finished writing the cycle's results
+		model.Invalidate()
+	}
+	lr.broadcast(event)
+}
+
+// statusEvent tells browsers whether the monitor is connected
+func statusEvent(connected bool) sseEvent {
+	return sseEvent{Name: "status", Data: fmt.Sprintf(`{"connected":%t}`, connected)}
+}
+
+func (lr *LiveRelay) setConnected(connected bool) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.connected != connected {
+		lr.connected = connected
+		lr.broadcast(statusEvent(connected))
+	}
+}
+
+// LastSeen returns when each service last reported through the feed
+func (lr *LiveRelay) LastSeen() map[string]time.Time {
+	if lr == nil {
+		return nil
+	}
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	seen := make(map[string]time.Time, len(lr.lastSeen))
+	for service, t := range lr.lastSeen {
+		seen[service] = t
+	}
+	return seen
+}
+
+// ServeHTTP streams relayed events to one browser, starting with the latest result of every service
+func (lr *LiveRelay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	lr.mu.Lock()
+	client := make(chan sseEvent, 512)
+	lr.clients[client] = true
+	snapshot := []sseEvent{statusEvent(lr.connected)}
+	for _, event := range lr.latest {
+		snapshot = append(snapshot, event)
+	}
+	lr.mu.Unlock()
+
+	defer func() {
+		lr.mu.Lock()
+		if lr.clients[client] {
+			delete(lr.clients, client)
+			close(client)
+		}
+		lr.mu.Unlock()
+	}()
+
+	for _, event := range snapshot {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-client:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// SeriesPoint is one downsampled bucket of a latency series. Latency figures
+// cover successful tests only; Failures counts the rest.
+type SeriesPoint struct {
+	Time     time.Time
+	AvgMs    float64
+	MinMs    float64
+	MaxMs    float64
+	P95Ms    float64
+	Count    int
+	Failures int
+}
+
+// Series is the latency history of one service key
+type Series struct {
+	Service  string
+	Location string
+	Provider string
+	TestType string
+	Points   []SeriesPoint
+}
+
+// SeriesResponse is the body of /api/series
+type SeriesResponse struct {
+	From   time.Time
+	To     time.Time
+	Step   string
+	Series []Series
+}
+
+// seriesSteps are the bucket sizes /api/series rounds up to
+var seriesSteps = []time.Duration{
+	30 * time.Second, time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute,
+	15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 3 * time.Hour,
+	6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// maxSeriesPoints caps the number of buckets returned per series
+const maxSeriesPoints = 500
+
+// chooseStep returns the requested step, widened so the span fits in
+// maxSeriesPoints buckets; with no request it aims for about 300 buckets
+func chooseStep(span, requested time.Duration) time.Duration {
+	want := requested
+	if want <= 0 {
+		want = span / 300
+	}
+	if floor := span / maxSeriesPoints; want < floor {
+		want = floor
+	}
+	for _, step := range seriesSteps {
+		if step >= want {
+			return step
+		}
+	}
+	return want.Truncate(24 * time.Hour)
+}
+
+// parseTimeParam reads an RFC 3339 time, unix seconds, "now", or a duration
+// such as "24h" or "7d" meaning that long before now
+func parseTimeParam(value string, now, fallback time.Time) (time.Time, error) {
+	switch {
+	case value == "":
+		return fallback, nil
+	case value == "now":
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if ago, err := parseSpan(value); err == nil {
+		return now.Add(-ago), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// parseSpan parses a Go duration, also accepting whole days such as "30d"
+func parseSpan(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// scanArchive calls fn for every archived record between from and to
+func scanArchive(archiveDir string, from, to time.Time, fn func(ArchiveRecord)) error {
+	files, err := filepath.Glob(filepath.Join(archiveDir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	// Day files are named in local time; widen by a day so zone offsets can't drop records
+	firstDay := from.Add(-24 * time.Hour).Format("2006-01-02")
+	lastDay := to.Add(24 * time.Hour).Format("2006-01-02")
+
+	for _, filename := range files {
+		day := strings.TrimSuffix(filepath.Base(filename), ".jsonl")
+		if day < firstDay || day > lastDay {
+			continue
+		}
+
+		records, err := readArchiveFile(filename)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if record.Timestamp.Before(from) || record.Timestamp.After(to) {
+				continue
+			}
+			fn(record)
+		}
+	}
+	return nil
+}
+
+// seriesBucket collects the samples that fall into one step
+type seriesBucket struct {
+	samples  []float64
+	failures int
+}
+
+// downsample turns buckets keyed by start time into sorted points
+func downsample(buckets map[int64]*seriesBucket, step time.Duration) []SeriesPoint {
+	starts := make([]int64, 0, len(buckets))
+	for start := range buckets {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	points := make([]SeriesPoint, 0, len(starts))
+	for _, start := range starts {
+		bucket := buckets[start]
+		point := SeriesPoint{
+			Time:     time.Unix(0, start*int64(step)),
+			Count:    len(bucket.samples),
+			Failures: bucket.failures,
+		}
+		if len(bucket.samples) > 0 {
+			sort.Float64s(bucket.samples)
+			total := 0.0
+			for _, ms := range bucket.samples {
+				total += ms
+			}
+			point.AvgMs = total / float64(len(bucket.samples))
+			point.MinMs = bucket.samples[0]
+			point.MaxMs = bucket.samples[len(bucket.samples)-1]
+			point.P95Ms = percentile(bucket.samples, 95)
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted)) + 0.999999)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// loadSeries builds downsampled series for the given service keys from the
+// archive, falling back to latency_history.json for services it doesn't hold
+func loadSeries(services []string, from, to time.Time, step time.Duration) ([]Series, error) {
+	buckets := make(map[string]map[int64]*seriesBucket)
+	for _, service := range services {
+		buckets[service] = make(map[int64]*seriesBucket)
+	}
+
+	add := func(service string, timestamp time.Time, responseTime int64, online bool) {
+		serviceBuckets, ok := buckets[service]
+		if !ok {
+			return
+		}
+		start := timestamp.UnixNano() / int64(step)
+		bucket := serviceBuckets[start]
+		if bucket == nil {
+			bucket = &seriesBucket{}
+			serviceBuckets[start] = bucket
+		}
+		if online {
+			bucket.samples = append(bucket.samples, float64(responseTime)/1e6)
+		} else {
+			bucket.failures++
+		}
+	}
+
+	err := scanArchive(archiveDir, from, to, func(record ArchiveRecord) {
+		add(record.Service, record.Timestamp, record.ResponseTime, record.Online)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, service := range services {
+		if len(buckets[service]) == 0 {
+			missing = append(missing, service)
+		}
+	}
+	if len(missing) > 0 {
+		if fileData, err := os.ReadFile("latency_history.json"); err == nil {
+			var history map[string][]DataPoint
+			if err := json.Unmarshal(fileData, &history); err != nil {
+				return nil, err
+			}
+			for _, service := range missing {
+				for _, point := range history[service] {
+					if !point.Timestamp.Before(from) && !point.Timestamp.After(to) {
+						add(service, point.Timestamp, point.ResponseTime, !point.Failed)
+					}
+				}
+			}
+		}
+	}
+
+	series := make([]Series, 0, len(services))
+	for _, service := range services {
+		location, provider, testType := parseServiceName(service)
+		series = append(series, Series{
+			Service:  service,
+			Location: location,
+			Provider: provider,
+			TestType: testType,
+			Points:   downsample(buckets[service], step),
+		})
+	}
+	return series, nil
+}
+
+// seriesAPIHandler serves /api/series?service=...&from=...&to=...&step=...
+// The service parameter may be repeated to compare several series.
+func seriesAPIHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	services := query["service"]
+	if len(services) == 0 {
+		http.Error(w, "missing service parameter", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	to, err := parseTimeParam(query.Get("to"), now, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), now, to.Add(-24*time.Hour))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	var requested time.Duration
+	if value := query.Get("step"); value != "" {
+		if requested, err = parseSpan(value); err != nil || requested <= 0 {
+			http.Error(w, fmt.Sprintf("invalid step %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+	step := chooseStep(to.Sub(from), requested)
+
+	series, err := loadSeries(services, from, to, step)
+	if err != nil {
+		log.Printf("Error loading series: %v", err)
+		http.Error(w, "Error loading series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SeriesResponse{
+		From:   from,
+		To:     to,
+		Step:   step.String(),
+		Series: series,
+	})
+}
+
+// readArchiveFile reads every record from one day file of the archive
+func readArchiveFile(filename string) ([]ArchiveRecord, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []ArchiveRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record ArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // skip a partially written line
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// latestRecordWindow is how far back the dashboard looks for the newest
+// check of each service, so an endpoint not yet checked today keeps its
+// place on the map
+const latestRecordWindow = 7 * 24 * time.Hour
+
+// archiveDayCache keeps a summary of each archive day file and rebuilds it
+// only when the file changes, by size or modification time
+type archiveDayCache[T any] struct {
+	summarize func([]ArchiveRecord) T
+
+	mu    sync.Mutex
+	files map[string]cachedDay[T]
+}
+
+// cachedDay is the summary of one archive file and the file version it was
+// built from
+type cachedDay[T any] struct {
+	stamp   string
+	summary T
+}
+
+// newArchiveDayCache summarizes each day file with summarize
+func newArchiveDayCache[T any](summarize func([]ArchiveRecord) T) *archiveDayCache[T] {
+	return &archiveDayCache[T]{summarize: summarize, files: make(map[string]cachedDay[T])}
+}
+
+// Days returns the summaries of the archive files dated first or later,
+// keyed by date. Files that fell out of that range are forgotten.
+func (c *archiveDayCache[T]) Days(archiveDir, first string) (map[string]T, error) {
+	files, err := filepath.Glob(filepath.Join(archiveDir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	days := make(map[string]T)
+	for _, filename := range files {
+		date := strings.TrimSuffix(filepath.Base(filename), ".jsonl")
+		if date < first {
+			continue
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			continue
+		}
+		stamp := fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+		cached, ok := c.files[filename]
+		if !ok || cached.stamp != stamp {
+			records, err := readArchiveFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			cached = cachedDay[T]{stamp: stamp, summary: c.summarize(records)}
+			c.files[filename] = cached
+		}
+		days[date] = cached.summary
+	}
+
+	for filename := range c.files {
+		if strings.TrimSuffix(filepath.Base(filename), ".jsonl") < first {
+			delete(c.files, filename)
+		}
+	}
+	return days, nil
+}
+
+// recentDays caches the newest record of each service per archive file
+var recentDays = newArchiveDayCache(latestByService)
+
+// latestRecords returns the newest record of each service in the archive
+// files of the last window
+func latestRecords(archiveDir string, now time.Time, window time.Duration) (map[string]ArchiveRecord, error) {
+	days, err := recentDays.Days(archiveDir, now.Add(-window).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]ArchiveRecord)
+	for _, day := range days {
+		for service, record := range day {
+			if prev, ok := latest[service]; !ok || record.Timestamp.After(prev.Timestamp) {
+				latest[service] = record
+			}
+		}
+	}
+	return latest, nil
+}
+
+// latestByService returns the most recent record of each service
+func latestByService(records []ArchiveRecord) map[string]ArchiveRecord {
+	latest := make(map[string]ArchiveRecord)
+	for _, record := range records {
+		if prev, ok := latest[record.Service]; !ok || record.Timestamp.After(prev.Timestamp) {
+			latest[record.Service] = record
+		}
+	}
+	return latest
+}
+
+// latestDiagnoses returns the most recent verdict for each endpoint
+func latestDiagnoses(records []ArchiveRecord) []DiagnosisSummary {
+	latest := make(map[string]ArchiveRecord)
+	for _, record := range records {
+		if record.Diagnosis == "" {
+			continue
+		}
+		key := record.Location + "|" + record.Provider
+		if prev, ok := latest[key]; !ok || record.Timestamp.After(prev.Timestamp) {
+			latest[key] = record
+		}
+	}
+
+	diagnoses := make([]DiagnosisSummary, 0, len(latest))
+	for _, record := range latest {
+		diagnoses = append(diagnoses, DiagnosisSummary{
+			Location:  record.Location,
+			Provider:  record.Provider,
+			Verdict:   record.Diagnosis,
+			Reason:    record.DiagnosisReason,
+			Timestamp: record.Timestamp.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	// Problems first, then alphabetical
+	sort.Slice(diagnoses, func(i, j int) bool {
+		iHealthy := diagnoses[i].Verdict == "HEALTHY"
+		jHealthy := diagnoses[j].Verdict == "HEALTHY"
+		if iHealthy != jHealthy {
+			return !iHealthy
+		}
+		return diagnoses[i].Location < diagnoses[j].Location
+	})
+
+	return diagnoses
+}
+
+func parseServiceName(name string) (location, provider, testType string) {
+	testType = "other"
+	provider = "N/A"
+	location = name
+
+	if idx := strings.LastIndex(name, " - "); idx >= 0 {
+		// A repeated probe carries its target, as in "TCP:8443"
+		testType, _, _ = strings.Cut(strings.ToLower(name[idx+3:]), ":")
+		name = name[:idx]
+	}
+
+	if start := strings.Index(name, "["); start >= 0 {
+		if end := strings.Index(name[start:], "]"); end >= 0 {
+			provider = name[start+1 : start+end]
+			location = strings.TrimSpace(name[:start])
+		}
+	}
+
+	return location, provider, testType
+}
+
+// staticAsset is an embedded file with its precomputed ETag
+type staticAsset struct {
+	data []byte
+	etag string
+}
+
+// staticAssets holds every file under web/static, keyed by name
+var staticAssets = loadStaticAssets()
+
+func loadStaticAssets() map[string]staticAsset {
+	assets := make(map[string]staticAsset)
+	err := fs.WalkDir(staticFS, "web/static", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := staticFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		assets[strings.TrimPrefix(name, "web/static/")] = staticAsset{
+			data: data,
+			etag: `"` + hex.EncodeToString(sum[:8]) + `"`,
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Could not load embedded assets: %v", err)
+	}
+	return assets
+}
+
+// assetURL returns a versioned URL for an embedded asset, so browsers can
+// cache it forever and still pick up a new build. The URL is relative to the
+// dashboard's root, which keeps it working under a base path.
+func assetURL(name string) string {
+	asset, ok := staticAssets[name]
+	if !ok {
+		return "static/" + name
+	}
+	return "static/" + name + "?v=" + strings.Trim(asset.etag, `"`)
+}
+
+// staticHandler serves embedded assets with ETags. Versioned URLs are
+// cached for a year; anything else is revalidated.
+func staticHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	asset, ok := staticAssets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", asset.etag)
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, no-cache")
+	}
+	// ServeContent answers If-None-Match with 304 Not Modified
+	http.ServeContent(w, r, path.Base(name), time.Time{}, bytes.NewReader(asset.data))
+}
+
+// svgEscape escapes text for use inside SVG markup
+func svgEscape(text string) string {
+	return template.HTMLEscapeString(text)
+}
+
+// niceCeiling rounds a maximum up to 1, 2 or 5 times a power of ten
+func niceCeiling(max float64) float64 {
+	if max <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(max)))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		if factor*magnitude >= max {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// barsSVG renders a bar chart as inline SVG for browsers without JavaScript
+func barsSVG(bars []ChartBar, horizontal bool) template.HTML {
+	if len(bars) == 0 {
+		return ""
+	}
+	max := 0.0
+	for _, bar := range bars {
+		max = math.Max(max, bar.Value)
+	}
+	top := niceCeiling(max)
+
+	var b strings.Builder
+	const width = 600.0
+	if horizontal {
+		const left, right, row = 130.0, 560.0, 28.0
+		height := float64(len(bars))*row + 10
+		fmt.Fprintf(&b, `<svg class="chart-svg" viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="12">`, width, height)
+		for i, bar := range bars {
+			y := 5 + float64(i)*row
+			w := bar.Value / top * (right - left)
+			fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" text-anchor="end" fill="#666">%s</text>`, left-8, y+row/2+4, svgEscape(bar.Label))
+			fmt.Fprintf(&b, `<rect x="%.0f" y="%.1f" width="%.1f" height="%.1f" fill="#764ba2"><title>%s: %.0fms</title></rect>`, left, y+4, w, row-8, svgEscape(bar.Label), bar.Value)
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="#333">%.0fms</text>`, left+w+4, y+row/2+4, bar.Value)
+		}
+	} else {
+		const left, bottom, height = 40.0, 220.0, 320.0
+		band := (width - left - 10) / float64(len(bars))
+		fmt.Fprintf(&b, `<svg class="chart-svg" viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="11">`, width, height)
+		for i := 0; i <= 4; i++ {
+			value := top * float64(i) / 4
+			y := bottom - value/top*(bottom-10)
+			fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#e0e0e0"/><text x="%.0f" y="%.1f" text-anchor="end" fill="#666">%.0f</text>`,
+				left, width-10, y, y, left-4, y+4, value)
+		}
+		for i, bar := range bars {
+			x := left + float64(i)*band + band*0.15
+			h := bar.Value / top * (bottom - 10)
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#667eea"><title>%s: %.0fms</title></rect>`, x, bottom-h, band*0.7, h, svgEscape(bar.Label), bar.Value)
+			fmt.Fprintf(&b, `<text transform="translate(%.1f %.0f) rotate(-45)" text-anchor="end" fill="#666">%s</text>`, x+band*0.35, bottom+12, svgEscape(bar.Label))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// layerColors match the PING/DNS/HTTP colors used by the JavaScript charts
+var layerColors = map[string]string{"ping": "#2196f3", "dns": "#4caf50", "http": "#ff9800"}
+
+// seriesSVG renders latency series as an SVG line chart
+func seriesSVG(series []Series, from, to time.Time) string {
+	const width, height = 800.0, 300.0
+	const left, right, top, bottom = 50.0, 790.0, 30.0, 270.0
+
+	max := 0.0
+	for _, s := range series {
+		for _, point := range s.Points {
+			if point.Count > 0 {
+				max = math.Max(max, point.AvgMs)
+			}
+		}
+	}
+	ceiling := niceCeiling(max)
+	span := to.Sub(from)
+	px := func(t time.Time) float64 { return left + float64(t.Sub(from))/float64(span)*(right-left) }
+	py := func(ms float64) float64 { return bottom - ms/ceiling*(bottom-top) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="11">`, width, height)
+	b.WriteString(`<rect width="100%" height="100%" fill="white"/>`)
+	for i := 0; i <= 4; i++ {
+		value := ceiling * float64(i) / 4
+		fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#e0e0e0"/><text x="%.0f" y="%.1f" text-anchor="end" fill="#666">%.0f</text>`,
+			left, right, py(value), py(value), left-4, py(value)+4, value)
+	}
+	layout := "15:04"
+	if span > 48*time.Hour {
+		layout = "Jan 2"
+	}
+	for i := 0; i <= 6; i++ {
+		t := from.Add(span * time.Duration(i) / 6)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.0f" text-anchor="middle" fill="#666">%s</text>`, px(t), bottom+16, t.Format(layout))
+	}
+
+	for i, s := range series {
+		color := "#667eea"
+		if len(series) > 1 && layerColors[s.TestType] != "" {
+			color = layerColors[s.TestType]
+		}
+		if len(series) > 1 {
+			fmt.Fprintf(&b, `<rect x="%.0f" y="8" width="12" height="12" fill="%s"/><text x="%.0f" y="18" fill="#666">%s</text>`,
+				left+float64(i)*80, color, left+float64(i)*80+16, strings.ToUpper(s.TestType))
+		}
+
+		// Break the line where a bucket had no successful checks
+		var path strings.Builder
+		drawing := false
+		for _, point := range s.Points {
+			if point.Count == 0 {
+				drawing = false
+				continue
+			}
+			command := "L"
+			if !drawing {
+				command = "M"
+			}
+			fmt.Fprintf(&path, "%s%.1f,%.1f ", command, px(point.Time), py(point.AvgMs))
+			drawing = true
+		}
+		if path.Len() > 0 {
+			fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.TrimSpace(path.String()), color)
+		}
+		for _, point := range s.Points {
+			if point.Failures > 0 {
+				y := bottom
+				if point.Count > 0 {
+					y = py(point.AvgMs)
+				}
+				fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4" fill="#f44336"><title>%d failed</title></circle>`, px(point.Time), y, point.Failures)
+			}
+		}
+	}
+	if max == 0 {
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" text-anchor="middle" fill="#666">No data in this range</text>`, (left+right)/2, (top+bottom)/2)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// seriesSVGHandler serves /chart/series.svg, taking the same parameters as /api/series
+func seriesSVGHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	services := query["service"]
+	if len(services) == 0 {
+		http.Error(w, "missing service parameter", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	to, err := parseTimeParam(query.Get("to"), now, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), now, to.Add(-24*time.Hour))
+	if err != nil || !from.Before(to) {
+		http.Error(w, "invalid time range", http.StatusBadRequest)
+		return
+	}
+
+	series, err := loadSeries(services, from, to, chooseStep(to.Sub(from), 0))
+	if err != nil {
+		log.Printf("Error loading series: %v", err)
+		http.Error(w, "Error loading series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, seriesSVG(series, from, to))
+}
+
+// endpointID turns a location and provider into the id used by /endpoint/{id}
+func endpointID(location, provider string) string {
+	name := location
+	if provider != "" && provider != "N/A" {
+		name += " " + provider
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// HistogramBin counts successful checks in one latency band
+type HistogramBin struct {
+	Label string
+	Count int
+}
+
+// histogramEdges are the upper bounds, in ms, of the latency histogram bands
+var histogramEdges = []float64{10, 25, 50, 100, 250, 500, 1000, 2500}
+
+// latencyHistogram sorts latencies into the histogram bands
+func latencyHistogram(samples []float64) []HistogramBin {
+	bins := make([]HistogramBin, len(histogramEdges)+1)
+	lower := 0.0
+	for i, edge := range histogramEdges {
+		bins[i].Label = fmt.Sprintf("%.0f-%.0f", lower, edge)
+		lower = edge
+	}
+	bins[len(histogramEdges)].Label = fmt.Sprintf("%.0f+", lower)
+
+	for _, ms := range samples {
+		i := sort.SearchFloat64s(histogramEdges, ms)
+		if i < len(histogramEdges) && ms == histogramEdges[i] {
+			i++
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+// LayerDetail is the current status and latency distribution of one test layer
+type LayerDetail struct {
+	Service       string
+	TestType      string
+	Status        string // UP, DOWN, DEGRADED or UNKNOWN
+	LastSeen      time.Time
+	LatestMs      float64
+	ResolvedIP    string
+	StatusCode    int
+	Trend         string
+	Error         string
+	ErrorCategory string
+	Count         int // successful checks in range
+	Failures      int
+	Availability  float64
+	AvgMs         float64
+	MinMs         float64
+	P50Ms         float64
+	P90Ms         float64
+	P95Ms         float64
+	P99Ms         float64
+	MaxMs         float64
+	Histogram     []HistogramBin
+}
+
+// ErrorEntry is one failed check on the endpoint page
+type ErrorEntry struct {
+	Time      time.Time
+	TestType  string
+	Category  string
+	Error     string
+	Diagnosis string
+}
+
+// IPChange records the resolved address of an endpoint changing
+type IPChange struct {
+	Time     time.Time
+	TestType string
+	From     string
+	To       string
+}
+
+// CertificateInfo is the certificate seen by the latest TLS probe
+type CertificateInfo struct {
+	Subject   string
+	Issuer    string
+	Version   string
+	NotAfter  string
+	DaysLeft  float64
+	CheckedAt time.Time
+}
+
+// EndpointSLO is an SLO that covers the endpoint, with the endpoint's own share
+type EndpointSLO struct {
+	SLOStatus
+	EndpointGood  int
+	EndpointTotal int
+	EndpointSLI   float64
+}
+
+// EndpointDetail is everything shown on /endpoint/{id}
+type EndpointDetail struct {
+	ID          string
+	Location    string
+	Provider    string
+	Region      string
+	Hostname    string
+	Labels      map[string]string
+	LastUpdate  string
+	Range       string
+	Ranges      []string
+	Layers      []LayerDetail
+	Errors      []ErrorEntry
+	IPChanges   []IPChange
+	Certificate *CertificateInfo
+	SLOs        []EndpointSLO
+	Services    []string
+}
+
+// maxEndpointErrors and maxIPChanges limit the lists on the endpoint page
+const (
+	maxEndpointErrors = 25
+	maxIPChanges      = 20
+)
+
+// categorizeError gives records archived before error categories were
+// stored a coarse category from their error text
+func categorizeError(record ArchiveRecord) string {
+	message := strings.ToLower(record.Error)
+	switch {
+	case record.Cycle == "LOCAL_OUTAGE":
+		return "local_network"
+	case record.TestType == "DNS", strings.Contains(message, "no such host"), strings.Contains(message, "dns"):
+		return "dns"
+	case strings.Contains(message, "timeout"), strings.Contains(message, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(message, "connection refused"):
+		return "connection_refused"
+	case strings.Contains(message, "connection reset"), strings.Contains(message, "eof"):
+		return "connection_reset"
+	case strings.Contains(message, "tls"), strings.Contains(message, "x509"), strings.Contains(message, "certificate"):
+		return "tls"
+	case record.StatusCode >= 400:
+		return "http_status"
+	case record.TestType == "PING":
+		return "no_reply"
+	}
+	return "other"
+}
+
+// recordStatus is the status word for an archived result
+func recordStatus(record ArchiveRecord) string {
+	switch {
+	case record.Degraded:
+		return "DEGRADED"
+	case record.Online:
+		return "UP"
+	}
+	return "DOWN"
+}
+
+// loadEndpointDetail builds the endpoint page from the archive, falling back
+// to latency_history.json for services the archive doesn't hold
+func loadEndpointDetail(id string, from, to time.Time) (*EndpointDetail, error) {
+	data, err := model.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &EndpointDetail{ID: id}
+	services := make(map[string]bool)
+	for _, endpoint := range data.Summary {
+		if endpoint.ID == id {
+			services[endpoint.Name] = true
+			detail.Services = append(detail.Services, endpoint.Name)
+			detail.Location, detail.Provider = endpoint.Location, endpoint.Provider
+		}
+	}
+	if len(detail.Services) == 0 {
+		return nil, nil
+	}
+
+	var records []ArchiveRecord
+	err = scanArchive(archiveDir, from, to, func(record ArchiveRecord) {
+		if services[record.Service] {
+			records = append(records, record)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+
+	// Services the archive doesn't hold are filled from latency_history.json
+	archived := make(map[string]bool)
+	for _, record := range records {
+		archived[record.Service] = true
+	}
+	if fileData, err := os.ReadFile("latency_history.json"); err == nil {
+		var history map[string][]DataPoint
+		if err := json.Unmarshal(fileData, &history); err == nil {
+			for _, service := range detail.Services {
+				if archived[service] {
+					continue
+				}
+				_, _, testType := parseServiceName(service)
+				for _, point := range history[service] {
+					if point.Timestamp.Before(from) || point.Timestamp.After(to) {
+						continue
+					}
+					records = append(records, ArchiveRecord{
+						Timestamp:    point.Timestamp,
+						Service:      service,
+						Location:     detail.Location,
+						Provider:     detail.Provider,
+						TestType:     strings.ToUpper(testType),
+						Online:       !point.Failed,
+						ResponseTime: point.ResponseTime,
+					})
+				}
+			}
+			sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+		}
+	}
+
+	latest := make(map[string]ArchiveRecord)
+	samples := make(map[string][]float64)
+	failures := make(map[string]int)
+	// Ping, DNS and HTTP checks can resolve to different addresses of the
+	// same hostname, so changes are tracked per service
+	lastIP := make(map[string]string)
+	for _, record := range records {
+		latest[record.Service] = record
+		if record.Region != "" {
+			detail.Region = record.Region
+		}
+		if record.Hostname != "" {
+			detail.Hostname = record.Hostname
+		}
+		if len(record.Labels) > 0 {
+			detail.Labels = record.Labels
+		}
+
+		if record.Online {
+			samples[record.Service] = append(samples[record.Service], float64(record.ResponseTime)/1e6)
+		} else {
+			failures[record.Service]++
+			category := record.ErrorCategory
+			if category == "" {
+				category = categorizeError(record)
+			}
+			detail.Errors = append(detail.Errors, ErrorEntry{
+				Time:      record.Timestamp,
+				TestType:  record.TestType,
+				Category:  category,
+				Error:     record.Error,
+				Diagnosis: record.Diagnosis,
+			})
+		}
+
+		if record.ResolvedIP != "" {
+			if last := lastIP[record.Service]; last != "" && record.ResolvedIP != last {
+				detail.IPChanges = append(detail.IPChanges, IPChange{
+					Time: record.Timestamp, TestType: record.TestType, From: last, To: record.ResolvedIP,
+				})
+			}
+			lastIP[record.Service] = record.ResolvedIP
+		}
+
+		if details := record.Details; details["tls_not_after"] != "" {
+			detail.Certificate = &CertificateInfo{
+				Subject:   details["tls_subject"],
+				Issuer:    details["tls_issuer"],
+				Version:   details["tls_version"],
+				NotAfter:  details["tls_not_after"],
+				DaysLeft:  record.Fields["tls_days_left"],
+				CheckedAt: record.Timestamp,
+			}
+		}
+	}
+
+	for _, service := range detail.Services {
+		_, _, testType := parseServiceName(service)
+		layer := LayerDetail{Service: service, TestType: strings.ToUpper(testType), Status: "UNKNOWN"}
+		if record, ok := latest[service]; ok {
+			layer.Status = recordStatus(record)
+			layer.LastSeen = record.Timestamp
+			layer.LatestMs = float64(record.ResponseTime) / 1e6
+			layer.ResolvedIP = record.ResolvedIP
+			layer.StatusCode = record.StatusCode
+			layer.Trend = record.Trend
+			layer.Error = record.Error
+			layer.ErrorCategory = record.ErrorCategory
+			if !record.Online && layer.ErrorCategory == "" {
+				layer.ErrorCategory = categorizeError(record)
+			}
+		}
+
+		values := samples[service]
+		sort.Float64s(values)
+		layer.Count = len(values)
+		layer.Failures = failures[service]
+		if total := layer.Count + layer.Failures; total > 0 {
+			layer.Availability = float64(layer.Count) / float64(total) * 100
+		}
+		if len(values) > 0 {
+			sum := 0.0
+			for _, ms := range values {
+				sum += ms
+			}
+			layer.AvgMs = sum / float64(len(values))
+			layer.MinMs = values[0]
+			layer.MaxMs = values[len(values)-1]
+			layer.P50Ms = percentile(values, 50)
+			layer.P90Ms = percentile(values, 90)
+			layer.P95Ms = percentile(values, 95)
+			layer.P99Ms = percentile(values, 99)
+		}
+		layer.Histogram = latencyHistogram(values)
+		detail.Layers = append(detail.Layers, layer)
+	}
+
+	// Newest first
+	sort.Slice(detail.Errors, func(i, j int) bool { return detail.Errors[i].Time.After(detail.Errors[j].Time) })
+	if len(detail.Errors) > maxEndpointErrors {
+		detail.Errors = detail.Errors[:maxEndpointErrors]
+	}
+	for i, j := 0, len(detail.IPChanges)-1; i < j; i, j = i+1, j-1 {
+		detail.IPChanges[i], detail.IPChanges[j] = detail.IPChanges[j], detail.IPChanges[i]
+	}
+	if len(detail.IPChanges) > maxIPChanges {
+		detail.IPChanges = detail.IPChanges[:maxIPChanges]
+	}
+
+	// SLOs covering any layer, with this endpoint's own share over the range.
+	// Like the monitor, maintenance and local outages don't count.
+	statuses, err := loadSLOStatus()
+	if err != nil {
+		log.Printf("Error loading SLO status: %v", err)
+	}
+	for _, status := range statuses {
+		slo := EndpointSLO{SLOStatus: status}
+		covered := false
+		for _, record := range records {
+			if !status.Match.Matches(record) {
+				continue
+			}
+			covered = true
+			if record.Maintenance != "" || record.Cycle == "LOCAL_OUTAGE" {
+				continue
+			}
+			slo.EndpointTotal++
+			if record.Online && (status.Objective != "latency" || float64(record.ResponseTime)/1e6 < status.ThresholdMs) {
+				slo.EndpointGood++
+			}
+		}
+		if !covered {
+			continue
+		}
+		if slo.EndpointTotal > 0 {
+			slo.EndpointSLI = float64(slo.EndpointGood) / float64(slo.EndpointTotal) * 100
+		}
+		detail.SLOs = append(detail.SLOs, slo)
+	}
+
+	return detail, nil
+}
+
+// histogramSVG renders a latency histogram as inline SVG
+func histogramSVG(bins []HistogramBin) template.HTML {
+	const width, height = 360.0, 160.0
+	const left, bottom = 30.0, 130.0
+
+	max := 0
+	for _, bin := range bins {
+		if bin.Count > max {
+			max = bin.Count
+		}
+	}
+	if max == 0 {
+		return template.HTML(`<p class="empty">No successful checks in this range</p>`)
+	}
+	ceiling := niceCeiling(float64(max))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart-svg" viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="9">`, width, height)
+	fmt.Fprintf(&b, `<text x="%.0f" y="10" text-anchor="end" fill="#666">%.0f</text>`, left-4, ceiling)
+	band := (width - left - 5) / float64(len(bins))
+	for i, bin := range bins {
+		x := left + float64(i)*band
+		h := float64(bin.Count) / ceiling * (bottom - 10)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#667eea"><title>%sms: %d checks</title></rect>`,
+			x+1, bottom-h, band-2, h, bin.Label, bin.Count)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.0f" text-anchor="middle" fill="#666">%s</text>`, x+band/2, bottom+12, bin.Label)
+	}
+	fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.0f" y2="%.0f" stroke="#999"/><text x="%.0f" y="%.0f" text-anchor="middle" fill="#666">ms</text></svg>`,
+		left, width-5, bottom, bottom, (left+width)/2, height-2)
+	return template.HTML(b.String())
+}
+
+// endpointHandler serves /endpoint/{id}?range=24h
+func endpointHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/endpoint/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	ranges := []string{"1h", "24h", "7d", "30d"}
+	rangeName := r.URL.Query().Get("range")
+	span, err := parseSpan(rangeName)
+	if err != nil || span <= 0 {
+		rangeName, span = "7d", 7*24*time.Hour
+	}
+	now := time.Now()
+
+	detail, err := loadEndpointDetail(id, now.Add(-span), now)
+	if err != nil {
+		log.Printf("Error loading endpoint %s: %v", id, err)
+		http.Error(w, "Error loading data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.NotFound(w, r)
+		return
+	}
+	detail.LastUpdate = now.Format("2006-01-02 15:04:05")
+	detail.Range = rangeName
+	detail.Ranges = ranges
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := endpointTemplate.Execute(w, detail); err != nil {
+		log.Printf("Template execute error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// Heatmap is a grid of p95 latency with one row per location
+type Heatmap struct {
+	Kind    string // "hour" (hour of day in the dashboard's time zone) or "layer"
+	Columns []string
+	Rows    []HeatmapRow
+}
+
+// HeatmapRow is one location of a heatmap
+type HeatmapRow struct {
+	ID       string
+	Name     string // "Paris, FR [AWS]"
+	Location string
+	Provider string
+	Cells    []HeatmapCell
+}
+
+// HeatmapCell summarizes the checks that fall into one cell; latencies are
+// over successful checks only
+type HeatmapCell struct {
+	P95Ms    float64
+	AvgMs    float64
+	Count    int
+	Failures int
+}
+
+// heatmapLayers orders the layer columns; other probe types follow alphabetically
+var heatmapLayers = []string{"PING", "DNS", "HTTP"}
+
+// heatmapData is rendered by heatmap.html
+type heatmapData struct {
+	LastUpdate string
+	Range      string
+	Ranges     []string
+	Provider   string
+	Providers  []string
+	Zone       string
+	Heatmaps   []Heatmap // by hour of day, then by layer
+}
+
+// newHeatmapCell computes the statistics of one bucket
+func newHeatmapCell(bucket *seriesBucket) HeatmapCell {
+	if bucket == nil {
+		return HeatmapCell{}
+	}
+	cell := HeatmapCell{Count: len(bucket.samples), Failures: bucket.failures}
+	if len(bucket.samples) > 0 {
+		sort.Float64s(bucket.samples)
+		total := 0.0
+		for _, ms := range bucket.samples {
+			total += ms
+		}
+		cell.AvgMs = total / float64(len(bucket.samples))
+		cell.P95Ms = percentile(bucket.samples, 95)
+	}
+	return cell
+}
+
+// loadHeatmaps builds the location × hour-of-day and location × layer
+// heatmaps from the archive. An empty provider includes every provider;
+// home network endpoints are left out. providers lists every provider seen.
+func loadHeatmaps(from, to time.Time, provider string) (hours, layers Heatmap, providers []string, err error) {
+	type locationBuckets struct {
+		location, provider string
+		hours              [24]*seriesBucket
+		layers             map[string]*seriesBucket
+	}
+	locations := make(map[string]*locationBuckets)
+	seenProviders := make(map[string]bool)
+	seenLayers := make(map[string]bool)
+
+	add := func(bucket **seriesBucket, record ArchiveRecord) {
+		if *bucket == nil {
+			*bucket = &seriesBucket{}
+		}
+		if record.Online {
+			(*bucket).samples = append((*bucket).samples, float64(record.ResponseTime)/1e6)
+		} else {
+			(*bucket).failures++
+		}
+	}
+
+	err = scanArchive(archiveDir, from, to, func(record ArchiveRecord) {
+		if record.Provider == "Home" || record.Maintenance != "" {
+			return
+		}
+		seenProviders[record.Provider] = true
+		if provider != "" && !strings.EqualFold(record.Provider, provider) {
+			return
+		}
+		name := record.Location + " [" + record.Provider + "]"
+		buckets := locations[name]
+		if buckets == nil {
+			buckets = &locationBuckets{location: record.Location, provider: record.Provider, layers: make(map[string]*seriesBucket)}
+			locations[name] = buckets
+		}
+		add(&buckets.hours[record.Timestamp.In(time.Local).Hour()], record)
+		layer := buckets.layers[record.TestType]
+		add(&layer, record)
+		buckets.layers[record.TestType] = layer
+		seenLayers[record.TestType] = true
+	})
+	if err != nil {
+		return hours, layers, nil, err
+	}
+
+	hours = Heatmap{Kind: "hour"}
+	for hour := 0; hour < 24; hour++ {
+		hours.Columns = append(hours.Columns, fmt.Sprintf("%02d", hour))
+	}
+	layers = Heatmap{Kind: "layer"}
+	for _, layer := range heatmapLayers {
+		if seenLayers[layer] {
+			layers.Columns = append(layers.Columns, layer)
+			delete(seenLayers, layer)
+		}
+	}
+	layers.Columns = append(layers.Columns, sortedKeys(seenLayers)...)
+
+	names := make([]string, 0, len(locations))
+	for name := range locations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buckets := locations[name]
+		row := HeatmapRow{ID: endpointID(buckets.location, buckets.provider), Name: name, Location: buckets.location, Provider: buckets.provider}
+		hourRow, layerRow := row, row
+		for _, bucket := range buckets.hours {
+			hourRow.Cells = append(hourRow.Cells, newHeatmapCell(bucket))
+		}
+		for _, layer := range layers.Columns {
+			layerRow.Cells = append(layerRow.Cells, newHeatmapCell(buckets.layers[layer]))
+		}
+		hours.Rows = append(hours.Rows, hourRow)
+		layers.Rows = append(layers.Rows, layerRow)
+	}
+
+	return hours, layers, sortedKeys(seenProviders), nil
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// heatColor shades a p95 latency from green (10ms or less) to red (1s or
+// more) on a log scale
+func heatColor(ms float64) template.CSS {
+	position := (math.Log10(math.Max(ms, 10)) - 1) / 2
+	hue := 120 * (1 - math.Min(position, 1))
+	return template.CSS(fmt.Sprintf("hsl(%.0f, 70%%, 72%%)", hue))
+}
+
+// writeHeatmapCSV writes one heatmap as CSV, one p95 value per cell and an
+// empty cell where there were no successful checks
+func writeHeatmapCSV(w io.Writer, heatmap Heatmap) error {
+	writer := csv.NewWriter(w)
+	header := []string{"Location", "Provider"}
+	for _, column := range heatmap.Columns {
+		if heatmap.Kind == "hour" {
+			column += ":00"
+		}
+		header = append(header, column+" p95 (ms)")
+	}
+	writer.Write(header)
+	for _, row := range heatmap.Rows {
+		record := []string{row.Location, row.Provider}
+		for _, cell := range row.Cells {
+			value := ""
+			if cell.Count > 0 {
+				value = strconv.FormatFloat(cell.P95Ms, 'f', 1, 64)
+			}
+			record = append(record, value)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// heatmapAPIHandler serves /api/heatmap?kind=hour|layer&provider=...&from=...&to=...&format=json|csv
+// Without kind, the JSON response holds both heatmaps.
+func heatmapAPIHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := time.Now()
+	to, err := parseTimeParam(query.Get("to"), now, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), now, to.Add(-7*24*time.Hour))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	kind := query.Get("kind")
+	format := query.Get("format")
+	if kind != "" && kind != "hour" && kind != "layer" {
+		http.Error(w, fmt.Sprintf("invalid kind %q, want hour or layer", kind), http.StatusBadRequest)
+		return
+	}
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, fmt.Sprintf("invalid format %q, want json or csv", format), http.StatusBadRequest)
+		return
+	}
+	if format == "csv" && kind == "" {
+		http.Error(w, "format=csv needs kind=hour or kind=layer", http.StatusBadRequest)
+		return
+	}
+
+	provider := query.Get("provider")
+	hours, layers, _, err := loadHeatmaps(from, to, provider)
+	if err != nil {
+		log.Printf("Error loading heatmaps: %v", err)
+		http.Error(w, "Error loading heatmaps", http.StatusInternalServerError)
+		return
+	}
+	heatmap := hours
+	if kind == "layer" {
+		heatmap = layers
+	}
+
+	if format == "csv" {
+		filename := "latency_heatmap_" + kind + ".csv"
+		if provider != "" {
+			filename = "latency_heatmap_" + kind + "_" + endpointID(provider, "") + ".csv"
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if err := writeHeatmapCSV(w, heatmap); err != nil {
+			log.Printf("Error writing heatmap CSV: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if kind != "" {
+		json.NewEncoder(w).Encode(heatmap)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		From, To      time.Time
+		Provider      string
+		Hours, Layers Heatmap
+	}{from, to, provider, hours, layers})
+}
+
+// heatmapHandler serves the /heatmap page
+func heatmapHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := heatmapData{
+		LastUpdate: time.Now().Format("2006-01-02 15:04:05"),
+		Ranges:     []string{"24h", "7d", "30d", "90d"},
+		Range:      query.Get("range"),
+		Provider:   query.Get("provider"),
+		Zone:       time.Now().Format("MST"),
+	}
+	span, err := parseSpan(data.Range)
+	if err != nil || span <= 0 {
+		data.Range, span = "7d", 7*24*time.Hour
+	}
+	now := time.Now()
+
+	hours, layers, providers, err := loadHeatmaps(now.Add(-span), now, data.Provider)
+	if err != nil {
+		log.Printf("Error loading heatmaps: %v", err)
+		http.Error(w, "Error loading data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data.Heatmaps = []Heatmap{hours, layers}
+	data.Providers = providers
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := heatmapTemplate.Execute(w, data); err != nil {
+		log.Printf("Template execute error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// StatusPageConfig is the StatusPage part of the Dashboard section. Without
+// Components, endpoints are grouped by provider and continent.
+type StatusPageConfig struct {
+	Title      string
+	URL        string // public address of the status page, used in feeds; derived from the request when empty
+	Public     bool   // serve only the status page, feeds and the notes API
+	NotesFile  string // manual incident notes, default status_notes.json
+	Components []ComponentConfig
+}
+
+// ComponentConfig groups endpoints into one line of the status page. An
+// endpoint belongs to every component with a matching entry in Match.
+type ComponentConfig struct {
+	Name  string
+	Match []EndpointMatcher
+}
+
+// statusDays is how far back the uptime bars and incident history reach
+const statusDays = 90
+
+// An outage shorter than minOutageFailures failed checks is a blip, not an
+// incident, and outages less than outageMergeGap apart are one incident
+const (
+	minOutageFailures  = 2
+	outageMergeGap     = 10 * time.Minute
+	maxStatusIncidents = 50
+)
+
+// Component statuses, from best to worst
+var componentStatuses = []string{"operational", "unknown", "maintenance", "degraded_performance", "partial_outage", "major_outage"}
+
+// statusRank orders component statuses so the worst one wins
+func statusRank(status string) int {
+	for i, s := range componentStatuses {
+		if s == status {
+			return i
+		}
+	}
+	return 0
+}
+
+// statusLabel names a component status for people
+func statusLabel(status string) string {
+	switch status {
+	case "operational":
+		return "Operational"
+	case "maintenance":
+		return "Under Maintenance"
+	case "degraded_performance":
+		return "Degraded Performance"
+	case "partial_outage":
+		return "Partial Outage"
+	case "major_outage":
+		return "Major Outage"
+	}
+	return "No Data"
+}
+
+// StatusReport is everything the status page and its feeds show
+type StatusReport struct {
+	Title      string
+	Updated    time.Time
+	Status     string // the worst component status
+	Components []ComponentStatus
+	Incidents  []Incident // newest first
+}
+
+// ComponentStatus is the current state and uptime history of one component
+type ComponentStatus struct {
+	Name     string
+	Status   string
+	Failing  []string // services down or degraded in their latest check
+	UptimePc float64  // over the days with data
+	Days     []UptimeDay
+}
+
+// UptimeDay is one bar of a component's uptime history
+type UptimeDay struct {
+	Date      string
+	Good      int // successful checks, degraded included
+	Total     int // checks outside maintenance and local network outages
+	UptimePc  float64
+	Incidents int
+}
+
+// Incident is an outage found in the archive, or a notice posted through
+// the notes API, with the notes posted about it
+type Incident struct {
+	ID        string
+	Title     string
+	Component string
+	Severity  string // partial_outage, major_outage or notice
+	Start     time.Time
+	End       time.Time // last failure or recovery; zero for an open notice
+	Ongoing   bool
+	Failures  int
+	Services  []string
+	Notes     []StatusNote // oldest first
+}
+
+// Updated is when the incident last changed
+func (i Incident) Updated() time.Time {
+	updated := i.Start
+	if i.End.After(updated) {
+		updated = i.End
+	}
+	if n := len(i.Notes); n > 0 && i.Notes[n-1].Time.After(updated) {
+		updated = i.Notes[n-1].Time
+	}
+	return updated
+}
+
+// StatusNote is a manual update on an incident, or a standalone notice when
+// Incident is empty
+type StatusNote struct {
+	ID        string
+	Time      time.Time
+	Incident  string `json:",omitempty"` // ID of the incident or notice it updates
+	Component string `json:",omitempty"`
+	Title     string `json:",omitempty"` // required for a notice
+	Status    string `json:",omitempty"` // investigating, identified, monitoring or resolved
+	Message   string
+	Author    string `json:",omitempty"`
+}
+
+// noteStatuses are the stages an incident note may report
+var noteStatuses = []string{"investigating", "identified", "monitoring", "resolved"}
+
+// NoteStore keeps the manual incident notes in a JSON file
+type NoteStore struct {
+	mu       sync.Mutex
+	filename string
+	notes    []StatusNote
+}
+
+// loadNoteStore reads the notes file; a missing file is an empty store
+func loadNoteStore(filename string) (*NoteStore, error) {
+	store := &NoteStore{filename: filename}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.notes); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return store, nil
+}
+
+// List returns the notes, oldest first
+func (s *NoteStore) List() []StatusNote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StatusNote(nil), s.notes...)
+}
+
+// Add stores a note, giving it an ID and, if unset, the current time
+func (s *NoteStore) Add(note StatusNote) (StatusNote, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return note, err
+	}
+	note.ID = hex.EncodeToString(id)
+	if note.Time.IsZero() {
+		note.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notes := append(append([]StatusNote(nil), s.notes...), note)
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Time.Before(notes[j].Time) })
+	if err := s.save(notes); err != nil {
+		return note, err
+	}
+	s.notes = notes
+	return note, nil
+}
+
+// Delete removes a note, reporting whether it existed
+func (s *NoteStore) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var notes []StatusNote
+	for _, note := range s.notes {
+		if note.ID != id {
+			notes = append(notes, note)
+		}
+	}
+	if len(notes) == len(s.notes) {
+		return false, nil
+	}
+	if err := s.save(notes); err != nil {
+		return false, err
+	}
+	s.notes = notes
+	return true, nil
+}
+
+// save replaces the notes file in one step
+func (s *NoteStore) save(notes []StatusNote) error {
+	data, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filename)
+}
+
+// StatusPage serves the status page, its feeds and the notes API. Day files
+// of the archive are summarized once and kept until they change, so only
+// today's file is read again as results come in.
+type StatusPage struct {
+	config     StatusPageConfig
+	basePath   string
+	archiveDir string
+	notes      *NoteStore
+	days       *archiveDayCache[*statusDay]
+}
+
+// statusDay summarizes one archive day file per component
+type statusDay struct {
+	uptime  map[string]*UptimeDay
+	outages []outageSpan
+	latest  map[string]ArchiveRecord // newest record of each service
+}
+
+// outageSpan is a stretch of time during which some service of a component
+// was failing
+type outageSpan struct {
+	component  string
+	start, end time.Time
+	failures   int
+	services   map[string]bool
+	major      bool // every service of the component failed at once
+	open       bool // still failing at the end of the day file
+}
+
+// NewStatusPage loads the notes and applies defaults
+func NewStatusPage(config StatusPageConfig, basePath, archiveDir string) (*StatusPage, error) {
+	if config.Title == "" {
+		config.Title = "Service Status"
+	}
+	if config.NotesFile == "" {
+		config.NotesFile = "status_notes.json"
+	}
+	for i, component := range config.Components {
+		if component.Name == "" {
+			return nil, fmt.Errorf("status page component %d has no name", i+1)
+		}
+	}
+	notes, err := loadNoteStore(config.NotesFile)
+	if err != nil {
+		return nil, err
+	}
+	page := &StatusPage{config: config, basePath: basePath, archiveDir: archiveDir, notes: notes}
+	page.days = newArchiveDayCache(page.summarizeDay)
+	return page, nil
+}
+
+// Register adds the status page routes to mux. A non-nil auth protects the
+// notes API when the rest of the mux is public.
+func (p *StatusPage) Register(mux *http.ServeMux, auth *Authenticator) {
+	mux.HandleFunc("/status", p.pageHandler)
+	mux.HandleFunc("/status.json", p.jsonHandler)
+	mux.HandleFunc("/status.rss", p.rssHandler)
+	mux.HandleFunc("/status.atom", p.atomHandler)
+	mux.Handle("/api/status/notes", auth.Wrap(http.HandlerFunc(p.notesHandler)))
+}
+
+// componentsOf names the components a record belongs to
+func (p *StatusPage) componentsOf(record ArchiveRecord) []string {
+	if len(p.config.Components) == 0 {
+		switch {
+		case record.Provider == "" || record.Provider == "Home" || record.Provider == "N/A":
+			return nil
+		case record.Coordinates != nil && record.Coordinates.Valid():
+			return []string{record.Provider + " " + continentOf(*record.Coordinates)}
+		}
+		return []string{record.Provider}
+	}
+
+	var names []string
+	for _, component := range p.config.Components {
+		for _, match := range component.Match {
+			if match.Matches(record) {
+				names = append(names, component.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// summarizeDay computes uptime and outages of one day file
+func (p *StatusPage) summarizeDay(records []ArchiveRecord) *statusDay {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	day := &statusDay{uptime: make(map[string]*UptimeDay), latest: latestByService(records)}
+
+	components := make(map[string][]string)     // service to components
+	members := make(map[string]map[string]bool) // component to services
+	for _, record := range records {
+		if _, ok := components[record.Service]; ok {
+			continue
+		}
+		components[record.Service] = p.componentsOf(record)
+		for _, name := range components[record.Service] {
+			if members[name] == nil {
+				members[name] = make(map[string]bool)
+			}
+			members[name][record.Service] = true
+		}
+	}
+
+	failing := make(map[string]map[string]bool)
+	open := make(map[string]*outageSpan)
+	for _, record := range records {
+		// Like SLOs, maintenance and local network outages don't count
+		if record.Maintenance != "" || record.Cycle == "LOCAL_OUTAGE" {
+			continue
+		}
+		for _, name := range components[record.Service] {
+			uptime := day.uptime[name]
+			if uptime == nil {
+				uptime = &UptimeDay{}
+				day.uptime[name] = uptime
+				failing[name] = make(map[string]bool)
+			}
+			uptime.Total++
+			span := open[name]
+
+			if record.Online {
+				uptime.Good++
+				delete(failing[name], record.Service)
+				if span != nil && len(failing[name]) == 0 {
+					span.end = record.Timestamp
+					day.outages = append(day.outages, *span)
+					delete(open, name)
+				}
+				continue
+			}
+
+			failing[name][record.Service] = true
+			if span == nil {
+				span = &outageSpan{component: name, start: record.Timestamp, services: make(map[string]bool)}
+				open[name] = span
+			}
+			span.end = record.Timestamp
+			span.failures++
+			span.services[record.Service] = true
+			if len(failing[name]) == len(members[name]) {
+				span.major = true
+			}
+		}
+	}
+	for _, span := range open {
+		span.open = true
+		day.outages = append(day.outages, *span)
+	}
+	return day
+}
+
+// loadDays returns the summaries of the archive files of the last
+// statusDays days, keyed by date, and the newest date
+func (p *StatusPage) loadDays(now time.Time) (map[string]*statusDay, string, error) {
+	days, err := p.days.Days(p.archiveDir, now.AddDate(0, 0, -(statusDays-1)).Format("2006-01-02"))
+	if err != nil {
+		return nil, "", err
+	}
+	newest := ""
+	for date := range days {
+		if date > newest {
+			newest = date
+		}
+	}
+	return days, newest, nil
+}
+
+// Report builds the status page as of now
+func (p *StatusPage) Report(now time.Time) (*StatusReport, error) {
+	days, newest, err := p.loadDays(now)
+	if err != nil {
+		return nil, err
+	}
+	report := &StatusReport{Title: p.config.Title, Updated: now, Status: "operational"}
+
+	// Current status from the newest check of each service, even if it was
+	// not checked on the newest day
+	latest := make(map[string]ArchiveRecord)
+	for _, day := range days {
+		for service, record := range day.latest {
+			if previous, ok := latest[service]; !ok || record.Timestamp.After(previous.Timestamp) {
+				latest[service] = record
+			}
+		}
+	}
+
+	var names []string
+	failing := make(map[string][]string)
+	current := make(map[string]string)
+	if len(latest) > 0 {
+		counts := make(map[string]*struct{ services, down, degraded, maintenance int })
+		for _, record := range latest {
+			for _, name := range p.componentsOf(record) {
+				count := counts[name]
+				if count == nil {
+					count = &struct{ services, down, degraded, maintenance int }{}
+					counts[name] = count
+				}
+				count.services++
+				switch {
+				case record.Maintenance != "":
+					count.maintenance++
+				case record.Cycle == "LOCAL_OUTAGE":
+				case !record.Online:
+					count.down++
+					failing[name] = append(failing[name], record.Service)
+				case record.Degraded:
+					count.degraded++
+					failing[name] = append(failing[name], record.Service)
+				}
+			}
+		}
+		for name, count := range counts {
+			switch {
+			case count.down > 0 && count.down == count.services:
+				current[name] = "major_outage"
+			case count.down > 0:
+				current[name] = "partial_outage"
+			case count.degraded > 0:
+				current[name] = "degraded_performance"
+			case count.maintenance == count.services:
+				current[name] = "maintenance"
+			default:
+				current[name] = "operational"
+			}
+		}
+	}
+
+	if len(p.config.Components) > 0 {
+		for _, component := range p.config.Components {
+			names = append(names, component.Name)
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, day := range days {
+			for name := range day.uptime {
+				seen[name] = true
+			}
+		}
+		for name := range current {
+			seen[name] = true
+		}
+		names = sortedKeys(seen)
+	}
+
+	report.Incidents = p.incidents(days, newest, current)
+	started := make(map[string]int) // component and date to incidents
+	for _, incident := range report.Incidents {
+		started[incident.Component+"|"+incident.Start.Format("2006-01-02")]++
+	}
+
+	for _, name := range names {
+		component := ComponentStatus{Name: name, Status: current[name], Failing: failing[name]}
+		if component.Status == "" {
+			component.Status = "unknown"
+		}
+		sort.Strings(component.Failing)
+
+		var good, total int
+		for i := statusDays - 1; i >= 0; i-- {
+			date := now.AddDate(0, 0, -i).Format("2006-01-02")
+			bar := UptimeDay{Date: date, Incidents: started[name+"|"+date]}
+			if day := days[date]; day != nil && day.uptime[name] != nil {
+				bar.Good, bar.Total = day.uptime[name].Good, day.uptime[name].Total
+				bar.UptimePc = float64(bar.Good) / float64(bar.Total) * 100
+				good += bar.Good
+				total += bar.Total
+			}
+			component.Days = append(component.Days, bar)
+		}
+		if total > 0 {
+			component.UptimePc = float64(good) / float64(total) * 100
+		}
+
+		// Components without a current check have no say in the headline
+		if component.Status != "unknown" && statusRank(component.Status) > statusRank(report.Status) {
+			report.Status = component.Status
+		}
+		report.Components = append(report.Components, component)
+	}
+	if len(current) == 0 && len(report.Components) > 0 {
+		report.Status = "unknown"
+	}
+
+	return report, nil
+}
+
+// incidents joins outages across day files, drops blips and attaches the
+// manual notes. Notices posted through the notes API are incidents too.
+func (p *StatusPage) incidents(days map[string]*statusDay, newest string, current map[string]string) []Incident {
+	var spans []outageSpan
+	for date, day := range days {
+		for _, span := range day.outages {
+			span.open = span.open && date == newest
+			spans = append(spans, span)
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].component != spans[j].component {
+			return spans[i].component < spans[j].component
+		}
+		return spans[i].start.Before(spans[j].start)
+	})
+
+	var merged []outageSpan
+	for _, span := range spans {
+		if n := len(merged); n > 0 && merged[n-1].component == span.component && span.start.Sub(merged[n-1].end) <= outageMergeGap {
+			last := &merged[n-1]
+			if span.end.After(last.end) {
+				last.end = span.end
+			}
+			last.failures += span.failures
+			last.major = last.major || span.major
+			last.open = span.open
+			for service := range span.services {
+				last.services[service] = true
+			}
+			continue
+		}
+		services := make(map[string]bool)
+		for service := range span.services {
+			services[service] = true
+		}
+		span.services = services
+		merged = append(merged, span)
+	}
+
+	incidents := []Incident{}
+	byID := make(map[string]*Incident)
+	for _, span := range merged {
+		if span.failures < minOutageFailures {
+			continue
+		}
+		incident := Incident{
+			ID:        fmt.Sprintf("%s-%d", endpointID(span.component, ""), span.start.Unix()),
+			Title:     span.component + ": partial outage",
+			Component: span.component,
+			Severity:  "partial_outage",
+			Start:     span.start,
+			End:       span.end,
+			Ongoing:   span.open && statusRank(current[span.component]) >= statusRank("partial_outage"),
+			Failures:  span.failures,
+			Services:  sortedKeys(span.services),
+		}
+		if span.major {
+			incident.Title = span.component + ": major outage"
+			incident.Severity = "major_outage"
+		}
+		incidents = append(incidents, incident)
+	}
+
+	notes := p.notes.List()
+	for _, note := range notes {
+		if note.Incident == "" {
+			incidents = append(incidents, Incident{
+				ID:        note.ID,
+				Title:     note.Title,
+				Component: note.Component,
+				Severity:  "notice",
+				Start:     note.Time,
+				Ongoing:   note.Status != "resolved",
+			})
+		}
+	}
+	for i := range incidents {
+		byID[incidents[i].ID] = &incidents[i]
+	}
+	for _, note := range notes {
+		id := note.Incident
+		if id == "" {
+			id = note.ID
+		}
+		incident := byID[id]
+		if incident == nil {
+			continue // about an incident older than the history
+		}
+		if p.config.Public {
+			note.Author = "" // user and token names stay internal
+		}
+		incident.Notes = append(incident.Notes, note)
+		if incident.Severity == "notice" {
+			incident.Ongoing = note.Status != "resolved"
+			if note.Status == "resolved" {
+				incident.End = note.Time
+			}
+		}
+	}
+
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].Start.After(incidents[j].Start) })
+	if len(incidents) > maxStatusIncidents {
+		incidents = incidents[:maxStatusIncidents]
+	}
+	return incidents
+}
+
+// pageURL is the absolute address of the status page, for feeds
+func (p *StatusPage) pageURL(r *http.Request) string {
+	if p.config.URL != "" {
+		return p.config.URL
+	}
+	scheme := "http"
+	switch {
+	case r.URL.Scheme != "": // from a trusted proxy
+		scheme = r.URL.Scheme
+	case r.TLS != nil:
+		scheme = "https"
+	}
+	page := "status"
+	if p.config.Public {
+		page = ""
+	}
+	return scheme + "://" + r.Host + cleanBasePath(p.basePath) + page
+}
+
+// statusPageData is what status.html renders
+type statusPageData struct {
+	*StatusReport
+	Public bool
+}
+
+// pageHandler serves the HTML status page, which needs no JavaScript
+func (p *StatusPage) pageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/status" {
+		http.NotFound(w, r)
+		return
+	}
+	report, err := p.Report(time.Now())
+	if err != nil {
+		log.Printf("Error building status page: %v", err)
+		http.Error(w, "Error loading data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := statusTemplate.Execute(w, statusPageData{report, p.config.Public}); err != nil {
+		log.Printf("Template execute error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// jsonHandler serves /status.json
+func (p *StatusPage) jsonHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := p.Report(time.Now())
+	if err != nil {
+		log.Printf("Error building status page: %v", err)
+		http.Error(w, "Error loading data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// incidentSummary describes an incident and its notes as plain text
+func incidentSummary(incident Incident) string {
+	var b strings.Builder
+	switch {
+	case incident.Severity == "notice" && incident.Component != "":
+		fmt.Fprintf(&b, "Notice for %s", incident.Component)
+	case incident.Severity == "notice":
+		b.WriteString("Notice")
+	default:
+		fmt.Fprintf(&b, "%s of %s from %s", statusLabel(incident.Severity), incident.Component, incident.Start.Format("2006-01-02 15:04 MST"))
+		if incident.Ongoing {
+			b.WriteString(", ongoing")
+		} else {
+			fmt.Fprintf(&b, " to %s", incident.End.Format("2006-01-02 15:04 MST"))
+		}
+		fmt.Fprintf(&b, " (%d failed checks: %s)", incident.Failures, strings.Join(incident.Services, ", "))
+	}
+	b.WriteString(".")
+	for _, note := range incident.Notes {
+		fmt.Fprintf(&b, "\n%s", note.Time.Format("2006-01-02 15:04 MST"))
+		if note.Status != "" {
+			fmt.Fprintf(&b, " [%s]", note.Status)
+		}
+		fmt.Fprintf(&b, " %s", note.Message)
+	}
+	return b.String()
+}
+
+// rssFeed is an RSS 2.0 document
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title         string    `xml:"title"`
+		Link          string    `xml:"link"`
+		Description   string    `xml:"description"`
+		LastBuildDate string    `xml:"lastBuildDate"`
+		Items         []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// rssItem is one incident in the RSS feed
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	GUID        struct {
+		ID          string `xml:",chardata"`
+		IsPermaLink bool   `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+}
+
+// rssHandler serves /status.rss with one item per incident
+func (p *StatusPage) rssHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := p.Report(time.Now())
+	if err != nil {
+		log.Printf("Error building status page: %v", err)
+		http.Error(w, "Error loading data", http.StatusInternalServerError)
+		return
+	}
+	link := p.pageURL(r)
+
+	feed := rssFeed{Version: "2.0"}
+	feed.Channel.Title = report.Title
+	feed.Channel.Link = link
+	feed.Channel.Description = "Incidents and notices for " + report.Title
+	feed.Channel.LastBuildDate = report.Updated.Format(time.RFC1123Z)
+	for _, incident := range report.Incidents {
+		item := rssItem{
+			Title:       incident.Title,
+			Link:        link + "#" + incident.ID,
+			Description: incidentSummary(incident),
+			PubDate:     incident.Updated().Format(time.RFC1123Z),
+		}
+		item.GUID.ID = incident.ID
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("Error writing RSS feed: %v", err)
+	}
+}
+
+// atomFeed is an Atom document
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomLink is a link element of an Atom feed or entry
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// atomEntry is one incident in the Atom feed
+type atomEntry struct {
+	Title     string   `xml:"title"`
+	ID        string   `xml:"id"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Link      atomLink `xml:"link"`
+	Summary   string   `xml:"summary"`
+}
+
+// atomHandler serves /status.atom with one entry per incident
+func (p *StatusPage) atomHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := p.Report(time.Now())
+	if err != nil {
+		log.Printf("Error building status page: %v", err)
+		http.Error(w, "Error loading data", http.StatusInternalServerError)
+		return
+	}
+	link := p.pageURL(r)
+
+	feed := atomFeed{
+		Title:   report.Title,
+		ID:      link,
+		Updated: report.Updated.Format(time.RFC3339),
+		Link: []atomLink{
+			{Href: link},
+			{Href: link + ".atom", Rel: "self"},
+		},
+		Author: report.Title,
+	}
+	// The feed sits next to the page, which may be the site root
+	if page, err := url.Parse(link); err == nil {
+		feed.Link[1].Href = page.ResolveReference(&url.URL{Path: "status.atom"}).String()
+	}
+	for _, incident := range report.Incidents {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     incident.Title,
+			ID:        link + "#" + incident.ID,
+			Published: incident.Start.Format(time.RFC3339),
+			Updated:   incident.Updated().Format(time.RFC3339),
+			Link:      atomLink{Href: link + "#" + incident.ID},
+			Summary:   incidentSummary(incident),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("Error writing Atom feed: %v", err)
+	}
+}
+
+// notesHandler serves /api/status/notes: GET lists the notes, POST adds one
+// and DELETE ?id= removes one. Changes need an authenticated user or token.
+func (p *StatusPage) notesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.notes.List())
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "GET, HEAD, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	author := authenticatedName(r)
+	if author == "" {
+		http.Error(w, "Forbidden: incident notes can only be changed with Dashboard.Auth configured", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		id := r.URL.Query().Get("id")
+		found, err := p.notes.Delete(id)
+		switch {
+		case err != nil:
+			log.Printf("Error saving %s: %v", p.config.NotesFile, err)
+			http.Error(w, "Error saving notes", http.StatusInternalServerError)
+		case !found:
+			http.Error(w, fmt.Sprintf("no note %q", id), http.StatusNotFound)
+		default:
+			log.Printf("Status note %s deleted by %s", id, author)
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	var note StatusNote
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&note); err != nil {
+		http.Error(w, "invalid note: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := p.validateNote(note); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	note.Author = author
+
+	note, err := p.notes.Add(note)
+	if err != nil {
+		log.Printf("Error saving %s: %v", p.config.NotesFile, err)
+		http.Error(w, "Error saving notes", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Status note %s added by %s", note.ID, author)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// validateNote checks a posted note against the current incidents and
+// components
+func (p *StatusPage) validateNote(note StatusNote) error {
+	if strings.TrimSpace(note.Message) == "" {
+		return fmt.Errorf("a note needs a Message")
+	}
+	known := note.Status == ""
+	for _, status := range noteStatuses {
+		known = known || status == note.Status
+	}
+	if !known {
+		return fmt.Errorf("unknown Status %q (want one of %s)", note.Status, strings.Join(noteStatuses, ", "))
+	}
+	if note.Incident == "" && strings.TrimSpace(note.Title) == "" {
+		return fmt.Errorf("a notice needs a Title, or Incident to update an existing incident")
+	}
+
+	report, err := p.Report(time.Now())
+	if err != nil {
+		return err
+	}
+	incidentFound, componentFound := note.Incident == "", note.Component == ""
+	for _, incident := range report.Incidents {
+		incidentFound = incidentFound || incident.ID == note.Incident
+	}
+	for _, component := range report.Components {
+		componentFound = componentFound || component.Name == note.Component
+	}
+	if !incidentFound {
+		return fmt.Errorf("no incident %q", note.Incident)
+	}
+	if !componentFound {
+		return fmt.Errorf("no component %q", note.Component)
+	}
+	return nil
+}
+
+// uptimeClass colors a day of the uptime bars
+func uptimeClass(day UptimeDay) string {
+	switch {
+	case day.Total == 0:
+		return "uptime-none"
+	case day.UptimePc >= 99.9:
+		return "uptime-good"
+	case day.UptimePc >= 99:
+		return "uptime-minor"
+	case day.UptimePc >= 95:
+		return "uptime-partial"
+	}
+	return "uptime-major"
+}
@@ -0,0 +1,179 @@
+package main
+
+// Authentication and reverse proxy tests:
+//
+//	go test dashboard.go dashboard_test.go
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testAuthenticator has a user with a plain password, a user with a hashed
+// one, a read-only token and a full token
+func testAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	sum := sha256.Sum256([]byte("s3cret"))
+	auth, err := NewAuthenticator(AuthConfig{
+		Users: map[string]string{"admin": "pw", "ops": "sha256:" + hex.EncodeToString(sum[:])},
+		Tokens: []APIToken{
+			{Name: "grafana", Token: "rotok", ReadOnly: true},
+			{Name: "deploy", Token: "opstok"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func TestAuthenticatorWrap(t *testing.T) {
+	handler := testAuthenticator(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(authenticatedName(r)))
+	}))
+	sum := sha256.Sum256([]byte("s3cret"))
+
+	tests := []struct {
+		name     string
+		method   string
+		user     string
+		password string
+		token    string
+		status   int
+		who      string
+	}{
+		{"no credentials", "GET", "", "", "", http.StatusUnauthorized, ""},
+		{"wrong password", "GET", "admin", "wrong", "", http.StatusUnauthorized, ""},
+		{"unknown user", "GET", "root", "pw", "", http.StatusUnauthorized, ""},
+		{"empty password", "GET", "admin", "", "", http.StatusUnauthorized, ""},
+		{"password", "GET", "admin", "pw", "", http.StatusOK, "admin"},
+		{"hashed password", "POST", "ops", "s3cret", "", http.StatusOK, "ops"},
+		{"hash given as password", "GET", "ops", hex.EncodeToString(sum[:]), "", http.StatusUnauthorized, ""},
+		{"unknown token", "GET", "", "", "nope", http.StatusUnauthorized, ""},
+		{"read-only token GET", "GET", "", "", "rotok", http.StatusOK, "token grafana"},
+		{"read-only token HEAD", "HEAD", "", "", "rotok", http.StatusOK, ""},
+		{"read-only token POST", "POST", "", "", "rotok", http.StatusForbidden, ""},
+		{"read-only token DELETE", "DELETE", "", "", "rotok", http.StatusForbidden, ""},
+		{"token POST", "POST", "", "", "opstok", http.StatusOK, "token deploy"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/api/status/notes", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.password)
+		}
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+		}
+		if test.status == http.StatusOK && test.method != "HEAD" && w.Body.String() != test.who {
+			t.Errorf("%s: authenticated as %q, want %q", test.name, w.Body.String(), test.who)
+		}
+		if test.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate challenge", test.name)
+		}
+	}
+}
+
+func TestNewAuthenticatorRejectsEmptySecrets(t *testing.T) {
+	if _, err := NewAuthenticator(AuthConfig{Users: map[string]string{"admin": ""}}); err == nil {
+		t.Error("user without a password accepted")
+	}
+	if _, err := NewAuthenticator(AuthConfig{Tokens: []APIToken{{Name: "ci"}}}); err == nil {
+		t.Error("empty token accepted")
+	}
+	if auth, err := NewAuthenticator(AuthConfig{}); auth != nil || err != nil {
+		t.Errorf("NewAuthenticator with nothing configured = %v, %v, want nil", auth, err)
+	}
+}
+
+// proxied is what a handler behind withTrustedProxies saw
+type proxied struct {
+	remoteAddr string
+	feedLink   string
+}
+
+// throughProxies sends one request through withTrustedProxies and returns
+// the client address and status page link the handler saw
+func throughProxies(t *testing.T, trustedEntries []string, remoteAddr string, headers map[string]string) proxied {
+	t.Helper()
+	proxies, err := parseTrustedProxies(trustedEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := &StatusPage{basePath: "/latency/"}
+
+	var seen proxied
+	handler := withTrustedProxies(proxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = proxied{remoteAddr: r.RemoteAddr, feedLink: page.pageURL(r)}
+	}))
+	r := httptest.NewRequest("GET", "/latency/status.rss", nil)
+	r.Host = "10.0.0.2:8080"
+	r.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	return seen
+}
+
+func TestWithTrustedProxies(t *testing.T) {
+	trustedEntries := []string{"10.0.0.0/8", "127.0.0.1"}
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "status.example.com"}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       proxied
+	}{
+		{"direct client", "203.0.113.7:51000", nil,
+			proxied{"203.0.113.7:51000", "http://10.0.0.2:8080/latency/status"}},
+		{"client spoofing the headers", "203.0.113.7:51000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"},
+			proxied{"203.0.113.7:51000", "http://10.0.0.2:8080/latency/status"}},
+		{"trusted proxy", "10.0.0.5:40000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "status.example.com"},
+			proxied{"203.0.113.7:0", "https://status.example.com/latency/status"}},
+		{"spoofed address behind a trusted proxy", "10.0.0.5:40000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7"},
+			proxied{"203.0.113.7:0", "http://10.0.0.2:8080/latency/status"}},
+		{"chain of trusted proxies", "127.0.0.1:40000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.9", "X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "status.example.com, 10.0.0.9"},
+			proxied{"203.0.113.7:0", "https://status.example.com/latency/status"}},
+		{"garbage in the chain", "10.0.0.5:40000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7, not-an-ip"},
+			proxied{"10.0.0.5:40000", "http://10.0.0.2:8080/latency/status"}},
+		{"invalid forwarded values", "10.0.0.5:40000",
+			map[string]string{"X-Forwarded-Proto": "javascript", "X-Forwarded-Host": "evil.example.com/phish"},
+			proxied{"10.0.0.5:40000", "http://10.0.0.2:8080/latency/status"}},
+	}
+	for _, test := range tests {
+		if got := throughProxies(t, trustedEntries, test.remoteAddr, test.headers); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	// Without trusted proxies every header is ignored
+	if got := throughProxies(t, nil, "10.0.0.5:40000", forwarded); got.feedLink != "http://10.0.0.2:8080/latency/status" {
+		t.Errorf("no trusted proxies: feed link %q", got.feedLink)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{" 127.0.0.1 ", "", "10.0.0.0/8", "::1"})
+	if err != nil || len(proxies) != 3 {
+		t.Fatalf("parseTrustedProxies = %v, %v", proxies, err)
+	}
+	for _, bad := range []string{"proxy.local", "10.0.0.0/33"} {
+		if _, err := parseTrustedProxies([]string{bad}); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
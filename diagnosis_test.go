@@ -0,0 +1,62 @@
+package main
+
+// Root-cause diagnosis tests:
+//
+//	go test main.go diagnosis_test.go
+
+import "testing"
+
+// s3Tokyo is the endpoint every diagnosis test checks
+var s3Tokyo = CloudEndpoint{Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS", Hostname: "s3.ap-northeast-1.amazonaws.com"}
+
+// layerResults is one cycle of DNS, PING and HTTP checks where the HTTP check
+// got the given status code
+func layerResults(statusCode int) []TestResult {
+	return []TestResult{
+		{Endpoint: s3Tokyo, TestType: TestTypeDNS, Online: true},
+		{Endpoint: s3Tokyo, TestType: TestTypePing, Online: true, ResolvedIP: "52.219.0.1"},
+		{Endpoint: s3Tokyo, TestType: TestTypeHTTP, Online: true, StatusCode: statusCode},
+	}
+}
+
+func TestDiagnoseEndpointHTTPStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       string
+	}{
+		{200, DiagnosisHealthy},
+		{403, DiagnosisHealthy},
+		{404, DiagnosisHealthy},
+		{500, DiagnosisService},
+		{503, DiagnosisService},
+	}
+	for _, test := range tests {
+		diagnosis := diagnoseEndpoint(s3Tokyo, layerResults(test.statusCode), CycleNormal)
+		if diagnosis.Verdict != test.want {
+			t.Errorf("HTTP %d: verdict = %s (%s), want %s", test.statusCode, diagnosis.Verdict, diagnosis.Reason, test.want)
+		}
+	}
+}
+
+func TestDiagnoseEndpointLowerLayerExplainsServerError(t *testing.T) {
+	results := layerResults(503)
+	results[0].Online = false
+	results[0].Error = "no such host"
+
+	diagnosis := diagnoseEndpoint(s3Tokyo, results, CycleNormal)
+	if diagnosis.Verdict != DiagnosisDNS {
+		t.Errorf("verdict = %s (%s), want %s", diagnosis.Verdict, diagnosis.Reason, DiagnosisDNS)
+	}
+}
+
+func TestDiagnoseEndpointServiceDown(t *testing.T) {
+	results := layerResults(0)
+	results[2].Online = false
+	results[2].Error = "context deadline exceeded"
+	results[2].Phases = []ProbePhase{{Name: "dns"}, {Name: "connect"}, {Name: "tls"}, {Name: "ttfb", Error: "context deadline exceeded"}}
+
+	diagnosis := diagnoseEndpoint(s3Tokyo, results, CycleNormal)
+	if diagnosis.Verdict != DiagnosisService {
+		t.Errorf("verdict = %s (%s), want %s", diagnosis.Verdict, diagnosis.Reason, DiagnosisService)
+	}
+}
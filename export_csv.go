@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,9 +18,25 @@ import (
 type DataPoint struct {
 	Timestamp    time.Time
 	ResponseTime int64 // nanoseconds
+	Failed       bool  // failed checks carry no latency
+}
+
+// successfulPoints returns the points of checks that succeeded
+func successfulPoints(points []DataPoint) []DataPoint {
+	var kept []DataPoint
+	for _, point := range points {
+		if !point.Failed {
+			kept = append(kept, point)
+		}
+	}
+	return kept
 }
 
 func main() {
+	heatmapDays := flag.Int("days", 30, "days of the result archive covered by the heatmaps")
+	heatmapProvider := flag.String("provider", "", "only include this provider in the heatmaps, e.g. AWS")
+	flag.Parse()
+
 	// Read the JSON file
 	data, err := os.ReadFile("latency_history.json")
 	if err != nil {
@@ -30,6 +50,9 @@ func main() {
 		fmt.Printf("Error parsing JSON: %v\n", err)
 		os.Exit(1)
 	}
+	for service, points := range history {
+		history[service] = successfulPoints(points)
+	}
 
 	fmt.Println("Generating CSV exports...")
 
@@ -61,10 +84,39 @@ func main() {
 		fmt.Println("✓ Created: latency_by_test_type.csv")
 	}
 
+	// Export 5: SLO compliance (only when the monitor has SLOs defined)
+	if created, err := exportSLO(); err != nil {
+		fmt.Printf("Error exporting SLOs: %v\n", err)
+	} else if created {
+		fmt.Println("✓ Created: latency_slo.csv")
+	}
+
+	// Export 6: p95 heatmaps (only when the monitor keeps an archive)
+	if created, err := exportHeatmaps(loadArchiveDir("monitor_config.json"), *heatmapDays, *heatmapProvider); err != nil {
+		fmt.Printf("Error exporting heatmaps: %v\n", err)
+	} else if created {
+		fmt.Println("✓ Created: latency_heatmap_hour.csv")
+		fmt.Println("✓ Created: latency_heatmap_layer.csv")
+	}
+
 	fmt.Println("\nAll CSV files generated successfully!")
 	fmt.Println("Open in Excel for analysis and visualization.")
 }
 
+// loadArchiveDir reads History.ArchiveDir from the monitor's configuration
+func loadArchiveDir(filename string) string {
+	var config struct{ History struct{ ArchiveDir string } }
+	if data, err := os.ReadFile(filename); err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			fmt.Printf("Could not read the archive directory from %s: %v\n", filename, err)
+		}
+	}
+	if config.History.ArchiveDir == "" {
+		return "latency_archive"
+	}
+	return config.History.ArchiveDir
+}
+
 // exportSummary creates a summary statistics CSV
 func exportSummary(history map[string][]DataPoint) error {
 	file, err := os.Create("latency_summary.csv")
@@ -456,6 +508,283 @@ func exportByTestType(history map[string][]DataPoint) error {
 	return nil
 }
 
+// SLOStatus mirrors an entry of slo_status.json written by the monitor
+type SLOStatus struct {
+	Name                 string
+	Objective            string
+	Target               float64
+	ThresholdMs          float64
+	Window               string
+	Good                 int
+	Total                int
+	SLI                  float64
+	ErrorBudgetRemaining float64
+	BurnRates            []struct {
+		Window string
+		Rate   float64
+	}
+	Compliant bool
+	UpdatedAt time.Time
+}
+
+// formatWindow renders a Go duration string such as "720h0m0s" as "30d"
+func formatWindow(window string) string {
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return window
+	}
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
+
+// exportSLO creates a CSV of SLO compliance, error budget and burn rates
+func exportSLO() (bool, error) {
+	data, err := os.ReadFile("slo_status.json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var statuses []SLOStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return false, err
+	}
+
+	file, err := os.Create("latency_slo.csv")
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	// One burn rate column per window used by any SLO
+	var windows []string
+	seen := make(map[string]bool)
+	for _, s := range statuses {
+		for _, burn := range s.BurnRates {
+			if !seen[burn.Window] {
+				seen[burn.Window] = true
+				windows = append(windows, burn.Window)
+			}
+		}
+	}
+
+	header := []string{"SLO", "Objective", "Target (%)", "Threshold (ms)", "Window",
+		"Good Checks", "Total Checks", "SLI (%)", "Error Budget Remaining (%)", "Status"}
+	for _, window := range windows {
+		header = append(header, "Burn Rate ("+formatWindow(window)+")")
+	}
+	header = append(header, "Evaluated")
+	writer.Write(header)
+
+	for _, s := range statuses {
+		status := "MET"
+		if !s.Compliant {
+			status = "MISSED"
+		}
+
+		row := []string{
+			s.Name,
+			s.Objective,
+			fmt.Sprintf("%.3f", s.Target),
+			fmt.Sprintf("%.0f", s.ThresholdMs),
+			formatWindow(s.Window),
+			strconv.Itoa(s.Good),
+			strconv.Itoa(s.Total),
+			fmt.Sprintf("%.3f", s.SLI),
+			fmt.Sprintf("%.2f", s.ErrorBudgetRemaining),
+			status,
+		}
+		for _, window := range windows {
+			rate := ""
+			for _, burn := range s.BurnRates {
+				if burn.Window == window {
+					rate = fmt.Sprintf("%.2f", burn.Rate)
+				}
+			}
+			row = append(row, rate)
+		}
+		row = append(row, s.UpdatedAt.Format("2006-01-02 15:04:05"))
+		writer.Write(row)
+	}
+
+	return true, nil
+}
+
+// ArchiveRecord holds the fields of a latency_archive record the heatmaps need
+type ArchiveRecord struct {
+	Timestamp    time.Time
+	Location     string
+	Provider     string
+	TestType     string
+	Online       bool
+	ResponseTime int64 // nanoseconds
+	Maintenance  string
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted)) + 0.999999)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// exportHeatmaps creates the location × hour-of-day and location × test
+// layer heatmaps of p95 latency from the last days of the archive, the same
+// cells the dashboard's /heatmap page shows. Hours are in local time.
+func exportHeatmaps(archiveDir string, days int, provider string) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(archiveDir, "*.jsonl"))
+	if err != nil || len(files) == 0 {
+		return false, err
+	}
+	sort.Strings(files)
+
+	from := time.Now().AddDate(0, 0, -days)
+	firstDay := from.AddDate(0, 0, -1).Format("2006-01-02")
+
+	type locationKey struct{ Location, Provider string }
+	byHour := make(map[locationKey]map[int][]float64)
+	byLayer := make(map[locationKey]map[string][]float64)
+	layerSeen := make(map[string]bool)
+
+	for _, filename := range files {
+		if strings.TrimSuffix(filepath.Base(filename), ".jsonl") < firstDay {
+			continue
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			return false, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var record ArchiveRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue // skip a partially written line
+			}
+			if record.Timestamp.Before(from) || !record.Online || record.Maintenance != "" || record.Provider == "Home" {
+				continue
+			}
+			if provider != "" && !strings.EqualFold(record.Provider, provider) {
+				continue
+			}
+			key := locationKey{record.Location, record.Provider}
+			if byHour[key] == nil {
+				byHour[key] = make(map[int][]float64)
+				byLayer[key] = make(map[string][]float64)
+			}
+			ms := float64(record.ResponseTime) / 1e6
+			hour := record.Timestamp.Local().Hour()
+			byHour[key][hour] = append(byHour[key][hour], ms)
+			byLayer[key][record.TestType] = append(byLayer[key][record.TestType], ms)
+			layerSeen[record.TestType] = true
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return false, err
+		}
+	}
+
+	var keys []locationKey
+	for key := range byHour {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Location == keys[j].Location {
+			return keys[i].Provider < keys[j].Provider
+		}
+		return keys[i].Location < keys[j].Location
+	})
+
+	// PING, DNS and HTTP first, then any other probe types
+	var layers []string
+	for _, layer := range []string{"PING", "DNS", "HTTP"} {
+		if layerSeen[layer] {
+			layers = append(layers, layer)
+			delete(layerSeen, layer)
+		}
+	}
+	var others []string
+	for layer := range layerSeen {
+		others = append(others, layer)
+	}
+	sort.Strings(others)
+	layers = append(layers, others...)
+
+	p95 := func(samples []float64) string {
+		if len(samples) == 0 {
+			return ""
+		}
+		sort.Float64s(samples)
+		return strconv.FormatFloat(percentile(samples, 95), 'f', 1, 64)
+	}
+
+	hourHeader := []string{"Location", "Provider"}
+	for hour := 0; hour < 24; hour++ {
+		hourHeader = append(hourHeader, fmt.Sprintf("%02d:00 p95 (ms)", hour))
+	}
+	var hourRows [][]string
+	for _, key := range keys {
+		row := []string{key.Location, key.Provider}
+		for hour := 0; hour < 24; hour++ {
+			row = append(row, p95(byHour[key][hour]))
+		}
+		hourRows = append(hourRows, row)
+	}
+	if err := writeCSV("latency_heatmap_hour.csv", hourHeader, hourRows); err != nil {
+		return false, err
+	}
+
+	layerHeader := []string{"Location", "Provider"}
+	for _, layer := range layers {
+		layerHeader = append(layerHeader, layer+" p95 (ms)")
+	}
+	var layerRows [][]string
+	for _, key := range keys {
+		row := []string{key.Location, key.Provider}
+		for _, layer := range layers {
+			row = append(row, p95(byLayer[key][layer]))
+		}
+		layerRows = append(layerRows, row)
+	}
+	if err := writeCSV("latency_heatmap_layer.csv", layerHeader, layerRows); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// writeCSV creates a CSV file from a header and rows
+func writeCSV(filename string, header []string, rows [][]string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write(header)
+	writer.WriteAll(rows)
+	return writer.Error()
+}
+
 // parseServiceName extracts location, provider, and test type from service name
 func parseServiceName(name string) (location, provider, testType string) {
 	// Format examples:
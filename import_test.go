@@ -0,0 +1,182 @@
+package main
+
+// Log import tests, built from lines of cloud_latency.log and health_monitor.log:
+//
+//	go test main.go import_test.go
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logTime parses a log timestamp the way the importer does
+func logTime(t *testing.T, text string) time.Time {
+	t.Helper()
+	timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", text, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return timestamp
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want ArchiveRecord
+	}{
+		{
+			"monitor up",
+			"2025-12-28 21:48:32 | [UP] Frankfurt, DE [AWS]                 | Test: DNS | Response: 56ms | Trend: BASELINE",
+			ArchiveRecord{Service: "Frankfurt, DE [AWS] - DNS", Location: "Frankfurt, DE", Region: "eu-central-1", Provider: "AWS",
+				TestType: "DNS", Online: true, ResponseTime: int64(56 * time.Millisecond), Trend: "BASELINE"},
+		},
+		{
+			"monitor down",
+			"2025-12-28 21:48:32 | [DOWN] Dallas, TX [Azure]                  | Test: PING | Response: 0ms | Trend: BASELINE | Error: DNS resolution failed",
+			ArchiveRecord{Service: "Dallas, TX [Azure] - PING", Location: "Dallas, TX", Provider: "Azure",
+				TestType: "PING", Trend: "BASELINE", Error: "DNS resolution failed", ErrorCategory: ErrorCategoryDNS},
+		},
+		{
+			"error containing pipes",
+			"2025-12-28 21:48:32 | [DOWN] Frankfurt, DE [AWS]                 | Test: EXEC | Response: 0ms | Trend: BASELINE | Error: CRITICAL - 3 rows | time=0.5s | Maintenance: aws-eu-upgrade",
+			ArchiveRecord{Service: "Frankfurt, DE [AWS] - EXEC", Location: "Frankfurt, DE", Region: "eu-central-1", Provider: "AWS",
+				TestType: "EXEC", Trend: "BASELINE", Error: "CRITICAL - 3 rows | time=0.5s", ErrorCategory: ErrorCategoryOther, Maintenance: "aws-eu-upgrade"},
+		},
+		{
+			"probe target",
+			"2025-12-28 21:48:32 | [UP] Frankfurt, DE [AWS]                 | Test: TCP | Response: 21ms | Trend: BASELINE | Target: 8443",
+			ArchiveRecord{Service: "Frankfurt, DE [AWS] - TCP:8443", Location: "Frankfurt, DE", Region: "eu-central-1", Provider: "AWS",
+				TestType: "TCP", Target: "8443", Online: true, ResponseTime: int64(21 * time.Millisecond), Trend: "BASELINE"},
+		},
+		{
+			"health monitor seconds",
+			"2025-12-28 20:52:08 | [UP] Home Router                    | Type: http | Response: 0.05s",
+			ArchiveRecord{Service: "Home Router [Home] - HTTP", Location: "Home Router", Region: "lan", Provider: "Home",
+				Labels: map[string]string{"role": "router"}, TestType: "HTTP", Online: true, ResponseTime: int64(50 * time.Millisecond)},
+		},
+		{
+			"health monitor down",
+			`2025-12-28 20:52:08 | [DOWN] MA Connect Website             | Type: http | Response: 0.00s | Error: Get "https://maconnect.mastercard.com": dial tcp: lookup maconnect.mastercard.com: no such host`,
+			ArchiveRecord{Service: "MA Connect Website [Home] - HTTP", Location: "MA Connect Website", Region: "internet", Provider: "Home",
+				Labels: map[string]string{"role": "website"}, TestType: "HTTP",
+				Error: `Get "https://maconnect.mastercard.com": dial tcp: lookup maconnect.mastercard.com: no such host`, ErrorCategory: ErrorCategoryDNS},
+		},
+		{
+			"legacy check name",
+			"2025-12-28 21:12:57 | [UP] Tokyo DNS (Japan)                             | Type: dns | Response: 0ms",
+			ArchiveRecord{Service: "Tokyo, JP [AWS] - DNS", Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS", TestType: "DNS", Online: true},
+		},
+		{
+			"legacy location",
+			"2025-12-28 20:52:09 | [UP] Cape Town, SA (UCT)            | Type: http | Response: 1.02s",
+			ArchiveRecord{Service: "Cape Town, ZA [AWS] - HTTP", Location: "Cape Town, ZA", Region: "af-south-1", Provider: "AWS",
+				TestType: "HTTP", Online: true, ResponseTime: int64(1020 * time.Millisecond)},
+		},
+	}
+
+	importer := newLogImporter(defaultEndpoints())
+	for _, test := range tests {
+		record, err := importer.parseLine(test.line)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		test.want.Timestamp = logTime(t, test.line[:19])
+		if record.Service != test.want.Service || record.Location != test.want.Location || record.Region != test.want.Region ||
+			record.Provider != test.want.Provider || record.TestType != test.want.TestType || record.Target != test.want.Target ||
+			record.Online != test.want.Online || record.ResponseTime != test.want.ResponseTime || record.Trend != test.want.Trend ||
+			record.Error != test.want.Error || record.ErrorCategory != test.want.ErrorCategory || record.Maintenance != test.want.Maintenance ||
+			!record.Timestamp.Equal(test.want.Timestamp) || record.Labels["role"] != test.want.Labels["role"] ||
+			(record.Coordinates == nil) != (test.want.Region == "" || test.want.Provider == "Home") {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.name, record, test.want)
+		}
+	}
+}
+
+func TestParseLineRejects(t *testing.T) {
+	tests := []struct {
+		line string
+		want error
+	}{
+		{"2025-12-28 20:52:08 | [UP] Lisbon (Portugal)               | Type: http | Response: 0.05s", errUnmappedName},
+		{"2025-12-28 21:48:32 | [DIAGNOSIS] Dallas, TX [Azure] | DNS_PROBLEM | hostname does not resolve", errUnparsedLine},
+		{"2025-12-28 21:48:32 | [UP] Frankfurt, DE [AWS] | Response: 56ms", errUnparsedLine},
+		{"Cycle completed in 4.2s", errUnparsedLine},
+	}
+
+	importer := newLogImporter(defaultEndpoints())
+	for _, test := range tests {
+		if _, err := importer.parseLine(test.line); err != test.want {
+			t.Errorf("parseLine(%q) = %v, want %v", test.line, err, test.want)
+		}
+	}
+	if importer.unmapped["Lisbon (Portugal)"] != 1 {
+		t.Errorf("unmapped = %v, want Lisbon (Portugal) counted once", importer.unmapped)
+	}
+}
+
+func TestImportLogFileCountsOldAndDuplicateLines(t *testing.T) {
+	lines := []string{
+		"2025-12-27 21:48:32 | [UP] Frankfurt, DE [AWS]                 | Test: DNS | Response: 56ms | Trend: BASELINE",
+		"2025-12-28 21:48:32 | [UP] Frankfurt, DE [AWS]                 | Test: DNS | Response: 56ms | Trend: BASELINE",
+		"2025-12-28 21:48:32 | [UP] Frankfurt, DE [AWS]                 | Test: DNS | Response: 56ms | Trend: BASELINE",
+		"2025-12-28 21:48:32 | [DOWN] Dallas, TX [Azure]                  | Test: PING | Response: 0ms | Trend: BASELINE | Error: DNS resolution failed",
+		"",
+		"2025-12-28 20:52:08 | [UP] Lisbon (Portugal)               | Type: http | Response: 0.05s",
+	}
+	filename := filepath.Join(t.TempDir(), "cloud_latency.log")
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	importer := newLogImporter(defaultEndpoints())
+	records, stats, err := importer.importLogFile(filename, logTime(t, "2025-12-28 00:00:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LogImportStats{Lines: 6, Imported: 2, Failures: 1, Duplicates: 1, TooOld: 1, Skipped: 1, Unmapped: 1}
+	if stats != want || len(records) != 2 {
+		t.Errorf("stats = %+v with %d records, want %+v", stats, len(records), want)
+	}
+
+	// With -all the cutoff is the zero time
+	importer = newLogImporter(defaultEndpoints())
+	if records, _, _ := importer.importLogFile(filename, time.Time{}); len(records) != 3 {
+		t.Errorf("imported %d records without a cutoff, want 3", len(records))
+	}
+}
+
+func TestArchivePruneSkipsKeptDays(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := NewHistoryArchive(HistoryConfig{ArchiveDir: dir, RetentionDays: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -60).Format("2006-01-02")
+	kept := now.AddDate(0, 0, -45).Format("2006-01-02")
+	recent := now.AddDate(0, 0, -5).Format("2006-01-02")
+	for _, day := range []string{old, kept, recent} {
+		if err := os.WriteFile(filepath.Join(dir, day+".jsonl"), []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Keep([]string{kept}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := archive.Append([]ArchiveRecord{{Timestamp: now, Service: "Frankfurt, DE [AWS] - DNS", Online: true}}); err != nil {
+		t.Fatal(err)
+	}
+	for day, want := range map[string]bool{old: false, kept: true, recent: true, now.Format("2006-01-02"): true} {
+		_, err := os.Stat(filepath.Join(dir, day+".jsonl"))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s.jsonl exists = %v, want %v", day, exists, want)
+		}
+	}
+}
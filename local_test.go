@@ -0,0 +1,61 @@
+package main
+
+// Local outage classification tests:
+//
+//	go test main.go local_test.go
+
+import "testing"
+
+// widespreadFailure is a cycle where every remote test failed across two
+// providers and regions
+func widespreadFailure() []TestResult {
+	return []TestResult{
+		{Endpoint: CloudEndpoint{Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS"}, TestType: "HTTP"},
+		{Endpoint: CloudEndpoint{Location: "Frankfurt, DE", Region: "eu-central-1", Provider: "AWS"}, TestType: "HTTP"},
+		{Endpoint: CloudEndpoint{Location: "Iowa, US", Region: "us-central1", Provider: "GCP"}, TestType: "PING"},
+	}
+}
+
+func TestLocalStatusHealthy(t *testing.T) {
+	tests := []struct {
+		name   string
+		status LocalStatus
+		want   bool
+	}{
+		{"not checked", LocalStatus{}, true},
+		{"all passed", LocalStatus{Checked: true, Gateway: "192.168.1.1", GatewayOK: true, ResolverOK: true, AnchorsOK: 1, AnchorsTotal: 2}, true},
+		{"no gateway found", LocalStatus{Checked: true, ResolverOK: true, AnchorsOK: 2, AnchorsTotal: 2}, true},
+		{"no anchors configured", LocalStatus{Checked: true, Gateway: "192.168.1.1", GatewayOK: true, ResolverOK: true}, true},
+		{"nothing but the resolver", LocalStatus{Checked: true, ResolverOK: true}, true},
+		{"gateway down", LocalStatus{Checked: true, Gateway: "192.168.1.1", ResolverOK: true, AnchorsOK: 2, AnchorsTotal: 2}, false},
+		{"resolver down", LocalStatus{Checked: true, ResolverOK: false}, false},
+		{"anchors down", LocalStatus{Checked: true, ResolverOK: true, AnchorsTotal: 2}, false},
+	}
+	for _, test := range tests {
+		if got := test.status.Healthy(); got != test.want {
+			t.Errorf("%s: Healthy() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestClassifyCycleIgnoresChecksWithoutTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		status LocalStatus
+	}{
+		{"no gateway found", LocalStatus{Checked: true, ResolverOK: true, AnchorsOK: 2, AnchorsTotal: 2}},
+		{"no anchors configured", LocalStatus{Checked: true, Gateway: "192.168.1.1", GatewayOK: true, ResolverOK: true}},
+	}
+	for _, test := range tests {
+		if got := classifyCycle(test.status, widespreadFailure(), 0.9); got != CycleNormal {
+			t.Errorf("%s: classifyCycle = %s, want %s", test.name, got, CycleNormal)
+		}
+	}
+}
+
+func TestClassifyCycleLocalOutage(t *testing.T) {
+	status := LocalStatus{Checked: true, Gateway: "192.168.1.1", AnchorsTotal: 2}
+	if got := classifyCycle(status, widespreadFailure(), 0.9); got != CycleLocalOutage {
+		t.Errorf("classifyCycle = %s, want %s", got, CycleLocalOutage)
+	}
+}
@@ -1,18 +1,36 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/smtp"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -31,9 +49,14 @@ const (
 type TestType string
 
 const (
-	TestTypePing TestType = "PING"
-	TestTypeDNS  TestType = "DNS"
-	TestTypeHTTP TestType = "HTTP"
+	TestTypePing       TestType = "PING"
+	TestTypeDNS        TestType = "DNS"
+	TestTypeHTTP       TestType = "HTTP"
+	TestTypeTCP        TestType = "TCP"
+	TestTypeTLS        TestType = "TLS"
+	TestTypeTraceroute TestType = "TRACEROUTE"
+	TestTypeExec       TestType = "EXEC"
+	TestTypeARP        TestType = "ARP"
 )
 
 // CloudEndpoint represents a cloud infrastructure endpoint
@@ -42,28 +65,66 @@ type CloudEndpoint struct {
 	Region   string
 	Provider string // AWS, Azure, GCP
 	Hostname string
-	TestPing bool
-	TestDNS  bool
-	TestHTTP bool
+	Labels   map[string]string
+	Probes   []ProbeSpec
+	Class    string // EndpointClassHome for home network endpoints, empty for cloud endpoints
+
+	// Coordinates place the endpoint on the dashboard's world map; looked up
+	// from Provider and Region when not configured
+	Coordinates *Coordinates
+}
+
+// Coordinates is a position in decimal degrees
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Valid reports whether the coordinates are on the globe
+func (c Coordinates) Valid() bool {
+	return c.Latitude >= -90 && c.Latitude <= 90 && c.Longitude >= -180 && c.Longitude <= 180
+}
+
+// VantagePoint is where the monitor runs; the dashboard's world map draws
+// lines from it to every endpoint
+type VantagePoint struct {
+	Name string
+	Coordinates
 }
 
+// defaultProbes is the DNS, PING and HTTP set run against every S3 endpoint
+var defaultProbes = []ProbeSpec{{Type: TestTypeDNS}, {Type: TestTypePing}, {Type: TestTypeHTTP}}
+
 // TestResult holds the result of a test
 type TestResult struct {
 	Endpoint     CloudEndpoint
 	TestType     TestType
 	Online       bool
+	Degraded     bool // reachable but reporting a problem (EXEC exit code 1)
 	ResponseTime time.Duration
 	ResolvedIP   string
 	Error        string
 	Timestamp    time.Time
 	Trend        string
 	Baseline     time.Duration
+	Loss         float64 // packet loss percentage (PING only)
+	Maintenance  string  // maintenance window or silence covering this test
+	LocalOutage  bool    // failure attributed to the local network, not the endpoint
+	StatusCode   int     // HTTP status code (HTTP only)
+	Phases       []ProbePhase
+	Elapsed      time.Duration      // wall-clock time spent running the test
+	Fields       map[string]float64 // probe-specific measurements
+	Details      map[string]string  // probe-specific text
+	Note         string             // short probe-specific detail for the console
+	Target       string             // set when the endpoint runs this probe type more than once
 }
 
 // HistoricalDataPoint represents a single measurement
 type HistoricalDataPoint struct {
 	Timestamp    time.Time
 	ResponseTime time.Duration
+	Maintenance  string
+	Failed       bool // the check failed; kept so maintenance covers failures too
 }
 
 // ServiceHistory tracks historical data for a service
@@ -101,6 +162,8 @@ func (hs *HistoryStore) LoadFromFile(filename string) error {
 	var rawData map[string][]struct {
 		Timestamp    time.Time
 		ResponseTime int64
+		Maintenance  string
+		Failed       bool
 	}
 
 	if err := json.Unmarshal(data, &rawData); err != nil {
@@ -117,6 +180,8 @@ func (hs *HistoryStore) LoadFromFile(filename string) error {
 			history.DataPoints = append(history.DataPoints, HistoricalDataPoint{
 				Timestamp:    point.Timestamp,
 				ResponseTime: time.Duration(point.ResponseTime),
+				Maintenance:  point.Maintenance,
+				Failed:       point.Failed,
 			})
 		}
 
@@ -134,17 +199,23 @@ func (hs *HistoryStore) SaveToFile(filename string) error {
 	rawData := make(map[string][]struct {
 		Timestamp    time.Time
 		ResponseTime int64
+		Maintenance  string `json:",omitempty"`
+		Failed       bool   `json:",omitempty"`
 	})
 
 	for serviceName, history := range hs.Services {
 		points := make([]struct {
 			Timestamp    time.Time
 			ResponseTime int64
+			Maintenance  string `json:",omitempty"`
+			Failed       bool   `json:",omitempty"`
 		}, len(history.DataPoints))
 
 		for i, point := range history.DataPoints {
 			points[i].Timestamp = point.Timestamp
 			points[i].ResponseTime = int64(point.ResponseTime)
+			points[i].Maintenance = point.Maintenance
+			points[i].Failed = point.Failed
 		}
 
 		rawData[serviceName] = points
@@ -158,8 +229,9 @@ func (hs *HistoryStore) SaveToFile(filename string) error {
 	return ioutil.WriteFile(filename, data, 0644)
 }
 
-// AddDataPoint adds a new measurement for a service
-func (hs *HistoryStore) AddDataPoint(serviceName string, timestamp time.Time, responseTime time.Duration) {
+// AddDataPoint adds a new measurement for a service, tagged with the
+// maintenance window or silence that covered it, if any
+func (hs *HistoryStore) AddDataPoint(serviceName string, timestamp time.Time, responseTime time.Duration, maintenance string, failed bool) {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
 
@@ -174,14 +246,28 @@ func (hs *HistoryStore) AddDataPoint(serviceName string, timestamp time.Time, re
 	history.DataPoints = append(history.DataPoints, HistoricalDataPoint{
 		Timestamp:    timestamp,
 		ResponseTime: responseTime,
+		Maintenance:  maintenance,
+		Failed:       failed,
 	})
 
-	if len(history.DataPoints) > 10 {
-		history.DataPoints = history.DataPoints[len(history.DataPoints)-10:]
+	// Keep the last 10 successful measurements for the baseline, and no more
+	// than 20 points in all so a long outage can't grow the file
+	successful := 0
+	for _, point := range history.DataPoints {
+		if !point.Failed {
+			successful++
+		}
+	}
+	for successful > 10 || len(history.DataPoints) > 20 {
+		if !history.DataPoints[0].Failed {
+			successful--
+		}
+		history.DataPoints = history.DataPoints[1:]
 	}
 }
 
-// GetBaseline calculates average of last 10 measurements
+// GetBaseline calculates average of last 10 measurements, ignoring failed
+// checks and those taken during maintenance
 func (hs *HistoryStore) GetBaseline(serviceName string) (time.Duration, int) {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
@@ -192,11 +278,18 @@ func (hs *HistoryStore) GetBaseline(serviceName string) (time.Duration, int) {
 	}
 
 	var total time.Duration
+	count := 0
 	for _, point := range history.DataPoints {
+		if point.Maintenance != "" || point.Failed {
+			continue
+		}
 		total += point.ResponseTime
+		count++
 	}
 
-	count := len(history.DataPoints)
+	if count == 0 {
+		return 0, 0
+	}
 	return total / time.Duration(count), count
 }
 
@@ -220,12 +313,208 @@ func CalculateTrend(current, baseline time.Duration, sampleCount int) string {
 	return "STEADY"
 }
 
+// ProbeSpec is one probe an endpoint runs, with its parameters
+type ProbeSpec struct {
+	Type    TestType
+	Params  map[string]string
+	Timeout Duration // overrides the prober's default timeout
+	Target  string   // tells repeated probes of one type apart; defaults to the port, URL, command or host
+}
+
+// ProbeParam describes one parameter a prober accepts
+type ProbeParam struct {
+	Name        string
+	Description string
+	Default     string
+	Required    bool
+}
+
+// ProbeOutcome is what a prober measured; runTest turns it into a TestResult
+type ProbeOutcome struct {
+	Online       bool
+	Degraded     bool
+	ResponseTime time.Duration
+	ResolvedIP   string
+	Error        string
+	Loss         float64
+	StatusCode   int
+	Phases       []ProbePhase
+	Fields       map[string]float64 // probe-specific measurements, e.g. tls_days_left
+	Details      map[string]string  // probe-specific text, e.g. tls_issuer
+	Note         string             // short detail shown on the console
+}
+
+// Prober implements one test type. Each prober declares its parameters,
+// default timeout and the extra result fields it fills in, and registers
+// itself with RegisterProber so endpoints can list it by type.
+type Prober interface {
+	Type() TestType
+	Title() string          // console section heading
+	Params() []ProbeParam   // accepted parameters
+	Timeout() time.Duration // default timeout
+	ResultFields() []string // keys set in ProbeOutcome.Fields and Details
+	Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome
+}
+
+// Registered probers, kept in registration order for console sections
+var (
+	probers     = make(map[TestType]Prober)
+	proberOrder []TestType
+)
+
+// RegisterProber makes a test type available to endpoints
+func RegisterProber(prober Prober) {
+	if _, exists := probers[prober.Type()]; !exists {
+		proberOrder = append(proberOrder, prober.Type())
+	}
+	probers[prober.Type()] = prober
+}
+
+// probeParams validates a spec against its prober's schema and fills in defaults
+func probeParams(prober Prober, spec ProbeSpec) (map[string]string, error) {
+	params := make(map[string]string)
+	known := make(map[string]bool)
+	for _, param := range prober.Params() {
+		known[param.Name] = true
+		value, ok := spec.Params[param.Name]
+		if !ok || value == "" {
+			if param.Required {
+				return nil, fmt.Errorf("%s probe needs parameter %q", prober.Type(), param.Name)
+			}
+			value = param.Default
+		}
+		params[param.Name] = value
+	}
+	for name := range spec.Params {
+		if !known[name] {
+			return nil, fmt.Errorf("%s probe has no parameter %q", prober.Type(), name)
+		}
+	}
+	return params, nil
+}
+
+// probeTarget names what a probe checks, so repeated probes of one type
+// get their own history: "8443", "db.lan:5432", a URL or a command name
+func probeTarget(params map[string]string) string {
+	switch {
+	case params["port"] != "" && params["host"] != "":
+		return net.JoinHostPort(params["host"], params["port"])
+	case params["port"] != "":
+		return params["port"]
+	case params["url"] != "":
+		return params["url"]
+	case params["command"] != "":
+		return filepath.Base(params["command"])
+	}
+	return params["host"]
+}
+
+// validateEndpoints drops probes with unknown types or invalid parameters.
+// The first probe of a type keeps the plain history key; each later probe of
+// that type gets a Target, and two entries with the same type and target are
+// rejected.
+func validateEndpoints(endpoints []CloudEndpoint) []CloudEndpoint {
+	valid := make([]CloudEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		var specs []ProbeSpec
+		seen := make(map[string]bool)
+		listed := make(map[TestType]bool)
+		for _, spec := range endpoint.Probes {
+			spec.Type = TestType(strings.ToUpper(string(spec.Type)))
+			prober, ok := probers[spec.Type]
+			var err error
+			if !ok {
+				err = fmt.Errorf("unknown probe type %q", spec.Type)
+			} else {
+				var params map[string]string
+				params, err = probeParams(prober, spec)
+				if err == nil && listed[spec.Type] && spec.Target == "" {
+					spec.Target = probeTarget(params)
+				}
+			}
+			key := string(spec.Type) + "|" + spec.Target
+			if err == nil && seen[key] {
+				err = fmt.Errorf("%s probe listed twice with the same target; set Target to tell them apart", spec.Type)
+			}
+			if err != nil {
+				fmt.Printf("%sWarning: %s [%s]: %v%s\n", ColorYellow, endpoint.Location, endpoint.Provider, err, ColorReset)
+				continue
+			}
+			seen[key] = true
+			listed[spec.Type] = true
+			specs = append(specs, spec)
+		}
+		endpoint.Probes = specs
+		if endpoint.Coordinates != nil && !endpoint.Coordinates.Valid() {
+			fmt.Printf("%sWarning: %s [%s]: coordinates %.4f, %.4f are out of range%s\n", ColorYellow, endpoint.Location, endpoint.Provider,
+				endpoint.Coordinates.Latitude, endpoint.Coordinates.Longitude, ColorReset)
+			endpoint.Coordinates = nil
+		}
+		if endpoint.Coordinates == nil {
+			endpoint.Coordinates = regionCoordinatesFor(endpoint.Provider, endpoint.Region)
+		}
+		valid = append(valid, endpoint)
+	}
+	return valid
+}
+
+// printProbers lists the registered probers and their parameters
+func printProbers() {
+	for _, testType := range proberOrder {
+		prober := probers[testType]
+		fmt.Printf("%s%s%s - %s (timeout %s)\n", ColorCyan, testType, ColorReset, prober.Title(), prober.Timeout())
+		for _, param := range prober.Params() {
+			detail := param.Description
+			if param.Required {
+				detail += " (required)"
+			} else if param.Default != "" {
+				detail += fmt.Sprintf(" (default %s)", param.Default)
+			}
+			fmt.Printf("  %-14s %s\n", param.Name, detail)
+		}
+		if fields := prober.ResultFields(); len(fields) > 0 {
+			fmt.Printf("  result fields: %s\n", strings.Join(fields, ", "))
+		}
+		fmt.Println()
+	}
+}
+
+// probeHost returns the host a probe targets: its "host" parameter or the endpoint hostname
+func probeHost(endpoint CloudEndpoint, params map[string]string) string {
+	if host := params["host"]; host != "" {
+		return host
+	}
+	return endpoint.Hostname
+}
+
+// phaseRecorder collects timed phases for a probe
+type phaseRecorder struct {
+	phases []ProbePhase
+}
+
+// record closes a phase that began at start
+func (pr *phaseRecorder) record(name string, start time.Time, err error) {
+	phase := ProbePhase{Name: name, Start: start, Duration: time.Since(start)}
+	if err != nil {
+		phase.Error = err.Error()
+	}
+	pr.phases = append(pr.phases, phase)
+}
+
 // resolveDNS resolves hostname to IP and measures time
-func resolveDNS(hostname string) (string, time.Duration, error) {
+func resolveDNS(ctx context.Context, hostname string) (string, time.Duration, error) {
 	resolver := &net.Resolver{}
 
 	start := time.Now()
-	ips, err := resolver.LookupHost(context.Background(), hostname)
+
+	// .local names belong to multicast DNS, which the Go resolver doesn't speak
+	if strings.HasSuffix(strings.ToLower(strings.TrimSuffix(hostname, ".")), ".local") {
+		if ip, err := resolveMDNS(ctx, hostname); err == nil {
+			return ip, time.Since(start), nil
+		}
+	}
+
+	ips, err := resolver.LookupHost(ctx, hostname)
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -239,396 +528,7402 @@ func resolveDNS(hostname string) (string, time.Duration, error) {
 	return ips[0], elapsed, nil
 }
 
-// pingIP pings an IP address (macOS compatible)
-func pingIP(ip string) (time.Duration, error) {
-	cmd := exec.Command("ping", "-c", "3", "-W", "5000", ip)
+// resolveMDNS asks the local network for the IPv4 address of a .local name
+// (RFC 6762). The query comes from an ephemeral port, so responders answer
+// it directly as a one-shot legacy query.
+func resolveMDNS(ctx context.Context, name string) (string, error) {
+	name = strings.TrimSuffix(name, ".")
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	query := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0} // ID, flags, one question
+	for _, label := range strings.Split(name, ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, 0, 1, 0x80, 1) // type A, class IN with the unicast-response bit
+	if _, err := conn.WriteToUDP(query, &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return "", fmt.Errorf("no mDNS answer for %s", name)
+		}
+		if ip := mdnsAnswer(buf[:n], name); ip != "" {
+			return ip, nil
+		}
+	}
+}
+
+// mdnsAnswer returns the IPv4 address of name from an mDNS response, if it has one
+func mdnsAnswer(msg []byte, name string) string {
+	if len(msg) < 12 || msg[2]&0x80 == 0 {
+		return ""
+	}
+	count := func(i int) int { return int(msg[i])<<8 | int(msg[i+1]) }
+	questions := count(4)
+	records := count(6) + count(8) + count(10)
+
+	offset := 12
+	for i := 0; i < questions; i++ {
+		_, next, ok := readDNSName(msg, offset)
+		if !ok {
+			return ""
+		}
+		offset = next + 4
+	}
+	for i := 0; i < records; i++ {
+		rrName, next, ok := readDNSName(msg, offset)
+		if !ok || next+10 > len(msg) {
+			return ""
+		}
+		rrType := count(next)
+		length := count(next + 8)
+		data := next + 10
+		if data+length > len(msg) {
+			return ""
+		}
+		if rrType == 1 && length == 4 && strings.EqualFold(rrName, name) {
+			return net.IP(msg[data : data+4]).String()
+		}
+		offset = data + length
+	}
+	return ""
+}
+
+// readDNSName decodes a possibly compressed name at offset, returning it and
+// the offset just past it
+func readDNSName(msg []byte, offset int) (string, int, bool) {
+	var labels []string
+	end := -1
+	for jumps := 0; jumps < 16; {
+		if offset >= len(msg) {
+			return "", 0, false
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, true
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, false
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = (length&0x3F)<<8 | int(msg[offset+1])
+			jumps++
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, false
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, false
+}
+
+// pingIP pings an IP address (macOS compatible) and reports packet loss
+func pingIP(ctx context.Context, ip string, count int) (time.Duration, float64, error) {
+	cmd := exec.CommandContext(ctx, "ping", "-c", strconv.Itoa(count), "-W", "5000", ip)
 
 	output, err := cmd.CombinedOutput()
+
+	// Both macOS and Linux report "3 packets transmitted, 2 received, 33.3% packet loss"
+	loss := 100.0
+	lossRe := regexp.MustCompile(`([\d.]+)% packet loss`)
+	if matches := lossRe.FindStringSubmatch(string(output)); len(matches) > 1 {
+		loss, _ = strconv.ParseFloat(matches[1], 64)
+	}
+
 	if err != nil {
-		return 0, fmt.Errorf("ping failed")
+		return 0, loss, fmt.Errorf("ping failed")
 	}
 
-	// macOS ping output format: "round-trip min/avg/max/stddev = 10.1/15.2/20.3/5.1 ms"
-	re := regexp.MustCompile(`round-trip[^=]+=\s*[\d.]+/([\d.]+)/`)
+	// macOS ping output format: "round-trip min/avg/max/stddev = 10.1/15.2/20.3/5.1 ms",
+	// Linux: "rtt min/avg/max/mdev = 10.1/15.2/20.3/5.1 ms"
+	re := regexp.MustCompile(`(?:round-trip|rtt)[^=]+=\s*[\d.]+/([\d.]+)/`)
 	matches := re.FindStringSubmatch(string(output))
 
 	if len(matches) > 1 {
 		avgMs, _ := strconv.ParseFloat(matches[1], 64)
-		return time.Duration(avgMs * float64(time.Millisecond)), nil
+		return time.Duration(avgMs * float64(time.Millisecond)), loss, nil
 	}
 
-	return 0, fmt.Errorf("could not parse ping output")
+	return 0, loss, fmt.Errorf("could not parse ping output")
+}
+
+// ProbePhase is the timing of one step inside a test, such as the TLS handshake
+type ProbePhase struct {
+	Name     string // dns, icmp, connect, tls, ttfb
+	Start    time.Time
+	Duration time.Duration
+	Error    string `json:",omitempty"`
 }
 
-// httpCheck performs HTTP HEAD request
-func httpCheck(url string) (time.Duration, error) {
+// httpCheck performs an HTTP request, recording the DNS, connect, TLS and
+// time-to-first-byte phases so failures can be attributed to a layer
+func httpCheck(ctx context.Context, method, url string) (time.Duration, []ProbePhase, int, error) {
 	client := http.Client{
-		Timeout: 10 * time.Second,
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		},
 	}
 
-	req, err := http.NewRequest("HEAD", url, nil)
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
-		return 0, err
+		return 0, nil, 0, err
 	}
 
+	var mu sync.Mutex
+	var phases []ProbePhase
+	starts := make(map[string]time.Time)
+
+	begin := func(name string) {
+		mu.Lock()
+		starts[name] = time.Now()
+		mu.Unlock()
+	}
+	end := func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		start, ok := starts[name]
+		if !ok {
+			return
+		}
+		delete(starts, name)
+		phase := ProbePhase{Name: name, Start: start, Duration: time.Since(start)}
+		if err != nil {
+			phase.Error = err.Error()
+		}
+		phases = append(phases, phase)
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { begin("dns") },
+		DNSDone:           func(info httptrace.DNSDoneInfo) { end("dns", info.Err) },
+		ConnectStart:      func(string, string) { begin("connect") },
+		ConnectDone:       func(_, _ string, err error) { end("connect", err) },
+		TLSHandshakeStart: func() { begin("tls") },
+		TLSHandshakeDone:  func(_ tls.ConnectionState, err error) { end("tls", err) },
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				begin("ttfb")
+			}
+		},
+		GotFirstResponseByte: func() { end("ttfb", nil) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
 	start := time.Now()
 	resp, err := client.Do(req)
 	elapsed := time.Since(start)
 
+	// A phase still open when the request failed is where it failed
+	mu.Lock()
+	openPhases := make([]string, 0, len(starts))
+	for name := range starts {
+		openPhases = append(openPhases, name)
+	}
+	mu.Unlock()
+	for _, name := range openPhases {
+		end(name, fmt.Errorf("did not complete"))
+	}
+
+	sort.Slice(phases, func(i, j int) bool {
+		return phases[i].Start.Before(phases[j].Start)
+	})
+
 	if err != nil {
-		return 0, err
+		return 0, phases, 0, err
 	}
 	defer resp.Body.Close()
 
-	return elapsed, nil
+	return elapsed, phases, resp.StatusCode, nil
 }
 
-// runTest executes a single test
-func runTest(endpoint CloudEndpoint, testType TestType, results chan<- TestResult, wg *sync.WaitGroup, history *HistoryStore) {
-	defer wg.Done()
+// dialPhases resolves and connects to host:port, recording the dns and
+// connect phases separately so a failure can be attributed to either
+func dialPhases(ctx context.Context, host, port string, rec *phaseRecorder) (net.Conn, string, time.Duration, error) {
+	start := time.Now()
+	ip, _, err := resolveDNS(ctx, host)
+	rec.record("dns", start, err)
+	if err != nil {
+		return nil, "", 0, err
+	}
 
-	var online bool
-	var responseTime time.Duration
-	var resolvedIP string
-	var errMsg string
+	connectStart := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+	connectTime := time.Since(connectStart)
+	rec.record("connect", connectStart, err)
+	if err != nil {
+		return nil, ip, 0, err
+	}
+	return conn, ip, connectTime, nil
+}
 
-	timestamp := time.Now()
+// dnsProber measures how long the hostname takes to resolve
+type dnsProber struct{}
 
-	switch testType {
-	case TestTypeDNS:
-		ip, duration, err := resolveDNS(endpoint.Hostname)
-		if err != nil {
-			errMsg = err.Error()
-		} else {
-			online = true
-			responseTime = duration
-			resolvedIP = ip
-		}
+func (dnsProber) Type() TestType         { return TestTypeDNS }
+func (dnsProber) Title() string          { return "DNS RESOLUTION TESTS" }
+func (dnsProber) Timeout() time.Duration { return 5 * time.Second }
+func (dnsProber) ResultFields() []string { return nil }
 
-	case TestTypePing:
-		// First resolve DNS
-		ip, _, err := resolveDNS(endpoint.Hostname)
-		if err != nil {
-			errMsg = "DNS resolution failed"
-		} else {
-			resolvedIP = ip
-			duration, err := pingIP(ip)
-			if err != nil {
-				errMsg = err.Error()
-			} else {
-				online = true
-				responseTime = duration
-			}
-		}
+func (dnsProber) Params() []ProbeParam {
+	return []ProbeParam{{Name: "host", Description: "hostname to resolve instead of the endpoint hostname"}}
+}
 
-	case TestTypeHTTP:
-		url := "https://" + endpoint.Hostname
-		duration, err := httpCheck(url)
-		if err != nil {
-			errMsg = err.Error()
-		} else {
-			online = true
-			responseTime = duration
-		}
+func (dnsProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
+
+	start := time.Now()
+	ip, duration, err := resolveDNS(ctx, probeHost(endpoint, params))
+	rec.record("dns", start, err)
+	outcome.Phases = rec.phases
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome
 	}
 
-	// Create service key for history
-	serviceKey := fmt.Sprintf("%s [%s] - %s", endpoint.Location, endpoint.Provider, testType)
+	outcome.Online = true
+	outcome.ResponseTime = duration
+	outcome.ResolvedIP = ip
+	return outcome
+}
 
-	// Calculate baseline and trend
-	baseline, sampleCount := history.GetBaseline(serviceKey)
-	trend := CalculateTrend(responseTime, baseline, sampleCount)
+// pingProber measures ICMP round-trip time and packet loss
+type pingProber struct{}
 
-	// Add to history if successful
-	if online {
-		history.AddDataPoint(serviceKey, timestamp, responseTime)
-	}
+func (pingProber) Type() TestType         { return TestTypePing }
+func (pingProber) Title() string          { return "ICMP PING TESTS (Network Layer Latency)" }
+func (pingProber) Timeout() time.Duration { return 20 * time.Second }
+func (pingProber) ResultFields() []string { return nil }
 
-	result := TestResult{
-		Endpoint:     endpoint,
-		TestType:     testType,
-		Online:       online,
-		ResponseTime: responseTime,
-		ResolvedIP:   resolvedIP,
-		Error:        errMsg,
-		Timestamp:    timestamp,
-		Trend:        trend,
-		Baseline:     baseline,
+func (pingProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "host", Description: "host to ping instead of the endpoint hostname"},
+		{Name: "count", Description: "echo requests to send", Default: "3"},
 	}
-
-	results <- result
 }
 
-// printResult displays a test result
-func printResult(result TestResult) {
-	var statusColor string
-	var status string
+func (pingProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
 
-	if result.Online {
-		statusColor = ColorGreen
-		status = "UP"
-	} else {
-		statusColor = ColorRed
-		status = "DOWN"
+	count, err := strconv.Atoi(params["count"])
+	if err != nil || count < 1 {
+		count = 3
 	}
 
-	// Determine trend color and symbol
-	var trendColor string
-	var trendSymbol string
-
-	switch result.Trend {
-	case "UP":
-		trendColor = ColorRed
-		trendSymbol = "↑"
-	case "DOWN":
-		trendColor = ColorGreen
-		trendSymbol = "↓"
-	case "STEADY":
-		trendColor = ColorYellow
-		trendSymbol = "→"
-	case "BASELINE":
-		trendColor = ColorCyan
-		trendSymbol = "●"
+	// First resolve DNS
+	start := time.Now()
+	ip, _, err := resolveDNS(ctx, probeHost(endpoint, params))
+	rec.record("dns", start, err)
+	if err != nil {
+		outcome.Error = "DNS resolution failed"
+		outcome.Loss = 100
+		outcome.Phases = rec.phases
+		return outcome
 	}
+	outcome.ResolvedIP = ip
 
-	locationStr := fmt.Sprintf("%s [%s]", result.Endpoint.Location, result.Endpoint.Provider)
-
-	if result.Online {
-		ms := result.ResponseTime.Milliseconds()
+	pingStart := time.Now()
+	duration, packetLoss, err := pingIP(ctx, ip, count)
+	rec.record("icmp", pingStart, err)
+	outcome.Phases = rec.phases
+	outcome.Loss = packetLoss
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome
+	}
 
-		fmt.Printf("%s[%s]%s %-35s %4dms %s[%s%s]%s",
-			statusColor, status, ColorReset,
-			locationStr, ms,
-			trendColor, result.Trend, trendSymbol, ColorReset)
+	outcome.Online = true
+	outcome.ResponseTime = duration
+	return outcome
+}
 
-		if result.Trend != "BASELINE" && result.Baseline > 0 {
-			baselineMs := result.Baseline.Milliseconds()
-			fmt.Printf(" (baseline: %dms)", baselineMs)
-		}
+// httpProber measures a full HTTPS request
+type httpProber struct{}
 
-		if result.ResolvedIP != "" && result.TestType == TestTypePing {
-			fmt.Printf(" [%s]", result.ResolvedIP)
-		}
+func (httpProber) Type() TestType         { return TestTypeHTTP }
+func (httpProber) Title() string          { return "HTTP/HTTPS TESTS (Application Layer Latency)" }
+func (httpProber) Timeout() time.Duration { return 10 * time.Second }
+func (httpProber) ResultFields() []string { return nil }
 
-		fmt.Println()
-	} else {
-		fmt.Printf("%s[%s]%s %-35s %s\n",
-			statusColor, status, ColorReset,
-			locationStr, result.Error)
+func (httpProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "url", Description: "URL to request instead of https://<hostname>"},
+		{Name: "method", Description: "HTTP method", Default: "HEAD"},
 	}
 }
 
-// writeToLog appends result to log file
-func writeToLog(result TestResult, logFile *os.File) {
-	if logFile == nil {
-		return
+func (httpProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	url := params["url"]
+	if url == "" {
+		url = "https://" + endpoint.Hostname
 	}
 
-	timestamp := result.Timestamp.Format("2006-01-02 15:04:05")
-	status := "UP"
-	if !result.Online {
-		status = "DOWN"
+	duration, phases, code, err := httpCheck(ctx, params["method"], url)
+	outcome := ProbeOutcome{Phases: phases, StatusCode: code}
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome
 	}
 
-	locationStr := fmt.Sprintf("%s [%s]", result.Endpoint.Location, result.Endpoint.Provider)
+	outcome.Online = true
+	outcome.ResponseTime = duration
+	return outcome
+}
 
-	logLine := fmt.Sprintf("%s | [%s] %-35s | Test: %s | Response: %dms | Trend: %s",
-		timestamp, status, locationStr,
-		result.TestType, result.ResponseTime.Milliseconds(), result.Trend)
+// tcpProber measures how long a TCP connection takes to open
+type tcpProber struct{}
 
-	if !result.Online {
-		logLine += fmt.Sprintf(" | Error: %s", result.Error)
-	}
+func (tcpProber) Type() TestType         { return TestTypeTCP }
+func (tcpProber) Title() string          { return "TCP CONNECT TESTS (Transport Layer Latency)" }
+func (tcpProber) Timeout() time.Duration { return 5 * time.Second }
+func (tcpProber) ResultFields() []string { return nil }
 
-	logLine += "\n"
-	logFile.WriteString(logLine)
+func (tcpProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "host", Description: "host to connect to instead of the endpoint hostname"},
+		{Name: "port", Description: "TCP port", Default: "443"},
+	}
 }
 
-// runHealthCheck performs one complete health check cycle
-func runHealthCheck(endpoints []CloudEndpoint, logFile *os.File, history *HistoryStore) {
-	results := make(chan TestResult, len(endpoints)*3)
-	var wg sync.WaitGroup
-
-	startTime := time.Now()
+func (tcpProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
 
-	// Launch tests
-	for _, endpoint := range endpoints {
-		if endpoint.TestDNS {
-			wg.Add(1)
-			go runTest(endpoint, TestTypeDNS, results, &wg, history)
-		}
-		if endpoint.TestPing {
-			wg.Add(1)
-			go runTest(endpoint, TestTypePing, results, &wg, history)
-		}
-		if endpoint.TestHTTP {
-			wg.Add(1)
-			go runTest(endpoint, TestTypeHTTP, results, &wg, history)
-		}
+	conn, ip, connectTime, err := dialPhases(ctx, probeHost(endpoint, params), params["port"], &rec)
+	outcome.Phases = rec.phases
+	outcome.ResolvedIP = ip
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome
 	}
+	conn.Close()
 
-	wg.Wait()
-	close(results)
+	outcome.Online = true
+	outcome.ResponseTime = connectTime
+	outcome.Note = "port " + params["port"]
+	return outcome
+}
 
-	// Organize results by test type
-	pingResults := []TestResult{}
-	dnsResults := []TestResult{}
-	httpResults := []TestResult{}
+// tlsProber measures the TLS handshake and checks the certificate
+type tlsProber struct{}
 
-	totalTests := 0
-	successfulTests := 0
-	var totalResponseTime time.Duration
+func (tlsProber) Type() TestType         { return TestTypeTLS }
+func (tlsProber) Title() string          { return "TLS HANDSHAKE TESTS (Certificate and Handshake Latency)" }
+func (tlsProber) Timeout() time.Duration { return 10 * time.Second }
 
-	for result := range results {
-		totalTests++
-		if result.Online {
-			successfulTests++
-			totalResponseTime += result.ResponseTime
-		}
+func (tlsProber) ResultFields() []string {
+	return []string{"tls_days_left", "tls_subject", "tls_issuer", "tls_version", "tls_not_after"}
+}
 
-		switch result.TestType {
-		case TestTypePing:
-			pingResults = append(pingResults, result)
+func (tlsProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "host", Description: "host to connect to instead of the endpoint hostname"},
+		{Name: "port", Description: "TCP port", Default: "443"},
+		{Name: "server_name", Description: "SNI name and name to verify (default: host)"},
+		{Name: "min_days", Description: "fail when the certificate expires within this many days", Default: "0"},
+		{Name: "insecure", Description: "skip certificate verification", Default: "false"},
+	}
+}
+
+func (tlsProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
+
+	host := probeHost(endpoint, params)
+	serverName := params["server_name"]
+	if serverName == "" {
+		serverName = host
+	}
+
+	conn, ip, _, err := dialPhases(ctx, host, params["port"], &rec)
+	outcome.ResolvedIP = ip
+	if err != nil {
+		outcome.Phases = rec.phases
+		outcome.Error = err.Error()
+		return outcome
+	}
+	defer conn.Close()
+
+	handshakeStart := time.Now()
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: params["insecure"] == "true",
+	})
+	err = tlsConn.HandshakeContext(ctx)
+	handshake := time.Since(handshakeStart)
+	if err != nil {
+		rec.record("tls", handshakeStart, err)
+		outcome.Phases = rec.phases
+		outcome.Error = "TLS handshake failed: " + err.Error()
+		return outcome
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		daysLeft := time.Until(cert.NotAfter).Hours() / 24
+		outcome.Fields = map[string]float64{"tls_days_left": math.Floor(daysLeft)}
+		outcome.Details = map[string]string{
+			"tls_subject":   cert.Subject.CommonName,
+			"tls_issuer":    cert.Issuer.CommonName,
+			"tls_version":   tls.VersionName(state.Version),
+			"tls_not_after": cert.NotAfter.UTC().Format(time.RFC3339),
+		}
+		outcome.Note = fmt.Sprintf("cert %.0fd left, %s", math.Floor(daysLeft), tls.VersionName(state.Version))
+
+		minDays, _ := strconv.ParseFloat(params["min_days"], 64)
+		if minDays > 0 && daysLeft < minDays {
+			err = fmt.Errorf("certificate for %s expires in %.0f days", serverName, daysLeft)
+			rec.record("tls", handshakeStart, err)
+			outcome.Phases = rec.phases
+			outcome.Error = err.Error()
+			return outcome
+		}
+	}
+	rec.record("tls", handshakeStart, nil)
+	outcome.Phases = rec.phases
+
+	outcome.Online = true
+	outcome.ResponseTime = handshake
+	return outcome
+}
+
+// tracerouteProber records the network path and the round-trip time to the last hop
+type tracerouteProber struct{}
+
+func (tracerouteProber) Type() TestType         { return TestTypeTraceroute }
+func (tracerouteProber) Title() string          { return "TRACEROUTE TESTS (Network Path)" }
+func (tracerouteProber) Timeout() time.Duration { return 60 * time.Second }
+func (tracerouteProber) ResultFields() []string { return []string{"hops", "path"} }
+
+func (tracerouteProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "host", Description: "host to trace instead of the endpoint hostname"},
+		{Name: "max_hops", Description: "maximum TTL", Default: "30"},
+	}
+}
+
+// tracerouteHopRe matches " 5  10.0.0.1  12.345 ms" from traceroute -n
+var tracerouteHopRe = regexp.MustCompile(`^\s*(\d+)\s+(\S+)\s+([\d.]+) ms`)
+
+func (tracerouteProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
+
+	start := time.Now()
+	ip, _, err := resolveDNS(ctx, probeHost(endpoint, params))
+	rec.record("dns", start, err)
+	if err != nil {
+		outcome.Phases = rec.phases
+		outcome.Error = "DNS resolution failed"
+		return outcome
+	}
+	outcome.ResolvedIP = ip
+
+	traceStart := time.Now()
+	cmd := exec.CommandContext(ctx, "traceroute", "-n", "-q", "1", "-w", "2", "-m", params["max_hops"], ip)
+	output, err := cmd.Output()
+	if err != nil && len(output) == 0 {
+		rec.record("trace", traceStart, err)
+		outcome.Phases = rec.phases
+		outcome.Error = "traceroute failed: " + err.Error()
+		return outcome
+	}
+
+	var path []string
+	var lastRTT time.Duration
+	reached := false
+	hops := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := tracerouteHopRe.FindStringSubmatch(line)
+		if len(matches) < 4 {
+			continue
+		}
+		hops, _ = strconv.Atoi(matches[1])
+		path = append(path, matches[2])
+		ms, _ := strconv.ParseFloat(matches[3], 64)
+		lastRTT = time.Duration(ms * float64(time.Millisecond))
+		reached = matches[2] == ip
+	}
+
+	outcome.Fields = map[string]float64{"hops": float64(hops)}
+	outcome.Details = map[string]string{"path": strings.Join(path, " ")}
+	outcome.Note = fmt.Sprintf("%d hops", hops)
+
+	if !reached {
+		err := fmt.Errorf("destination not reached after %d hops", hops)
+		rec.record("trace", traceStart, err)
+		outcome.Phases = rec.phases
+		outcome.Error = err.Error()
+		return outcome
+	}
+	rec.record("trace", traceStart, nil)
+	outcome.Phases = rec.phases
+
+	outcome.Online = true
+	outcome.ResponseTime = lastRTT
+	return outcome
+}
+
+// arpProber checks that a LAN device answers ARP, which works even when it drops ICMP
+type arpProber struct{}
+
+func (arpProber) Type() TestType         { return TestTypeARP }
+func (arpProber) Title() string          { return "ARP REACHABILITY TESTS (LAN Devices)" }
+func (arpProber) Timeout() time.Duration { return 5 * time.Second }
+func (arpProber) ResultFields() []string { return []string{"mac"} }
+
+func (arpProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "host", Description: "LAN address or .local name instead of the endpoint hostname"},
+	}
+}
+
+// arping output: iputils "Unicast reply from 192.168.1.1 [AA:BB:CC:DD:EE:FF]  1.234ms"
+// and Habets "60 bytes from aa:bb:cc:dd:ee:ff (192.168.1.1): index=0 time=1.234 msec"
+var (
+	arpingIputilsRe = regexp.MustCompile(`reply from \S+ \[([0-9A-Fa-f:]+)\]\s+([\d.]+)ms`)
+	arpingHabetsRe  = regexp.MustCompile(`from ([0-9A-Fa-f:]{17}) \(\S+\): index=\d+ time=([\d.]+) (usec|msec|sec)`)
+	arpTableRe      = regexp.MustCompile(`at ([0-9A-Fa-f:]{11,17}) on`)
+)
+
+// errArpingUnavailable means arping is missing or lacks raw socket permission
+var errArpingUnavailable = errors.New("arping unavailable")
+
+// arpingIP sends one ARP request with arping and returns the reply time and MAC address
+func arpingIP(ctx context.Context, ip string) (time.Duration, string, error) {
+	output, err := exec.CommandContext(ctx, "arping", "-c", "1", "-w", "2", ip).CombinedOutput()
+	text := string(output)
+
+	if matches := arpingIputilsRe.FindStringSubmatch(text); len(matches) > 2 {
+		ms, _ := strconv.ParseFloat(matches[2], 64)
+		return time.Duration(ms * float64(time.Millisecond)), strings.ToLower(matches[1]), nil
+	}
+	if matches := arpingHabetsRe.FindStringSubmatch(text); len(matches) > 3 {
+		value, _ := strconv.ParseFloat(matches[2], 64)
+		unit := map[string]time.Duration{"usec": time.Microsecond, "msec": time.Millisecond, "sec": time.Second}[matches[3]]
+		return time.Duration(value * float64(unit)), strings.ToLower(matches[1]), nil
+	}
+
+	if errors.Is(err, exec.ErrNotFound) || strings.Contains(text, "not permitted") || strings.Contains(text, "denied") {
+		return 0, "", errArpingUnavailable
+	}
+	return 0, "", fmt.Errorf("no ARP reply from %s", ip)
+}
+
+// neighborMAC looks up a complete entry for ip in the OS neighbor table
+func neighborMAC(ip string) string {
+	// Linux: /proc/net/arp has "IP HWtype Flags HWaddress Mask Device", flag 0x2 is complete
+	if data, err := ioutil.ReadFile("/proc/net/arp"); err == nil {
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[0] == ip && fields[2] != "0x0" && fields[3] != "00:00:00:00:00:00" {
+				return fields[3]
+			}
+		}
+		return ""
+	}
+
+	// macOS: "? (192.168.1.1) at aa:bb:cc:dd:ee:ff on en0 ifscope [ethernet]"
+	output, err := exec.Command("arp", "-n", ip).Output()
+	if err == nil {
+		if matches := arpTableRe.FindStringSubmatch(string(output)); len(matches) > 1 {
+			return matches[1]
+		}
+	}
+	return ""
+}
+
+// neighborLookup makes the OS resolve ip over ARP and waits for the neighbor
+// entry. Used when arping can't run; a still-cached entry counts as reachable.
+func neighborLookup(ctx context.Context, ip string) (time.Duration, string, error) {
+	start := time.Now()
+	// Any packet to the address makes the kernel send an ARP request
+	if conn, err := net.Dial("udp4", net.JoinHostPort(ip, "9")); err == nil {
+		conn.Write([]byte{0})
+		conn.Close()
+	}
+	for {
+		if mac := neighborMAC(ip); mac != "" {
+			return time.Since(start), mac, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, "", fmt.Errorf("no ARP entry for %s", ip)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func (arpProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
+
+	start := time.Now()
+	ip, _, err := resolveDNS(ctx, probeHost(endpoint, params))
+	rec.record("dns", start, err)
+	if err != nil {
+		outcome.Phases = rec.phases
+		outcome.Error = "DNS resolution failed"
+		return outcome
+	}
+	outcome.ResolvedIP = ip
+	if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+		outcome.Phases = rec.phases
+		outcome.Error = "ARP needs an IPv4 address, got " + ip
+		return outcome
+	}
+
+	arpStart := time.Now()
+	rtt, mac, err := arpingIP(ctx, ip)
+	if err == errArpingUnavailable {
+		rtt, mac, err = neighborLookup(ctx, ip)
+		outcome.Note = "neighbor table"
+	}
+	rec.record("arp", arpStart, err)
+	outcome.Phases = rec.phases
+	if err != nil {
+		outcome.Note = ""
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	outcome.Online = true
+	outcome.ResponseTime = rtt
+	outcome.Details = map[string]string{"mac": mac}
+	if outcome.Note == "" {
+		outcome.Note = mac
+	} else {
+		outcome.Note = mac + ", " + outcome.Note
+	}
+	return outcome
+}
+
+// execProber runs an external check command using the Nagios plugin
+// conventions: exit code 0 is OK, 1 is degraded, 2 is down and anything else
+// (3 is UNKNOWN) is a failed check. The first line of output is the status
+// text, optionally followed by perfdata after "|". A command may instead
+// print a JSON object with message, latency_ms and metrics keys.
+type execProber struct{}
+
+func (execProber) Type() TestType         { return TestTypeExec }
+func (execProber) Title() string          { return "EXEC CHECKS (Custom Scripts)" }
+func (execProber) Timeout() time.Duration { return 30 * time.Second }
+
+// ResultFields lists the fixed keys; every perfdata label or JSON metric is added as well
+func (execProber) ResultFields() []string { return []string{"exit_code", "output"} }
+
+func (execProber) Params() []ProbeParam {
+	return []ProbeParam{
+		{Name: "command", Description: "program to run", Required: true},
+		{Name: "args", Description: "arguments, split on spaces unless quoted; {hostname}, {region}, {location} and {provider} are replaced"},
+		{Name: "latency", Description: "perfdata label or JSON metric reported as the response time (default: first time value, else run time)"},
+	}
+}
+
+// perfValue is one perfdata metric, e.g. 'rta'=12.5ms;100;500;0
+type perfValue struct {
+	Label string
+	Value float64
+	Unit  string
+}
+
+// perfValueRe splits the value of a perfdata item into number and unit of measure
+var perfValueRe = regexp.MustCompile(`^(-?[\d.]+(?:[eE][-+]?\d+)?)([a-zA-Z%]*)$`)
+
+// execJSONOutput is the JSON form of check output
+type execJSONOutput struct {
+	Message   string             `json:"message"`
+	LatencyMs *float64           `json:"latency_ms"`
+	Metrics   map[string]float64 `json:"metrics"`
+}
+
+// splitArgs splits a command line on spaces, keeping single- or double-quoted text together
+func splitArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// parsePerfdata reads space-separated label=value[UOM];warn;crit;min;max items
+func parsePerfdata(perfdata string) []perfValue {
+	var values []perfValue
+	for _, item := range splitArgs(perfdata) {
+		eq := strings.LastIndex(item, "=")
+		if eq <= 0 {
+			continue
+		}
+		value := strings.SplitN(item[eq+1:], ";", 2)[0]
+		matches := perfValueRe.FindStringSubmatch(value)
+		if matches == nil {
+			continue
+		}
+		number, err := strconv.ParseFloat(matches[1], 64)
+		if err != nil {
+			continue
+		}
+		values = append(values, perfValue{Label: item[:eq], Value: number, Unit: matches[2]})
+	}
+	return values
+}
+
+// parsePluginOutput splits Nagios plugin output into the status text and perfdata.
+// Perfdata follows the first "|" on the first line and any "|" in the long text.
+func parsePluginOutput(output string) (string, []perfValue) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	text, perfdata, _ := strings.Cut(lines[0], "|")
+	for _, line := range lines[1:] {
+		if _, more, ok := strings.Cut(line, "|"); ok {
+			perfdata += " " + more
+		}
+	}
+	return strings.TrimSpace(text), parsePerfdata(perfdata)
+}
+
+// perfMilliseconds converts a time-valued perfdata metric to milliseconds
+func perfMilliseconds(value perfValue) (float64, bool) {
+	switch strings.ToLower(value.Unit) {
+	case "s":
+		return value.Value * 1000, true
+	case "ms":
+		return value.Value, true
+	case "us":
+		return value.Value / 1000, true
+	}
+	return value.Value, false
+}
+
+// fieldName turns a perfdata label into a result field key
+func fieldName(label string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(label))
+	return strings.Trim(name, "_")
+}
+
+// perfFieldName turns a perfdata label or JSON metric name into a result
+// field key. The perf_ prefix keeps script metrics from shadowing the up,
+// loss or status_code fields in the outputs; a label with no usable
+// characters gives "" and is dropped.
+func perfFieldName(label string) string {
+	if name := fieldName(label); name != "" {
+		return "perf_" + name
+	}
+	return ""
+}
+
+func (execProber) Probe(ctx context.Context, endpoint CloudEndpoint, params map[string]string) ProbeOutcome {
+	var outcome ProbeOutcome
+	var rec phaseRecorder
+
+	replacer := strings.NewReplacer(
+		"{hostname}", endpoint.Hostname,
+		"{region}", endpoint.Region,
+		"{location}", endpoint.Location,
+		"{provider}", endpoint.Provider,
+	)
+	var args []string
+	for _, arg := range splitArgs(params["args"]) {
+		args = append(args, replacer.Replace(arg))
+	}
+
+	cmd := exec.CommandContext(ctx, params["command"], args...)
+	cmd.WaitDelay = 2 * time.Second // don't wait forever on children holding stdout
+	cmd.Env = append(os.Environ(),
+		"ENDPOINT_LOCATION="+endpoint.Location,
+		"ENDPOINT_REGION="+endpoint.Region,
+		"ENDPOINT_PROVIDER="+endpoint.Provider,
+		"ENDPOINT_HOSTNAME="+endpoint.Hostname,
+	)
+	for key, value := range endpoint.Labels {
+		cmd.Env = append(cmd.Env, "ENDPOINT_LABEL_"+strings.ToUpper(fieldName(key))+"="+value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	stdout, err := cmd.Output()
+	runTime := time.Since(start)
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			rec.record("exec", start, err)
+			outcome.Phases = rec.phases
+			deadline, _ := ctx.Deadline()
+			outcome.Error = fmt.Sprintf("check timed out after %s", deadline.Sub(start).Round(time.Second))
+			return outcome
+		case errors.As(err, &exitErr):
+			exitCode = exitErr.ExitCode()
+		default:
+			rec.record("exec", start, err)
+			outcome.Phases = rec.phases
+			outcome.Error = "could not run check: " + err.Error()
+			return outcome
+		}
+	}
+
+	// Read the status text, latency and metrics from JSON or plugin output
+	var text string
+	var latencyMs *float64
+	fields := map[string]float64{"exit_code": float64(exitCode)}
+	var jsonOutput execJSONOutput
+	if trimmed := bytes.TrimSpace(stdout); bytes.HasPrefix(trimmed, []byte("{")) && json.Unmarshal(trimmed, &jsonOutput) == nil {
+		text = jsonOutput.Message
+		latencyMs = jsonOutput.LatencyMs
+		for name, value := range jsonOutput.Metrics {
+			if name := perfFieldName(name); name != "" {
+				fields[name] = value
+			}
+		}
+	} else {
+		var perf []perfValue
+		text, perf = parsePluginOutput(string(stdout))
+		for _, value := range perf {
+			name := perfFieldName(value.Label)
+			if name == "" {
+				continue
+			}
+			ms, isTime := perfMilliseconds(value)
+			fields[name] = ms
+			if isTime && latencyMs == nil {
+				latencyMs = &ms
+			}
+		}
+	}
+	if name := params["latency"]; name != "" {
+		if value, ok := fields[perfFieldName(name)]; ok {
+			latencyMs = &value
+		}
+	}
+	if text == "" {
+		text = strings.TrimSpace(strings.SplitN(stderr.String(), "\n", 2)[0])
+	}
+
+	outcome.Fields = fields
+	if text != "" {
+		outcome.Details = map[string]string{"output": text}
+	}
+	outcome.ResponseTime = runTime
+	if latencyMs != nil {
+		outcome.ResponseTime = time.Duration(*latencyMs * float64(time.Millisecond))
+	}
+
+	switch exitCode {
+	case 0:
+		rec.record("exec", start, nil)
+		outcome.Online = true
+		outcome.Note = text
+	case 1:
+		rec.record("exec", start, nil)
+		outcome.Online = true
+		outcome.Degraded = true
+		outcome.Note = text
+	default:
+		if text == "" {
+			text = "CRITICAL"
+			if exitCode != 2 {
+				text = "UNKNOWN"
+			}
+		}
+		if exitCode != 2 {
+			text += fmt.Sprintf(" (exit %d)", exitCode)
+		}
+		err = errors.New(text)
+		rec.record("exec", start, err)
+		outcome.ResponseTime = 0
+		outcome.Error = text
+	}
+	outcome.Phases = rec.phases
+	return outcome
+}
+
+func init() {
+	RegisterProber(pingProber{})
+	RegisterProber(dnsProber{})
+	RegisterProber(httpProber{})
+	RegisterProber(tcpProber{})
+	RegisterProber(tlsProber{})
+	RegisterProber(tracerouteProber{})
+	RegisterProber(arpProber{})
+	RegisterProber(execProber{})
+}
+
+// serviceKeyFor builds the history key for an endpoint test,
+// e.g. "Tokyo, JP [AWS] - PING", or "Home Router [Home] - TCP:8443"
+// for a later probe of the same type
+func serviceKeyFor(endpoint CloudEndpoint, testType TestType, target string) string {
+	if target != "" {
+		return fmt.Sprintf("%s [%s] - %s:%s", endpoint.Location, endpoint.Provider, testType, target)
+	}
+	return fmt.Sprintf("%s [%s] - %s", endpoint.Location, endpoint.Provider, testType)
+}
+
+// runTest executes a single probe
+func runTest(endpoint CloudEndpoint, spec ProbeSpec, maintenance string, results chan<- TestResult, wg *sync.WaitGroup, history *HistoryStore, metrics *Metrics) {
+	defer wg.Done()
+	metrics.ProbeStarted()
+	defer metrics.ProbeFinished()
+
+	timestamp := time.Now()
+
+	var outcome ProbeOutcome
+	prober, ok := probers[spec.Type]
+	if !ok {
+		outcome.Error = fmt.Sprintf("unknown probe type %q", spec.Type)
+	} else if params, err := probeParams(prober, spec); err != nil {
+		outcome.Error = err.Error()
+	} else {
+		timeout := time.Duration(spec.Timeout)
+		if timeout <= 0 {
+			timeout = prober.Timeout()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		outcome = prober.Probe(ctx, endpoint, params)
+		cancel()
+	}
+
+	elapsed := time.Since(timestamp)
+
+	// Create service key for history
+	serviceKey := serviceKeyFor(endpoint, spec.Type, spec.Target)
+
+	// Calculate baseline and trend
+	baseline, sampleCount := history.GetBaseline(serviceKey)
+	trend := CalculateTrend(outcome.ResponseTime, baseline, sampleCount)
+
+	// Failures are kept too, so a maintenance tag covers them; the baseline
+	// only uses successful checks
+	history.AddDataPoint(serviceKey, timestamp, outcome.ResponseTime, maintenance, !outcome.Online)
+
+	result := TestResult{
+		Endpoint:     endpoint,
+		TestType:     spec.Type,
+		Online:       outcome.Online,
+		Degraded:     outcome.Degraded,
+		ResponseTime: outcome.ResponseTime,
+		ResolvedIP:   outcome.ResolvedIP,
+		Error:        outcome.Error,
+		Timestamp:    timestamp,
+		Trend:        trend,
+		Baseline:     baseline,
+		Loss:         outcome.Loss,
+		Maintenance:  maintenance,
+		StatusCode:   outcome.StatusCode,
+		Phases:       outcome.Phases,
+		Elapsed:      elapsed,
+		Fields:       outcome.Fields,
+		Details:      outcome.Details,
+		Note:         outcome.Note,
+		Target:       spec.Target,
+	}
+
+	results <- result
+}
+
+// printResult displays a test result
+func printResult(result TestResult) {
+	var statusColor string
+	var status string
+
+	switch {
+	case result.Degraded:
+		statusColor = ColorYellow
+		status = "DEGRADED"
+	case result.Online:
+		statusColor = ColorGreen
+		status = "UP"
+	default:
+		statusColor = ColorRed
+		status = "DOWN"
+	}
+
+	// Determine trend color and symbol
+	var trendColor string
+	var trendSymbol string
+
+	switch result.Trend {
+	case "UP":
+		trendColor = ColorRed
+		trendSymbol = "↑"
+	case "DOWN":
+		trendColor = ColorGreen
+		trendSymbol = "↓"
+	case "STEADY":
+		trendColor = ColorYellow
+		trendSymbol = "→"
+	case "BASELINE":
+		trendColor = ColorCyan
+		trendSymbol = "●"
+	}
+
+	locationStr := fmt.Sprintf("%s [%s]", result.Endpoint.Location, result.Endpoint.Provider)
+	if result.Target != "" {
+		locationStr += " " + result.Target
+	}
+
+	if result.Online {
+		ms := result.ResponseTime.Milliseconds()
+
+		fmt.Printf("%s[%s]%s %-35s %4dms %s[%s%s]%s",
+			statusColor, status, ColorReset,
+			locationStr, ms,
+			trendColor, result.Trend, trendSymbol, ColorReset)
+
+		if result.Trend != "BASELINE" && result.Baseline > 0 {
+			baselineMs := result.Baseline.Milliseconds()
+			fmt.Printf(" (baseline: %dms)", baselineMs)
+		}
+
+		if result.ResolvedIP != "" && result.TestType == TestTypePing {
+			fmt.Printf(" [%s]", result.ResolvedIP)
+		}
+
+		if result.Note != "" {
+			fmt.Printf(" (%s)", result.Note)
+		}
+	} else {
+		fmt.Printf("%s[%s]%s %-35s %s",
+			statusColor, status, ColorReset,
+			locationStr, result.Error)
+	}
+
+	if result.Maintenance != "" {
+		fmt.Printf(" %s[MAINTENANCE: %s]%s", ColorBlue, result.Maintenance, ColorReset)
+	}
+
+	if result.LocalOutage {
+		fmt.Printf(" %s[LOCAL NETWORK]%s", ColorYellow, ColorReset)
+	}
+
+	fmt.Println()
+}
+
+// writeToLog appends result to log file
+func writeToLog(result TestResult, logFile io.Writer) {
+	if logFile == nil {
+		return
+	}
+
+	timestamp := result.Timestamp.Format("2006-01-02 15:04:05")
+	status := resultStatus(result)
+
+	locationStr := fmt.Sprintf("%s [%s]", result.Endpoint.Location, result.Endpoint.Provider)
+
+	logLine := fmt.Sprintf("%s | [%s] %-35s | Test: %s | Response: %dms | Trend: %s",
+		timestamp, status, locationStr,
+		result.TestType, result.ResponseTime.Milliseconds(), result.Trend)
+
+	if result.Target != "" {
+		logLine += fmt.Sprintf(" | Target: %s", result.Target)
+	}
+
+	if !result.Online {
+		logLine += fmt.Sprintf(" | Error: %s", result.Error)
+	} else if result.Degraded {
+		logLine += fmt.Sprintf(" | Detail: %s", result.Note)
+	}
+
+	if result.Maintenance != "" {
+		logLine += fmt.Sprintf(" | Maintenance: %s", result.Maintenance)
+	}
+
+	if result.LocalOutage {
+		logLine += " | Cycle: LOCAL_OUTAGE"
+	}
+
+	logLine += "\n"
+	io.WriteString(logFile, logLine)
+}
+
+// Duration is a time.Duration that reads "30s" / "5m" style strings from JSON
+type Duration time.Duration
+
+// UnmarshalJSON accepts either a duration string or a number of nanoseconds.
+// Strings may also use a "d" suffix for whole days, e.g. "30d".
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") {
+			*d = Duration(time.Duration(days) * 24 * time.Hour)
+			return nil
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var ns int64
+	if err := json.Unmarshal(b, &ns); err != nil {
+		return fmt.Errorf("invalid duration %s", string(b))
+	}
+	*d = Duration(ns)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string such as "5m0s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// MonitorConfig holds optional settings loaded from monitor_config.json
+type MonitorConfig struct {
+	Alerts      AlertConfig
+	Maintenance []MaintenanceWindow
+	LocalChecks LocalCheckConfig
+	History     HistoryConfig
+	SLOs        []SLOConfig
+	Metrics     MetricsConfig
+	OTLP        OTLPConfig
+	Influx      InfluxConfig
+	Graphite    GraphiteConfig
+	Sinks       map[string]SinkOptions
+	Endpoints   []CloudEndpoint // extra endpoints, each listing its probes
+	Home        HomeConfig
+	Log         LogConfig
+	Live        LiveConfig
+	Vantage     *VantagePoint // where the monitor runs, for the dashboard's world map
+}
+
+// loadConfig reads the monitor configuration, returning defaults when the file is absent
+func loadConfig(filename string) (*MonitorConfig, error) {
+	config := &MonitorConfig{
+		LocalChecks: LocalCheckConfig{
+			ResolverQuery: "aws.amazon.com",
+			Anchors:       []string{"1.1.1.1:443", "8.8.8.8:443"},
+			FailureRatio:  0.9,
+		},
+		Log: LogConfig{File: "cloud_latency.log", Format: LogFormatText},
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// EndpointMatcher selects endpoint tests by their CloudEndpoint fields.
+// Empty fields match everything; values may use shell-style wildcards.
+type EndpointMatcher struct {
+	Location string
+	Provider string
+	Region   string
+	Hostname string
+	TestType string
+	Labels   map[string]string
+}
+
+// matchField compares a single matcher value against an endpoint field
+func matchField(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && matched
+}
+
+// Matches reports whether the matcher selects the given endpoint test
+func (m EndpointMatcher) Matches(endpoint CloudEndpoint, testType TestType) bool {
+	for key, pattern := range m.Labels {
+		if !matchField(pattern, endpoint.Labels[key]) {
+			return false
+		}
+	}
+
+	return matchField(m.Location, endpoint.Location) &&
+		matchField(m.Provider, endpoint.Provider) &&
+		matchField(m.Region, endpoint.Region) &&
+		matchField(m.Hostname, endpoint.Hostname) &&
+		matchField(m.TestType, string(testType))
+}
+
+// Alert rule kinds
+const (
+	AlertRuleDown    = "down"
+	AlertRuleTrendUp = "trend_up"
+	AlertRuleLoss    = "loss"
+	AlertRuleBurn    = "burn_rate"
+)
+
+// AlertConfig defines alert rules and the notifiers they deliver to
+type AlertConfig struct {
+	Rules     []AlertRule
+	Notifiers []NotifierConfig
+}
+
+// AlertRule describes a condition evaluated against every matching test result
+type AlertRule struct {
+	Name        string
+	Kind        string // down, trend_up, loss, burn_rate
+	Match       EndpointMatcher
+	Consecutive int      // down: failed checks in a row before alerting
+	For         Duration // how long the condition must hold before firing
+	Threshold   float64  // loss: packet loss percentage; burn_rate: burn rate multiple
+	Notifiers   []string // notifier names; empty sends to every notifier
+
+	// burn_rate: fires when every listed window burns faster than Threshold
+	SLO         string
+	BurnWindows []Duration
+
+	// Hysteresis: a firing alert only resolves once it is clearly healthy again
+	RecoverAfter     int     // down, trend_up: healthy checks in a row before resolving
+	ResolveThreshold float64 // loss: packet loss must drop to this level to resolve
+
+	RepeatInterval Duration // re-send a firing alert this often; 0 sends it once
+	FlapWindow     Duration // window used to count condition changes (default 30m)
+	FlapThreshold  int      // condition changes within FlapWindow that mark flapping; 0 disables
+}
+
+// NotifierConfig configures one notification backend
+type NotifierConfig struct {
+	Name string
+	Type string // webhook, slack, teams, email, exec
+
+	// webhook, slack, teams
+	URL     string
+	Headers map[string]string
+
+	// email
+	SMTPHost string
+	SMTPPort int
+	Username string
+	Password string
+	From     string
+	To       []string
+
+	// exec
+	Command string
+	Args    []string
+
+	Timeout Duration
+}
+
+// Alert is a notification raised by a rule for one endpoint test
+type Alert struct {
+	Rule      string
+	Kind      string
+	Service   string
+	Endpoint  CloudEndpoint
+	TestType  TestType
+	Status    string
+	Message   string
+	Value     float64
+	Duration  Duration // how long the alert has been firing
+	Timestamp time.Time
+}
+
+// Notifier delivers alerts to an external system
+type Notifier interface {
+	Name() string
+	Notify(alert Alert) error
+}
+
+// newNotifier builds a notifier from its configuration
+func newNotifier(config NotifierConfig) (Notifier, error) {
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	switch config.Type {
+	case "webhook", "slack", "teams":
+		if config.URL == "" {
+			return nil, fmt.Errorf("notifier %q: URL is required", config.Name)
+		}
+		return &WebhookNotifier{
+			name:    config.Name,
+			url:     config.URL,
+			format:  config.Type,
+			headers: config.Headers,
+			client:  &http.Client{Timeout: timeout},
+		}, nil
+
+	case "email":
+		if config.SMTPHost == "" || len(config.To) == 0 {
+			return nil, fmt.Errorf("notifier %q: SMTPHost and To are required", config.Name)
+		}
+		port := config.SMTPPort
+		if port == 0 {
+			port = 25
+		}
+		return &EmailNotifier{
+			name:     config.Name,
+			addr:     net.JoinHostPort(config.SMTPHost, strconv.Itoa(port)),
+			host:     config.SMTPHost,
+			username: config.Username,
+			password: config.Password,
+			from:     config.From,
+			to:       config.To,
+		}, nil
+
+	case "exec":
+		if config.Command == "" {
+			return nil, fmt.Errorf("notifier %q: Command is required", config.Name)
+		}
+		return &ExecNotifier{
+			name:    config.Name,
+			command: config.Command,
+			args:    config.Args,
+			timeout: timeout,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("notifier %q: unknown type %q", config.Name, config.Type)
+}
+
+// WebhookNotifier posts alerts as generic JSON, Slack or Teams payloads
+type WebhookNotifier struct {
+	name    string
+	url     string
+	format  string
+	headers map[string]string
+	client  *http.Client
+}
+
+// Name returns the configured notifier name
+func (n *WebhookNotifier) Name() string { return n.name }
+
+// Notify posts the alert to the webhook URL
+func (n *WebhookNotifier) Notify(alert Alert) error {
+	var payload interface{}
+
+	switch n.format {
+	case "slack":
+		payload = map[string]string{"text": alertSubject(alert) + "\n" + alert.Message}
+	case "teams":
+		payload = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "http://schema.org/extensions",
+			"themeColor": alertColor(alert),
+			"summary":    alertSubject(alert),
+			"title":      alertSubject(alert),
+			"text":       alert.Message,
+		}
+	default:
+		payload = alert
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailNotifier sends alerts through an SMTP server
+type EmailNotifier struct {
+	name     string
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// Name returns the configured notifier name
+func (n *EmailNotifier) Name() string { return n.name }
+
+// Notify sends the alert as a plain text email
+func (n *EmailNotifier) Notify(alert Alert) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", alertSubject(alert))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(alert.Message + "\r\n")
+
+	var auth smtp.Auth
+	if n.username != "" {
+		auth = smtp.PlainAuth("", n.username, n.password, n.host)
+	}
+
+	return smtp.SendMail(n.addr, auth, n.from, n.to, []byte(msg.String()))
+}
+
+// ExecNotifier runs a command for every alert, passing it as JSON on stdin
+// and as ALERT_* environment variables
+type ExecNotifier struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// Name returns the configured notifier name
+func (n *ExecNotifier) Name() string { return n.name }
+
+// Notify runs the configured command
+func (n *ExecNotifier) Notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, n.command, n.args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"ALERT_RULE="+alert.Rule,
+		"ALERT_KIND="+alert.Kind,
+		"ALERT_STATUS="+alert.Status,
+		"ALERT_SERVICE="+alert.Service,
+		"ALERT_MESSAGE="+alert.Message,
+		"ALERT_VALUE="+strconv.FormatFloat(alert.Value, 'f', -1, 64),
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// alertSubject builds a one-line summary for notification titles
+func alertSubject(alert Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", alert.Status, alert.Rule, alert.Service)
+}
+
+// alertColor picks the Teams card color for an alert status
+func alertColor(alert Alert) string {
+	switch alert.Status {
+	case "FIRING":
+		return "D32F2F"
+	case "FLAPPING":
+		return "F57C00"
+	}
+	return "388E3C"
+}
+
+// Alert lifecycle states
+const (
+	AlertStateInactive = "inactive"
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// AlertState tracks one rule/service pair through pending, firing and resolved
+type AlertState struct {
+	Rule            string
+	Service         string
+	State           string
+	ConsecutiveDown int
+	ClearStreak     int
+	ConditionMet    bool
+	PendingSince    time.Time
+	FiringSince     time.Time
+	LastNotified    time.Time
+	Transitions     []time.Time // condition changes inside the flap window
+	Flapping        bool
+}
+
+// AlertEngine evaluates alert rules against test results and dispatches notifications
+type AlertEngine struct {
+	rules     []AlertRule
+	notifiers []Notifier
+	state     map[string]*AlertState
+	mu        sync.Mutex
+}
+
+// NewAlertEngine creates an alert engine from configuration. The SLO
+// definitions are needed to check the burn_rate rules against them. Rules and
+// notifiers that fail validation are left out and returned as problems.
+func NewAlertEngine(config AlertConfig, slos []SLOConfig) (*AlertEngine, []error) {
+	engine := &AlertEngine{
+		state: make(map[string]*AlertState),
+	}
+
+	// A bad rule or notifier is skipped so it can't silence the others
+	var problems []error
+	for _, notifierConfig := range config.Notifiers {
+		notifier, err := newNotifier(notifierConfig)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		engine.notifiers = append(engine.notifiers, notifier)
+	}
+
+	for _, rule := range config.Rules {
+		if err := validateRule(rule, slos); err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		engine.rules = append(engine.rules, rule)
+	}
+
+	return engine, problems
+}
+
+// validateRule checks that a rule has a known kind and what that kind needs
+func validateRule(rule AlertRule, slos []SLOConfig) error {
+	switch rule.Kind {
+	case AlertRuleDown, AlertRuleTrendUp, AlertRuleLoss:
+		return nil
+	case AlertRuleBurn:
+		if rule.SLO == "" || len(rule.BurnWindows) == 0 {
+			return fmt.Errorf("alert rule %q: burn_rate rules need SLO and BurnWindows", rule.Name)
+		}
+		return validateBurnRule(rule, slos)
+	}
+	return fmt.Errorf("alert rule %q: unknown kind %q", rule.Name, rule.Kind)
+}
+
+// validateBurnRule checks that a burn_rate rule names a configured SLO and
+// only uses windows that SLO reports; any other window would read as a
+// burn rate of zero and the rule could never fire
+func validateBurnRule(rule AlertRule, slos []SLOConfig) error {
+	for _, slo := range slos {
+		if slo.Name != rule.SLO {
+			continue
+		}
+
+		windows := slo.BurnRateWindows
+		if len(windows) == 0 {
+			windows = defaultBurnRateWindows
+		}
+		for _, window := range rule.BurnWindows {
+			reported := false
+			for _, w := range windows {
+				if w == window {
+					reported = true
+				}
+			}
+			if !reported {
+				names := make([]string, len(windows))
+				for i, w := range windows {
+					names[i] = formatWindow(w)
+				}
+				return fmt.Errorf("alert rule %q: BurnWindows %s is not one of SLO %q BurnRateWindows (%s)",
+					rule.Name, formatWindow(window), slo.Name, strings.Join(names, ", "))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("alert rule %q: unknown SLO %q", rule.Name, rule.SLO)
+}
+
+// LoadFromFile restores alert state saved by a previous run
+func (ae *AlertEngine) LoadFromFile(filename string) error {
+	if ae == nil {
+		return nil
+	}
+
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var states []*AlertState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		ae.state[state.Rule+"|"+state.Service] = state
+	}
+
+	return nil
+}
+
+// SaveToFile persists alert state so firing alerts survive a restart
+func (ae *AlertEngine) SaveToFile(filename string) error {
+	if ae == nil {
+		return nil
+	}
+
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	states := make([]*AlertState, 0, len(ae.state))
+	for _, state := range ae.state {
+		if state.State == AlertStateInactive && state.ConsecutiveDown == 0 && len(state.Transitions) == 0 {
+			continue
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Rule == states[j].Rule {
+			return states[i].Service < states[j].Service
+		}
+		return states[i].Rule < states[j].Rule
+	})
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// evaluateCondition updates counters for one result and reports whether the
+// rule's trigger condition holds (met) or its recovery condition holds (clear).
+// Between the two thresholds neither is true, which keeps the current state.
+func evaluateCondition(rule AlertRule, result TestResult, state *AlertState) (met, clear bool, value float64, message string) {
+	locationStr := fmt.Sprintf("%s [%s]", result.Endpoint.Location, result.Endpoint.Provider)
+
+	recoverAfter := rule.RecoverAfter
+	if recoverAfter < 1 {
+		recoverAfter = 1
+	}
+
+	switch rule.Kind {
+	case AlertRuleDown:
+		if result.Online {
+			state.ConsecutiveDown = 0
+			state.ClearStreak++
+		} else {
+			state.ConsecutiveDown++
+			state.ClearStreak = 0
+		}
+		required := rule.Consecutive
+		if required < 1 {
+			required = 1
+		}
+		met = state.ConsecutiveDown >= required
+		clear = state.ClearStreak >= recoverAfter
+		value = float64(state.ConsecutiveDown)
+		message = fmt.Sprintf("%s %s has been DOWN for %d consecutive checks: %s",
+			locationStr, result.TestType, state.ConsecutiveDown, result.Error)
+
+	case AlertRuleTrendUp:
+		met = result.Trend == "UP"
+		if met {
+			state.ClearStreak = 0
+		} else {
+			state.ClearStreak++
+		}
+		clear = state.ClearStreak >= recoverAfter
+		value = float64(result.ResponseTime.Milliseconds())
+		message = fmt.Sprintf("%s %s latency trending UP: %dms (baseline: %dms)",
+			locationStr, result.TestType,
+			result.ResponseTime.Milliseconds(), result.Baseline.Milliseconds())
+
+	case AlertRuleLoss:
+		resolveThreshold := rule.ResolveThreshold
+		if resolveThreshold == 0 || resolveThreshold > rule.Threshold {
+			resolveThreshold = rule.Threshold
+		}
+		met = result.Loss > rule.Threshold
+		clear = result.Loss <= resolveThreshold
+		value = result.Loss
+		message = fmt.Sprintf("%s packet loss %.1f%% exceeds %.1f%%",
+			locationStr, result.Loss, rule.Threshold)
+	}
+
+	return met, clear, value, message
+}
+
+// updateFlapping records a condition change and reports whether the rule/service
+// pair started or stopped flapping
+func updateFlapping(rule AlertRule, state *AlertState, met bool, now time.Time) (started, stopped bool) {
+	if rule.FlapThreshold <= 0 {
+		return false, false
+	}
+
+	window := time.Duration(rule.FlapWindow)
+	if window == 0 {
+		window = 30 * time.Minute
+	}
+
+	if met != state.ConditionMet {
+		state.Transitions = append(state.Transitions, now)
+	}
+
+	kept := state.Transitions[:0]
+	for _, t := range state.Transitions {
+		if now.Sub(t) <= window {
+			kept = append(kept, t)
+		}
+	}
+	state.Transitions = kept
+
+	if !state.Flapping && len(state.Transitions) >= rule.FlapThreshold {
+		state.Flapping = true
+		return true, false
+	}
+
+	// Require the change rate to fall well below the threshold before
+	// declaring the flapping over, so it doesn't toggle itself
+	if state.Flapping && len(state.Transitions) <= rule.FlapThreshold/2 {
+		state.Flapping = false
+		return false, true
+	}
+
+	return false, false
+}
+
+// evaluateRule advances the state machine for one result and returns the
+// alert to send, if any
+func (ae *AlertEngine) evaluateRule(rule AlertRule, result TestResult, serviceKey string) *Alert {
+	key := rule.Name + "|" + serviceKey
+	state := ae.state[key]
+	if state == nil {
+		state = &AlertState{Rule: rule.Name, Service: serviceKey, State: AlertStateInactive}
+		ae.state[key] = state
+	}
+
+	if rule.Kind == AlertRuleLoss && result.TestType != TestTypePing {
+		return nil
+	}
+
+	met, clear, value, message := evaluateCondition(rule, result, state)
+
+	alert := &Alert{
+		Rule:      rule.Name,
+		Kind:      rule.Kind,
+		Service:   serviceKey,
+		Endpoint:  result.Endpoint,
+		TestType:  result.TestType,
+		Message:   message,
+		Value:     value,
+		Timestamp: result.Timestamp,
+	}
+
+	subject := fmt.Sprintf("%s [%s] %s", result.Endpoint.Location, result.Endpoint.Provider, result.TestType)
+	return advanceAlert(rule, state, met, clear, subject, alert)
+}
+
+// advanceAlert moves an alert through pending, firing and resolved given the
+// latest condition, and returns the notification to send, if any
+func advanceAlert(rule AlertRule, state *AlertState, met, clear bool, subject string, alert *Alert) *Alert {
+	now := alert.Timestamp
+	flapStarted, flapStopped := updateFlapping(rule, state, met, now)
+	state.ConditionMet = met
+
+	var notify string
+
+	switch state.State {
+	case AlertStateInactive, AlertStateResolved:
+		if met {
+			state.State = AlertStatePending
+			state.PendingSince = now
+		}
+	case AlertStatePending:
+		if !met {
+			state.State = AlertStateInactive
+			state.PendingSince = time.Time{}
+		}
+	case AlertStateFiring:
+		if clear {
+			state.State = AlertStateResolved
+			lasted := now.Sub(state.FiringSince).Round(time.Second)
+			alert.Message = fmt.Sprintf("%s recovered after %v (%s)", subject, lasted, rule.Name)
+			alert.Duration = Duration(lasted)
+			notify = "RESOLVED"
+		} else if met && rule.RepeatInterval > 0 && now.Sub(state.LastNotified) >= time.Duration(rule.RepeatInterval) {
+			alert.Duration = Duration(now.Sub(state.FiringSince).Round(time.Second))
+			notify = "FIRING"
+		}
+	}
+
+	// A pending alert fires once the condition has held for the rule's minimum duration
+	if state.State == AlertStatePending && now.Sub(state.PendingSince) >= time.Duration(rule.For) {
+		state.State = AlertStateFiring
+		state.FiringSince = now
+		notify = "FIRING"
+	}
+
+	switch {
+	case flapStarted:
+		alert.Status = "FLAPPING"
+		alert.Message = fmt.Sprintf("%s is flapping (%d state changes); notifications paused",
+			subject, len(state.Transitions))
+	case flapStopped:
+		alert.Status = "FIRING"
+		condition := "still failing"
+		if state.State != AlertStateFiring {
+			alert.Status = "RESOLVED"
+			condition = "healthy"
+		}
+		alert.Message = fmt.Sprintf("%s stopped flapping and is %s", subject, condition)
+	case state.Flapping || notify == "":
+		return nil
+	default:
+		alert.Status = notify
+	}
+
+	state.LastNotified = now
+	return alert
+}
+
+// Process evaluates every rule against a cycle's results and sends alerts
+// whose state changed
+func (ae *AlertEngine) Process(results []TestResult) {
+	if ae == nil {
+		return
+	}
+
+	ae.mu.Lock()
+	var pending []struct {
+		alert Alert
+		rule  AlertRule
+	}
+	for _, rule := range ae.rules {
+		if rule.Kind == AlertRuleBurn {
+			continue
+		}
+		for _, result := range results {
+			// Results under maintenance or during a local outage neither
+			// raise nor resolve alerts
+			if result.Maintenance != "" || result.LocalOutage {
+				continue
+			}
+			if !rule.Match.Matches(result.Endpoint, result.TestType) {
+				continue
+			}
+			serviceKey := serviceKeyFor(result.Endpoint, result.TestType, result.Target)
+			if alert := ae.evaluateRule(rule, result, serviceKey); alert != nil {
+				pending = append(pending, struct {
+					alert Alert
+					rule  AlertRule
+				}{*alert, rule})
+			}
+		}
+	}
+	ae.mu.Unlock()
+
+	for _, p := range pending {
+		ae.dispatch(p.alert, p.rule)
+	}
+}
+
+// ProcessSLOs evaluates burn_rate rules against the latest SLO status
+func (ae *AlertEngine) ProcessSLOs(statuses []SLOStatus) {
+	if ae == nil {
+		return
+	}
+
+	ae.mu.Lock()
+	var pending []struct {
+		alert Alert
+		rule  AlertRule
+	}
+	for _, rule := range ae.rules {
+		if rule.Kind != AlertRuleBurn {
+			continue
+		}
+		for _, status := range statuses {
+			if status.Name != rule.SLO {
+				continue
+			}
+
+			service := "SLO " + status.Name
+			key := rule.Name + "|" + service
+			state := ae.state[key]
+			if state == nil {
+				state = &AlertState{Rule: rule.Name, Service: service, State: AlertStateInactive}
+				ae.state[key] = state
+			}
+
+			// Multi-window burn rate: every window must exceed the threshold to
+			// fire, and any one dropping back below it resolves the alert
+			met := true
+			var rates []string
+			highest := 0.0
+			for _, window := range rule.BurnWindows {
+				rate := 0.0
+				for _, burn := range status.BurnRates {
+					if burn.Window == window {
+						rate = burn.Rate
+					}
+				}
+				if rate <= rule.Threshold {
+					met = false
+				}
+				if rate > highest {
+					highest = rate
+				}
+				rates = append(rates, fmt.Sprintf("%s %.2fx", formatWindow(window), rate))
+			}
+
+			alert := &Alert{
+				Rule:    rule.Name,
+				Kind:    rule.Kind,
+				Service: service,
+				Message: fmt.Sprintf("%s burning error budget above %.1fx (%s); %.1f%% of budget left",
+					service, rule.Threshold, strings.Join(rates, ", "), status.ErrorBudgetRemaining),
+				Value:     highest,
+				Timestamp: status.UpdatedAt,
+			}
+
+			if alert := advanceAlert(rule, state, met, !met, service, alert); alert != nil {
+				pending = append(pending, struct {
+					alert Alert
+					rule  AlertRule
+				}{*alert, rule})
+			}
+		}
+	}
+	ae.mu.Unlock()
+
+	for _, p := range pending {
+		ae.dispatch(p.alert, p.rule)
+	}
+}
+
+// dispatch sends an alert to the notifiers selected by its rule
+func (ae *AlertEngine) dispatch(alert Alert, rule AlertRule) {
+	color := ColorRed
+	if alert.Status == "RESOLVED" {
+		color = ColorGreen
+	}
+	fmt.Printf("%s[ALERT %s] %s%s\n", color, alert.Status, alert.Message, ColorReset)
+
+	for _, notifier := range ae.notifiers {
+		if len(rule.Notifiers) > 0 && !containsString(rule.Notifiers, notifier.Name()) {
+			continue
+		}
+		if err := notifier.Notify(alert); err != nil {
+			fmt.Printf("%sWarning: Notifier %s failed: %v%s\n", ColorYellow, notifier.Name(), err, ColorReset)
+		}
+	}
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceWindow is a scheduled period during which matching results are
+// collected but not counted as outages. Set Start/End for a one-off window,
+// or Schedule (cron syntax: minute hour day-of-month month day-of-week) and
+// Duration for a recurring one.
+type MaintenanceWindow struct {
+	Name     string
+	Match    EndpointMatcher
+	Start    time.Time
+	End      time.Time
+	Schedule string
+	Duration Duration
+	Timezone string
+}
+
+// Silence is an ad-hoc suppression created from the command line
+type Silence struct {
+	ID        string
+	Match     EndpointMatcher
+	Start     time.Time
+	End       time.Time
+	Comment   string
+	CreatedBy string
+}
+
+// cronField holds the allowed values for one cron field
+type cronField map[int]bool
+
+// cronSchedule is a parsed five-field cron expression
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+	domAny, dowAny                bool
+}
+
+var cronNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// parseCronValue parses a number or a day/month name
+func parseCronValue(s string) (int, error) {
+	if v, ok := cronNames[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// parseCronField parses lists, ranges and steps such as "1-5", "*/15" or "mon,wed"
+func parseCronField(field string, min, max int) (cronField, error) {
+	values := make(cronField)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// parseCron parses a standard five-field cron expression
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	var schedule cronSchedule
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if schedule.dow[7] {
+		schedule.dow[0] = true
+	}
+	schedule.domAny = fields[2] == "*"
+	schedule.dowAny = fields[4] == "*"
+
+	return &schedule, nil
+}
+
+// matches reports whether a window starts at the given minute
+func (cs *cronSchedule) matches(t time.Time) bool {
+	if !cs.minute[t.Minute()] || !cs.hour[t.Hour()] || !cs.month[int(t.Month())] {
+		return false
+	}
+
+	// As in cron, a restricted day-of-month and day-of-week match if either does
+	domMatch := cs.dom[t.Day()]
+	dowMatch := cs.dow[int(t.Weekday())]
+	switch {
+	case cs.domAny && cs.dowAny:
+		return true
+	case cs.domAny:
+		return dowMatch
+	case cs.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// MaintenanceSchedule combines configured windows with silences from silences.json
+type MaintenanceSchedule struct {
+	windows      []MaintenanceWindow
+	crons        map[string]*cronSchedule
+	silencesFile string
+	silences     []Silence
+	silencesMod  time.Time
+}
+
+// activeSuppression is a window or silence in effect for the current cycle
+type activeSuppression struct {
+	Name  string
+	Match EndpointMatcher
+}
+
+// NewMaintenanceSchedule validates the configured windows
+func NewMaintenanceSchedule(windows []MaintenanceWindow, silencesFile string) (*MaintenanceSchedule, error) {
+	ms := &MaintenanceSchedule{
+		windows:      windows,
+		crons:        make(map[string]*cronSchedule),
+		silencesFile: silencesFile,
+	}
+
+	names := make(map[string]bool)
+	for _, window := range windows {
+		// Results are tagged and recurring schedules looked up by name
+		if window.Name == "" {
+			return nil, fmt.Errorf("maintenance window needs a Name")
+		}
+		if names[window.Name] {
+			return nil, fmt.Errorf("maintenance window %q: duplicate name", window.Name)
+		}
+		names[window.Name] = true
+
+		if window.Schedule == "" {
+			if window.End.Before(window.Start) || window.End.IsZero() {
+				return nil, fmt.Errorf("maintenance window %q: needs Start and End, or Schedule and Duration", window.Name)
+			}
+			continue
+		}
+
+		schedule, err := parseCron(window.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %q: %v", window.Name, err)
+		}
+		if window.Duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %q: recurring windows need a Duration", window.Name)
+		}
+		if window.Timezone != "" {
+			if _, err := time.LoadLocation(window.Timezone); err != nil {
+				return nil, fmt.Errorf("maintenance window %q: %v", window.Name, err)
+			}
+		}
+		ms.crons[window.Name] = schedule
+	}
+
+	return ms, nil
+}
+
+// windowActive reports whether a window covers the given time
+func (ms *MaintenanceSchedule) windowActive(window MaintenanceWindow, now time.Time) bool {
+	if window.Schedule == "" {
+		return !now.Before(window.Start) && now.Before(window.End)
+	}
+
+	if window.Timezone != "" {
+		if loc, err := time.LoadLocation(window.Timezone); err == nil {
+			now = now.In(loc)
+		}
+	}
+
+	// Walk back minute by minute looking for a start within the window length
+	schedule := ms.crons[window.Name]
+	start := now.Truncate(time.Minute)
+	for t := start; now.Sub(t) < time.Duration(window.Duration); t = t.Add(-time.Minute) {
+		if schedule.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadSilences rereads silences.json when it has changed
+func (ms *MaintenanceSchedule) reloadSilences() error {
+	info, err := os.Stat(ms.silencesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			ms.silences = nil
+			return nil
+		}
+		return err
+	}
+	if info.ModTime().Equal(ms.silencesMod) {
+		return nil
+	}
+
+	silences, err := loadSilences(ms.silencesFile)
+	if err != nil {
+		return err
+	}
+	ms.silences = silences
+	ms.silencesMod = info.ModTime()
+	return nil
+}
+
+// Active returns the windows and silences in effect at the given time
+func (ms *MaintenanceSchedule) Active(now time.Time) []activeSuppression {
+	if ms == nil {
+		return nil
+	}
+
+	if err := ms.reloadSilences(); err != nil {
+		fmt.Printf("%sWarning: Could not load silences: %v%s\n", ColorYellow, err, ColorReset)
+	}
+
+	var active []activeSuppression
+	for _, window := range ms.windows {
+		if ms.windowActive(window, now) {
+			active = append(active, activeSuppression{Name: window.Name, Match: window.Match})
+		}
+	}
+	for _, silence := range ms.silences {
+		if !now.Before(silence.Start) && now.Before(silence.End) {
+			active = append(active, activeSuppression{Name: "silence " + silence.ID, Match: silence.Match})
+		}
+	}
+	return active
+}
+
+// suppressionFor returns the name of the first suppression covering an endpoint test
+func suppressionFor(active []activeSuppression, endpoint CloudEndpoint, testType TestType) string {
+	for _, suppression := range active {
+		if suppression.Match.Matches(endpoint, testType) {
+			return suppression.Name
+		}
+	}
+	return ""
+}
+
+// loadSilences reads silences from a JSON file
+func loadSilences(filename string) ([]Silence, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var silences []Silence
+	if err := json.Unmarshal(data, &silences); err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+// saveSilences writes silences to a JSON file
+func saveSilences(filename string, silences []Silence) error {
+	data, err := json.MarshalIndent(silences, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// runSilenceCommand adds, lists or expires silences:
+//
+//	go run main.go silence -region eu-west-1 -for 2h -comment "uplink work"
+//	go run main.go silence -list
+//	go run main.go silence -expire <id>
+func runSilenceCommand(args []string) error {
+	fs := flag.NewFlagSet("silence", flag.ExitOnError)
+	var match EndpointMatcher
+	fs.StringVar(&match.Location, "location", "", "location to silence (wildcards allowed)")
+	fs.StringVar(&match.Provider, "provider", "", "provider to silence")
+	fs.StringVar(&match.Region, "region", "", "region to silence")
+	fs.StringVar(&match.Hostname, "hostname", "", "hostname to silence")
+	fs.StringVar(&match.TestType, "test", "", "test type to silence (PING, DNS, HTTP, TCP, TLS, ...)")
+	label := fs.String("label", "", "label to silence, as key=value")
+	duration := fs.Duration("for", time.Hour, "how long the silence lasts")
+	comment := fs.String("comment", "", "reason for the silence")
+	list := fs.Bool("list", false, "list active silences")
+	expire := fs.String("expire", "", "expire the silence with this ID")
+	fs.Parse(args)
+
+	const filename = "silences.json"
+	silences, err := loadSilences(filename)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	// Drop silences that ended more than a day ago
+	kept := silences[:0]
+	for _, silence := range silences {
+		if now.Sub(silence.End) < 24*time.Hour {
+			kept = append(kept, silence)
+		}
+	}
+	silences = kept
+
+	switch {
+	case *list:
+		for _, silence := range silences {
+			if now.Before(silence.End) {
+				fmt.Printf("%s  until %s  %+v  %s\n", silence.ID,
+					silence.End.Format("2006-01-02 15:04"), silence.Match, silence.Comment)
+			}
+		}
+		return nil
+
+	case *expire != "":
+		for i := range silences {
+			if silences[i].ID == *expire {
+				silences[i].End = now
+				fmt.Printf("Expired silence %s\n", *expire)
+				return saveSilences(filename, silences)
+			}
+		}
+		return fmt.Errorf("no silence with ID %s", *expire)
+	}
+
+	if *label != "" {
+		parts := strings.SplitN(*label, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("label must be key=value")
+		}
+		match.Labels = map[string]string{parts[0]: parts[1]}
+	}
+	if match.Location == "" && match.Provider == "" && match.Region == "" &&
+		match.Hostname == "" && match.TestType == "" && match.Labels == nil {
+		return fmt.Errorf("refusing to silence every endpoint; pass at least one matcher")
+	}
+
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return fmt.Errorf("generating silence ID: %w", err)
+	}
+
+	createdBy := os.Getenv("USER")
+	silence := Silence{
+		ID:        hex.EncodeToString(idBytes),
+		Match:     match,
+		Start:     now,
+		End:       now.Add(*duration),
+		Comment:   *comment,
+		CreatedBy: createdBy,
+	}
+	silences = append(silences, silence)
+
+	if err := saveSilences(filename, silences); err != nil {
+		return err
+	}
+	fmt.Printf("Created silence %s until %s\n", silence.ID, silence.End.Format("2006-01-02 15:04"))
+	return nil
+}
+
+// Cycle classifications
+const (
+	CycleNormal      = "NORMAL"
+	CycleLocalOutage = "LOCAL_OUTAGE"
+)
+
+// Diagnosis verdicts combining every layer tested for an endpoint
+const (
+	DiagnosisHealthy      = "HEALTHY"
+	DiagnosisDNS          = "DNS_PROBLEM"
+	DiagnosisNetworkPath  = "NETWORK_PATH_PROBLEM"
+	DiagnosisTLS          = "TLS_PROBLEM"
+	DiagnosisService      = "SERVICE_PROBLEM"
+	DiagnosisLocalNetwork = "LOCAL_NETWORK"
+)
+
+// EndpointDiagnosis is the single root-cause verdict for an endpoint in one cycle
+type EndpointDiagnosis struct {
+	Endpoint CloudEndpoint
+	Verdict  string
+	Reason   string
+}
+
+// failedPhase returns the first phase of a test that reported an error
+func failedPhase(result TestResult) *ProbePhase {
+	for i := range result.Phases {
+		if result.Phases[i].Error != "" {
+			return &result.Phases[i]
+		}
+	}
+	return nil
+}
+
+// diagnoseEndpoint combines the DNS, PING, TCP, TLS and HTTP results for
+// one endpoint into a single verdict, working up the stack: a lower layer
+// failing explains every failure above it.
+func diagnoseEndpoint(endpoint CloudEndpoint, results []TestResult, cycle string) EndpointDiagnosis {
+	diagnosis := EndpointDiagnosis{Endpoint: endpoint, Verdict: DiagnosisHealthy, Reason: "all layers responding"}
+
+	// With several probes of one type, a failing one speaks for the layer
+	var dns, ping, web, arp *TestResult
+	for i := range results {
+		var layer **TestResult
+		switch results[i].TestType {
 		case TestTypeDNS:
-			dnsResults = append(dnsResults, result)
+			layer = &dns
+		case TestTypePing:
+			layer = &ping
 		case TestTypeHTTP:
-			httpResults = append(httpResults, result)
+			layer = &web
+		case TestTypeARP:
+			layer = &arp
+		default:
+			continue
+		}
+		if *layer == nil || ((*layer).Online && !results[i].Online) {
+			*layer = &results[i]
+		}
+	}
+
+	anyFailed := false
+	for _, result := range results {
+		if !result.Online || serverError(result) {
+			anyFailed = true
+		} else if result.Degraded && diagnosis.Reason == "all layers responding" {
+			diagnosis.Reason = fmt.Sprintf("all layers responding; %s check degraded: %s", result.TestType, result.Note)
+		}
+	}
+	if !anyFailed {
+		return diagnosis
+	}
+
+	if cycle == CycleLocalOutage {
+		diagnosis.Verdict = DiagnosisLocalNetwork
+		diagnosis.Reason = "local network outage; remote endpoint state unknown"
+		return diagnosis
+	}
+
+	// The lowest layer that failed in any of the HTTP, TCP or TLS checks
+	layers := map[string]int{"dns": 0, "connect": 1, "tls": 2}
+	var webPhase *ProbePhase
+	for i := range results {
+		result := results[i]
+		if result.Online || (result.TestType != TestTypeHTTP && result.TestType != TestTypeTCP && result.TestType != TestTypeTLS) {
+			continue
+		}
+		phase := failedPhase(result)
+		if phase == nil {
+			continue
+		}
+		if layer, ok := layers[phase.Name]; ok && (webPhase == nil || layer < layers[webPhase.Name]) {
+			webPhase = phase
+		}
+	}
+
+	// DNS layer
+	switch {
+	case dns != nil && !dns.Online:
+		diagnosis.Verdict = DiagnosisDNS
+		diagnosis.Reason = "hostname does not resolve: " + dns.Error
+		return diagnosis
+	case webPhase != nil && webPhase.Name == "dns":
+		diagnosis.Verdict = DiagnosisDNS
+		diagnosis.Reason = "hostname does not resolve: " + webPhase.Error
+		return diagnosis
+	case ping != nil && !ping.Online && ping.ResolvedIP == "":
+		diagnosis.Verdict = DiagnosisDNS
+		diagnosis.Reason = "hostname does not resolve: " + ping.Error
+		return diagnosis
+	}
+
+	pingFailed := ping != nil && !ping.Online
+
+	// Network path (ICMP and TCP connect)
+	if webPhase != nil && webPhase.Name == "connect" {
+		if strings.Contains(webPhase.Error, "connection refused") {
+			diagnosis.Verdict = DiagnosisService
+			diagnosis.Reason = "host reachable but refusing connections"
+			return diagnosis
+		}
+		diagnosis.Verdict = DiagnosisNetworkPath
+		diagnosis.Reason = "TCP connect failed: " + webPhase.Error
+		if pingFailed {
+			diagnosis.Reason = "no ICMP or TCP reachability: " + webPhase.Error
+		}
+		return diagnosis
+	}
+
+	// A TCP, TLS or ARP check that answered proves the path works even if ICMP is filtered
+	tcpReachable := false
+	for _, result := range results {
+		if result.Online && (result.TestType == TestTypeTCP || result.TestType == TestTypeTLS || result.TestType == TestTypeARP) {
+			tcpReachable = true
+		}
+	}
+
+	if arp != nil && !arp.Online && !tcpReachable && (ping == nil || pingFailed) {
+		diagnosis.Verdict = DiagnosisNetworkPath
+		diagnosis.Reason = "no ARP reply on the LAN: " + arp.Error
+		return diagnosis
+	}
+
+	if web == nil && pingFailed && !tcpReachable {
+		diagnosis.Verdict = DiagnosisNetworkPath
+		diagnosis.Reason = "ICMP unreachable: " + ping.Error
+		return diagnosis
+	}
+
+	// TLS layer
+	if webPhase != nil && webPhase.Name == "tls" {
+		diagnosis.Verdict = DiagnosisTLS
+		diagnosis.Reason = "TLS handshake failed: " + webPhase.Error
+		return diagnosis
+	}
+
+	if web != nil && !web.Online {
+		// Nothing failed below HTTP, so the service itself is at fault
+		// unless the request never got a connection at all
+		connected := false
+		for _, phase := range web.Phases {
+			if phase.Name == "connect" && phase.Error == "" {
+				connected = true
+			}
+		}
+		if !connected && pingFailed {
+			diagnosis.Verdict = DiagnosisNetworkPath
+			diagnosis.Reason = "no ICMP or TCP reachability: " + web.Error
+			return diagnosis
+		}
+
+		diagnosis.Verdict = DiagnosisService
+		diagnosis.Reason = "service not responding: " + web.Error
+		return diagnosis
+	}
+
+	// The server answered with an error of its own
+	for _, result := range results {
+		if serverError(result) {
+			diagnosis.Verdict = DiagnosisService
+			diagnosis.Reason = fmt.Sprintf("service answering with HTTP %d", result.StatusCode)
+			return diagnosis
+		}
+	}
+
+	// Any other failing check above the network layer points at the service
+	for _, result := range results {
+		if !result.Online && result.TestType != TestTypePing && result.TestType != TestTypeTraceroute && result.TestType != TestTypeARP {
+			diagnosis.Verdict = DiagnosisService
+			diagnosis.Reason = fmt.Sprintf("%s check failing: %s", result.TestType, result.Error)
+			return diagnosis
+		}
+	}
+
+	// The service answers, so a failed ping or traceroute only means ICMP is filtered
+	diagnosis.Reason = "service healthy; ICMP not answered"
+	if web != nil {
+		diagnosis.Reason = "HTTP healthy; ICMP not answered"
+	}
+	return diagnosis
+}
+
+// serverError reports whether an HTTP check got a 5xx response. The check
+// itself stays online, since the service answered.
+func serverError(result TestResult) bool {
+	return result.TestType == TestTypeHTTP && result.Online && result.StatusCode >= 500
+}
+
+// diagnoseCycle produces a verdict for every endpoint tested in a cycle
+func diagnoseCycle(results []TestResult, cycle string) []EndpointDiagnosis {
+	byEndpoint := make(map[string][]TestResult)
+	var order []string
+
+	for _, result := range results {
+		key := result.Endpoint.Location + "|" + result.Endpoint.Provider
+		if byEndpoint[key] == nil {
+			order = append(order, key)
+		}
+		byEndpoint[key] = append(byEndpoint[key], result)
+	}
+	sort.Strings(order)
+
+	diagnoses := make([]EndpointDiagnosis, 0, len(order))
+	for _, key := range order {
+		endpointResults := byEndpoint[key]
+		diagnoses = append(diagnoses, diagnoseEndpoint(endpointResults[0].Endpoint, endpointResults, cycle))
+	}
+	return diagnoses
+}
+
+// printDiagnoses shows the endpoints that are not healthy and a verdict count
+func printDiagnoses(diagnoses []EndpointDiagnosis) map[string]int {
+	counts := make(map[string]int)
+	for _, diagnosis := range diagnoses {
+		counts[diagnosis.Verdict]++
+	}
+
+	if counts[DiagnosisHealthy] == len(diagnoses) {
+		return counts
+	}
+
+	fmt.Printf("\n%s=== DIAGNOSIS (Root Cause by Endpoint) ===%s\n", ColorMagenta, ColorReset)
+	for _, diagnosis := range diagnoses {
+		if diagnosis.Verdict == DiagnosisHealthy {
+			continue
+		}
+		locationStr := fmt.Sprintf("%s [%s]", diagnosis.Endpoint.Location, diagnosis.Endpoint.Provider)
+		fmt.Printf("%s[%s]%s %-35s %s\n", ColorRed, diagnosis.Verdict, ColorReset, locationStr, diagnosis.Reason)
+	}
+
+	return counts
+}
+
+// writeDiagnosisToLog appends one verdict line per diagnosis given
+func writeDiagnosisToLog(timestamp time.Time, diagnoses []EndpointDiagnosis, logFile io.Writer) {
+	if logFile == nil {
+		return
+	}
+
+	for _, diagnosis := range diagnoses {
+		locationStr := fmt.Sprintf("%s [%s]", diagnosis.Endpoint.Location, diagnosis.Endpoint.Provider)
+		fmt.Fprintf(logFile, "%s | [DIAGNOSIS] %-35s | Verdict: %s | Reason: %s\n",
+			timestamp.Format("2006-01-02 15:04:05"), locationStr, diagnosis.Verdict, diagnosis.Reason)
+	}
+}
+
+// LocalCheckConfig describes the local network checks run before each cycle
+type LocalCheckConfig struct {
+	Disabled      bool
+	Gateway       string   // router address; detected from the routing table when empty
+	Resolver      string   // upstream DNS resolver as host:port; system resolver when empty
+	ResolverQuery string   // hostname resolved to test the resolver
+	Anchors       []string // well-known host:port pairs checked with a TCP connect
+	FailureRatio  float64  // share of remote tests that must fail to blame the local network
+}
+
+// LocalStatus is the outcome of the local network checks for one cycle
+type LocalStatus struct {
+	Checked      bool
+	Gateway      string
+	GatewayOK    bool
+	Resolver     string
+	ResolverOK   bool
+	AnchorsOK    int
+	AnchorsTotal int
+}
+
+// Healthy reports whether every local check passed. A check without a
+// target, a gateway that could not be detected or an empty anchor list, is
+// no evidence either way.
+func (ls LocalStatus) Healthy() bool {
+	if !ls.Checked {
+		return true
+	}
+	gatewayOK := ls.Gateway == "" || ls.GatewayOK
+	anchorsOK := ls.AnchorsTotal == 0 || ls.AnchorsOK > 0
+	return gatewayOK && ls.ResolverOK && anchorsOK
+}
+
+// String summarizes the local checks for the console and log
+func (ls LocalStatus) String() string {
+	state := func(ok bool) string {
+		if ok {
+			return "OK"
+		}
+		return "DOWN"
+	}
+	gateway := "Gateway: not found"
+	if ls.Gateway != "" {
+		gateway = fmt.Sprintf("Gateway %s: %s", ls.Gateway, state(ls.GatewayOK))
+	}
+	return fmt.Sprintf("%s | Resolver %s: %s | Anchors: %d/%d",
+		gateway, ls.Resolver, state(ls.ResolverOK), ls.AnchorsOK, ls.AnchorsTotal)
+}
+
+// detectGateway finds the default gateway from the OS routing table
+func detectGateway() string {
+	// Linux: /proc/net/route lists the default route with destination 00000000
+	if data, err := ioutil.ReadFile("/proc/net/route"); err == nil {
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || fields[1] != "00000000" {
+				continue
+			}
+			raw, err := strconv.ParseUint(fields[2], 16, 32)
+			if err != nil {
+				continue
+			}
+			// The gateway is stored little-endian
+			return net.IPv4(byte(raw), byte(raw>>8), byte(raw>>16), byte(raw>>24)).String()
+		}
+	}
+
+	// macOS: "route -n get default" prints "gateway: 192.168.1.1"
+	output, err := exec.Command("route", "-n", "get", "default").Output()
+	if err == nil {
+		re := regexp.MustCompile(`gateway:\s*(\S+)`)
+		if matches := re.FindStringSubmatch(string(output)); len(matches) > 1 {
+			return matches[1]
+		}
+	}
+
+	return ""
+}
+
+// hostReachable reports whether a host answers on the network. A refused TCP
+// connection still proves the host is up, so only timeouts and unreachable
+// errors count as failures.
+func hostReachable(host string) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "80"), 2*time.Second)
+	if err == nil {
+		conn.Close()
+		return true
+	}
+	if strings.Contains(err.Error(), "connection refused") {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _, err = pingIP(ctx, host, 3)
+	return err == nil
+}
+
+// runLocalChecks tests the gateway, upstream resolver and anchor hosts concurrently
+func runLocalChecks(config LocalCheckConfig) LocalStatus {
+	if config.Disabled {
+		return LocalStatus{}
+	}
+
+	status := LocalStatus{
+		Checked:      true,
+		Gateway:      config.Gateway,
+		Resolver:     config.Resolver,
+		AnchorsTotal: len(config.Anchors),
+	}
+	if status.Gateway == "" {
+		status.Gateway = detectGateway()
+	}
+	if status.Resolver == "" {
+		status.Resolver = "system"
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ok := status.Gateway != "" && hostReachable(status.Gateway)
+		mu.Lock()
+		status.GatewayOK = ok
+		mu.Unlock()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resolver := &net.Resolver{}
+		if config.Resolver != "" {
+			resolver.PreferGo = true
+			resolver.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, config.Resolver)
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_, err := resolver.LookupHost(ctx, config.ResolverQuery)
+		mu.Lock()
+		status.ResolverOK = err == nil
+		mu.Unlock()
+	}()
+
+	for _, anchor := range config.Anchors {
+		wg.Add(1)
+		go func(anchor string) {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", anchor, 3*time.Second)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			mu.Lock()
+			status.AnchorsOK++
+			mu.Unlock()
+		}(anchor)
+	}
+
+	wg.Wait()
+	return status
+}
+
+// classifyCycle decides whether a cycle's failures are remote or caused by the
+// local network. It requires both local evidence (a failed gateway, resolver
+// or anchor check) and a correlated failure spanning several regions or
+// providers.
+func classifyCycle(local LocalStatus, results []TestResult, failureRatio float64) string {
+	if local.Healthy() {
+		return CycleNormal
+	}
+
+	total := 0
+	failed := 0
+	regions := make(map[string]bool)
+	providers := make(map[string]bool)
+
+	for _, result := range results {
+		// Home network endpoints are the local network, not remote evidence
+		if result.Maintenance != "" || result.Endpoint.Class == EndpointClassHome {
+			continue
+		}
+		total++
+		if !result.Online {
+			failed++
+			regions[result.Endpoint.Region] = true
+			providers[result.Endpoint.Provider] = true
+		}
+	}
+
+	if total == 0 || float64(failed)/float64(total) < failureRatio {
+		return CycleNormal
+	}
+	if len(regions) < 2 && len(providers) < 2 {
+		return CycleNormal
+	}
+
+	return CycleLocalOutage
+}
+
+// writeCycleToLog records a local network outage in the log file
+func writeCycleToLog(timestamp time.Time, local LocalStatus, failed, total int, logFile io.Writer) {
+	if logFile == nil {
+		return
+	}
+
+	fmt.Fprintf(logFile, "%s | [LOCAL OUTAGE] %s | Failed tests: %d/%d\n",
+		timestamp.Format("2006-01-02 15:04:05"), local, failed, total)
+}
+
+// EndpointClassHome marks endpoints on or near the home network
+const EndpointClassHome = "home"
+
+// HomeConfig enables the home network endpoints: the router, LAN devices,
+// the ISP's first hop and public websites
+type HomeConfig struct {
+	Enabled  bool
+	Only     bool         // skip the cloud endpoints
+	Router   string       // router address; the local checks gateway or the default route when empty
+	ISPHop   string       // ISP gateway address; found with traceroute when empty, "off" to skip
+	Devices  []HomeTarget // LAN devices by IP or mDNS name such as nas.local
+	Websites []HomeTarget // public websites; GitHub and Google when empty
+}
+
+// HomeTarget is one named LAN device or website
+type HomeTarget struct {
+	Name   string
+	Host   string // device IP address or hostname
+	URL    string // website URL
+	Labels map[string]string
+	Probes []ProbeSpec // replaces the default probes for the target's role
+}
+
+// defaultWebsites are checked when the home config lists none
+var defaultWebsites = []HomeTarget{
+	{Name: "GitHub", URL: "https://github.com"},
+	{Name: "Google", URL: "https://www.google.com"},
+}
+
+// homeRoles orders the home network section from the router outward
+var homeRoles = []string{"router", "isp", "device", "website"}
+
+// discoverISPHop finds the ISP's first hop: the first public or carrier-grade
+// NAT address on the path to a well-known host
+func discoverISPHop(router string) string {
+	const target = "1.1.1.1"
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	output, _ := exec.CommandContext(ctx, "traceroute", "-n", "-q", "1", "-w", "2", "-m", "6", target).Output()
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := tracerouteHopRe.FindStringSubmatch(line)
+		if len(matches) < 4 {
+			continue
+		}
+		ip := net.ParseIP(matches[2])
+		if ip == nil || matches[2] == router || matches[2] == target || ip.IsPrivate() || ip.IsLoopback() {
+			continue
+		}
+		return matches[2]
+	}
+	return ""
+}
+
+// homeEndpoints builds the router, ISP hop, LAN device and website endpoints.
+// They use the "Home" provider, so their history keys look like
+// "Home Router [Home] - PING".
+func homeEndpoints(config HomeConfig, gateway string) []CloudEndpoint {
+	var endpoints []CloudEndpoint
+	add := func(target HomeTarget, role, region string, probes []ProbeSpec) {
+		labels := map[string]string{"role": role}
+		for key, value := range target.Labels {
+			labels[key] = value
+		}
+		if len(target.Probes) > 0 {
+			probes = target.Probes
+		}
+		endpoints = append(endpoints, CloudEndpoint{
+			Location: target.Name,
+			Region:   region,
+			Provider: "Home",
+			Hostname: target.Host,
+			Labels:   labels,
+			Probes:   probes,
+			Class:    EndpointClassHome,
+		})
+	}
+
+	router := config.Router
+	if router == "" {
+		router = gateway
+	}
+	if router == "" {
+		router = detectGateway()
+	}
+	if router != "" {
+		add(HomeTarget{Name: "Home Router", Host: router}, "router", "lan", []ProbeSpec{
+			{Type: TestTypeARP},
+			{Type: TestTypePing},
+			{Type: TestTypeHTTP, Params: map[string]string{"url": "http://" + router + "/"}},
+		})
+	} else {
+		fmt.Printf("%sWarning: home network: could not detect the router; set Home.Router%s\n", ColorYellow, ColorReset)
+	}
+
+	ispHop := config.ISPHop
+	if ispHop == "" {
+		ispHop = discoverISPHop(router)
+		if ispHop == "" {
+			fmt.Printf("%sWarning: home network: could not find the ISP's first hop; set Home.ISPHop%s\n", ColorYellow, ColorReset)
+		}
+	}
+	if ispHop != "" && ispHop != "off" {
+		add(HomeTarget{Name: "ISP Gateway", Host: ispHop}, "isp", "isp", []ProbeSpec{{Type: TestTypePing}})
+	}
+
+	for _, device := range config.Devices {
+		if device.Name == "" || device.Host == "" {
+			fmt.Printf("%sWarning: home network: device needs a Name and Host%s\n", ColorYellow, ColorReset)
+			continue
+		}
+		add(device, "device", "lan", []ProbeSpec{{Type: TestTypeARP}, {Type: TestTypePing}})
+	}
+
+	websites := config.Websites
+	if len(websites) == 0 {
+		websites = defaultWebsites
+	}
+	for _, website := range websites {
+		parsed, err := url.Parse(website.URL)
+		if website.Name == "" || err != nil || parsed.Hostname() == "" {
+			fmt.Printf("%sWarning: home network: website needs a Name and URL%s\n", ColorYellow, ColorReset)
+			continue
+		}
+		website.Host = parsed.Hostname()
+		add(website, "website", "internet", []ProbeSpec{
+			{Type: TestTypeDNS},
+			{Type: TestTypeHTTP, Params: map[string]string{"url": website.URL}},
+		})
+	}
+
+	return endpoints
+}
+
+// printHomeResults shows the home network endpoints as one console section,
+// from the router outward
+func printHomeResults(results []TestResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	roleRank := func(result TestResult) int {
+		for i, role := range homeRoles {
+			if result.Endpoint.Labels["role"] == role {
+				return i
+			}
+		}
+		return len(homeRoles)
+	}
+	// Lowest layer first, then any other probe types in registration order
+	typeRank := make(map[TestType]int)
+	for i, testType := range append([]TestType{TestTypeARP, TestTypePing, TestTypeDNS, TestTypeTCP, TestTypeTLS, TestTypeHTTP}, proberOrder...) {
+		if _, ok := typeRank[testType]; !ok {
+			typeRank[testType] = i
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if roleRank(a) != roleRank(b) {
+			return roleRank(a) < roleRank(b)
+		}
+		if a.Endpoint.Location != b.Endpoint.Location {
+			return a.Endpoint.Location < b.Endpoint.Location
+		}
+		return typeRank[a.TestType] < typeRank[b.TestType]
+	})
+
+	fmt.Printf("\n%s=== HOME NETWORK (Router, ISP, LAN Devices and Websites) ===%s\n", ColorMagenta, ColorReset)
+	for _, result := range results {
+		fmt.Printf("%-5s ", result.TestType)
+		printResult(result)
+	}
+}
+
+// HistoryConfig controls the long-term result archive
+type HistoryConfig struct {
+	ArchiveDir    string
+	RetentionDays int
+}
+
+// ArchiveRecord is one test result in the long-term archive
+type ArchiveRecord struct {
+	Timestamp       time.Time
+	Service         string
+	Location        string
+	Region          string
+	Provider        string
+	Hostname        string
+	Labels          map[string]string `json:",omitempty"`
+	Coordinates     *Coordinates      `json:",omitempty"`
+	TestType        TestType
+	Target          string `json:",omitempty"`
+	Online          bool
+	Degraded        bool               `json:",omitempty"`
+	ResponseTime    int64              // nanoseconds, as in latency_history.json
+	ResolvedIP      string             `json:",omitempty"`
+	Error           string             `json:",omitempty"`
+	ErrorCategory   string             `json:",omitempty"`
+	Trend           string             `json:",omitempty"`
+	Loss            float64            `json:",omitempty"`
+	Maintenance     string             `json:",omitempty"`
+	Cycle           string             `json:",omitempty"`
+	StatusCode      int                `json:",omitempty"`
+	Diagnosis       string             `json:",omitempty"`
+	DiagnosisReason string             `json:",omitempty"`
+	Fields          map[string]float64 `json:",omitempty"`
+	Details         map[string]string  `json:",omitempty"`
+}
+
+// HistoryArchive keeps every result, including failures, in one JSON Lines
+// file per day. latency_history.json only holds the last 10 successful
+// measurements used for baselines; the archive is the long-term record.
+type HistoryArchive struct {
+	dir       string
+	retention time.Duration
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// NewHistoryArchive creates the archive directory if needed
+func NewHistoryArchive(config HistoryConfig) (*HistoryArchive, error) {
+	dir := config.ArchiveDir
+	if dir == "" {
+		dir = "latency_archive"
+	}
+	days := config.RetentionDays
+	if days == 0 {
+		days = 90
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &HistoryArchive{
+		dir:       dir,
+		retention: time.Duration(days) * 24 * time.Hour,
+	}, nil
+}
+
+// newArchiveRecord converts a test result into an archive record
+func newArchiveRecord(result TestResult, cycle string, diagnosis EndpointDiagnosis) ArchiveRecord {
+	category := ""
+	if !result.Online {
+		category = errorCategory(result)
+	}
+	return ArchiveRecord{
+		Timestamp:       result.Timestamp,
+		Service:         serviceKeyFor(result.Endpoint, result.TestType, result.Target),
+		Location:        result.Endpoint.Location,
+		Region:          result.Endpoint.Region,
+		Provider:        result.Endpoint.Provider,
+		Hostname:        result.Endpoint.Hostname,
+		Labels:          result.Endpoint.Labels,
+		Coordinates:     result.Endpoint.Coordinates,
+		TestType:        result.TestType,
+		Target:          result.Target,
+		Online:          result.Online,
+		Degraded:        result.Degraded,
+		ResponseTime:    int64(result.ResponseTime),
+		ResolvedIP:      result.ResolvedIP,
+		Error:           result.Error,
+		ErrorCategory:   category,
+		Trend:           result.Trend,
+		Loss:            result.Loss,
+		Maintenance:     result.Maintenance,
+		Cycle:           cycle,
+		StatusCode:      result.StatusCode,
+		Diagnosis:       diagnosis.Verdict,
+		DiagnosisReason: diagnosis.Reason,
+		Fields:          result.Fields,
+		Details:         result.Details,
+	}
+}
+
+// Append writes records to the file for their day
+func (ha *HistoryArchive) Append(records []ArchiveRecord) error {
+	if ha == nil || len(records) == 0 {
+		return nil
+	}
+
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
+
+	byDay := make(map[string][]ArchiveRecord)
+	for _, record := range records {
+		day := record.Timestamp.Format("2006-01-02")
+		byDay[day] = append(byDay[day], record)
+	}
+
+	for day, dayRecords := range byDay {
+		file, err := os.OpenFile(filepath.Join(ha.dir, day+".jsonl"),
+			os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(file)
+		for _, record := range dayRecords {
+			if err := encoder.Encode(record); err != nil {
+				file.Close()
+				return err
+			}
+		}
+
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+
+	ha.pruneLocked(time.Now())
+	return nil
+}
+
+// Scan calls fn for every archived record between from and to, oldest day first
+func (ha *HistoryArchive) Scan(from, to time.Time, fn func(ArchiveRecord)) error {
+	if ha == nil {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(ha.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	firstDay := from.Format("2006-01-02")
+	lastDay := to.Format("2006-01-02")
+
+	for _, filename := range files {
+		day := strings.TrimSuffix(filepath.Base(filename), ".jsonl")
+		if day < firstDay || day > lastDay {
+			continue
+		}
+
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var record ArchiveRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue // skip a partially written line
+			}
+			if record.Timestamp.Before(from) || record.Timestamp.After(to) {
+				continue
+			}
+			fn(record)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keep marks days so the prune never deletes them; import-logs -all uses it
+// for backfilled days older than the retention period
+func (ha *HistoryArchive) Keep(days []string) error {
+	for _, day := range days {
+		if err := ioutil.WriteFile(filepath.Join(ha.dir, day+".keep"), nil, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneLocked deletes day files older than the retention period, at most
+// once an hour. Days with a .keep marker are left alone.
+func (ha *HistoryArchive) pruneLocked(now time.Time) {
+	if now.Sub(ha.lastPrune) < time.Hour {
+		return
+	}
+	ha.lastPrune = now
+
+	files, err := filepath.Glob(filepath.Join(ha.dir, "*.jsonl"))
+	if err != nil {
+		return
+	}
+
+	cutoff := now.Add(-ha.retention).Format("2006-01-02")
+	for _, file := range files {
+		day := strings.TrimSuffix(filepath.Base(file), ".jsonl")
+		if day >= cutoff {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(ha.dir, day+".keep")); err == nil {
+			continue
+		}
+		os.Remove(file)
+	}
+}
+
+// legacyEndpoints maps location names written by the earlier home health
+// monitor (health_monitor.log) to the endpoints that replaced them
+var legacyEndpoints = map[string]CloudEndpoint{
+	"Ashburn (Virginia, USA)":     {Location: "Ashburn, VA", Provider: "AWS"},
+	"Bangkok (Thailand)":          {Location: "Bangkok, TH", Provider: "GCP"},
+	"Cape Town (South Africa)":    {Location: "Cape Town, ZA", Provider: "AWS"},
+	"Dallas (Texas, USA)":         {Location: "Dallas, TX", Provider: "Azure"},
+	"Dubai (UAE)":                 {Location: "Dubai, AE", Provider: "AWS"},
+	"Frankfurt (Germany)":         {Location: "Frankfurt, DE", Provider: "AWS"},
+	"Johannesburg (South Africa)": {Location: "Johannesburg, ZA", Provider: "Azure"},
+	"London (UK)":                 {Location: "London, UK", Provider: "AWS"},
+	"Melbourne (Australia)":       {Location: "Melbourne, AU", Provider: "Azure"},
+	"Mexico City (Mexico)":        {Location: "Mexico City, MX", Provider: "Azure"},
+	"Mumbai (India)":              {Location: "Mumbai, IN", Provider: "AWS"},
+	"Paris (France)":              {Location: "Paris, FR", Provider: "AWS"},
+	"Pune (India)":                {Location: "Pune, IN", Provider: "Azure"},
+	"Riyadh (Saudi Arabia)":       {Location: "Riyadh, SA", Provider: "Azure"},
+	"San Jose (California, USA)":  {Location: "San Jose, CA", Provider: "AWS"},
+	"Singapore (Singapore)":       {Location: "Singapore, SG", Provider: "AWS"},
+	"Sydney (Australia)":          {Location: "Sydney, AU", Provider: "AWS"},
+	"São Paulo (Brazil)":          {Location: "São Paulo, BR", Provider: "AWS"},
+	"Tokyo (Japan)":               {Location: "Tokyo, JP", Provider: "AWS"},
+	"Cape Town, SA (UCT)":         {Location: "Cape Town, ZA", Provider: "AWS"},
+	"Home Router":                 {Location: "Home Router", Provider: "Home", Region: "lan", Labels: map[string]string{"role": "router"}},
+	"Router":                      {Location: "Home Router", Provider: "Home", Region: "lan", Labels: map[string]string{"role": "router"}},
+	"Router (ping)":               {Location: "Home Router", Provider: "Home", Region: "lan", Labels: map[string]string{"role": "router"}},
+	"GitHub":                      {Location: "GitHub", Provider: "Home", Region: "internet", Labels: map[string]string{"role": "website"}},
+	"Google":                      {Location: "Google", Provider: "Home", Region: "internet", Labels: map[string]string{"role": "website"}},
+	"Google DNS":                  {Location: "Google DNS", Provider: "Home", Region: "internet", Labels: map[string]string{"role": "website"}},
+	"George Maddaloni Website":    {Location: "George Maddaloni Website", Provider: "Home", Region: "internet", Labels: map[string]string{"role": "website"}},
+	"MA Connect Website":          {Location: "MA Connect Website", Provider: "Home", Region: "internet", Labels: map[string]string{"role": "website"}},
+	"Mastercard Website":          {Location: "Mastercard Website", Provider: "Home", Region: "internet", Labels: map[string]string{"role": "website"}},
+}
+
+// legacyCheckRe matches the earlier monitor's per-check names, such as
+// "Tokyo DNS (Japan)" or "Paris CDN (France)", which belong to the same
+// endpoint as "Tokyo (Japan)"
+var legacyCheckRe = regexp.MustCompile(`^(.+?) (?:DNS|CDN) (\(.+\))$`)
+
+// logLineRe matches result lines from both log formats:
+//
+//	2025-12-28 20:52:08 | [UP] Home Router        | Type: http | Response: 0.05s
+//	2025-12-29 10:22:11 | [DOWN] Tokyo, JP [AWS]  | Test: PING | Response: 0ms | Trend: BASELINE | Error: ...
+var logLineRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) \| \[(UP|DOWN|DEGRADED)\] (.*?)\s*\| (.*)$`)
+
+// LogImportStats counts what happened to the lines of one log file
+type LogImportStats struct {
+	Lines      int
+	Imported   int
+	Failures   int // imported results that were failures
+	Duplicates int // already in the archive or seen earlier in the import
+	TooOld     int // older than the archive retention
+	Skipped    int // cycle and diagnosis lines, blank lines
+	Unparsed   int
+	Unmapped   int // names with no mapping to a current endpoint
+}
+
+// logImporter turns log lines into archive records, mapping names to current endpoints
+type logImporter struct {
+	endpoints map[string]CloudEndpoint // "Location|Provider" -> endpoint
+	names     map[string]CloudEndpoint // legacy name -> endpoint
+	seen      map[string]bool          // "Service|unix second" already archived or imported
+	unmapped  map[string]int           // lines per logged name that could not be mapped
+}
+
+// newLogImporter maps logged names to the given endpoints and the legacy names
+func newLogImporter(endpoints []CloudEndpoint) *logImporter {
+	importer := &logImporter{
+		endpoints: make(map[string]CloudEndpoint),
+		names:     make(map[string]CloudEndpoint),
+		seen:      make(map[string]bool),
+		unmapped:  make(map[string]int),
+	}
+	for _, endpoint := range endpoints {
+		importer.endpoints[endpoint.Location+"|"+endpoint.Provider] = endpoint
+	}
+	for name, endpoint := range legacyEndpoints {
+		importer.names[name] = endpoint
+	}
+	return importer
+}
+
+// splitLocation splits "Tokyo, JP [AWS]" into location and provider
+func splitLocation(text string) (string, string, bool) {
+	open := strings.LastIndex(text, " [")
+	if open < 0 || !strings.HasSuffix(text, "]") {
+		return "", "", false
+	}
+	return text[:open], text[open+2 : len(text)-1], true
+}
+
+// endpointFor maps a logged location to the current endpoint it belongs to.
+// It reports false for a legacy name with no mapping.
+func (li *logImporter) endpointFor(name string) (CloudEndpoint, bool) {
+	endpoint, ok := li.names[name]
+	if !ok {
+		if matches := legacyCheckRe.FindStringSubmatch(name); matches != nil {
+			endpoint, ok = li.names[matches[1]+" "+matches[2]]
+		}
+	}
+	if !ok {
+		location, provider, ok := splitLocation(name)
+		if !ok {
+			return CloudEndpoint{}, false
+		}
+		endpoint = CloudEndpoint{Location: location, Provider: provider}
+	}
+	if known, ok := li.endpoints[endpoint.Location+"|"+endpoint.Provider]; ok {
+		return known, true
+	}
+	return endpoint, true
+}
+
+// Reasons a log line is not imported
+var (
+	errUnparsedLine = errors.New("not a result line")
+	errUnmappedName = errors.New("no endpoint for the logged name")
+)
+
+// parseLine converts one log line into an archive record
+func (li *logImporter) parseLine(line string) (ArchiveRecord, error) {
+	matches := logLineRe.FindStringSubmatch(line)
+	if matches == nil {
+		return ArchiveRecord{}, errUnparsedLine
+	}
+	timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", matches[1], time.Local)
+	if err != nil {
+		return ArchiveRecord{}, errUnparsedLine
+	}
+
+	endpoint, ok := li.endpointFor(matches[3])
+	if !ok {
+		li.unmapped[matches[3]]++
+		return ArchiveRecord{}, errUnmappedName
+	}
+	record := ArchiveRecord{
+		Timestamp:   timestamp,
+		Location:    endpoint.Location,
+		Region:      endpoint.Region,
+		Provider:    endpoint.Provider,
+		Hostname:    endpoint.Hostname,
+		Labels:      endpoint.Labels,
+		Coordinates: endpoint.Coordinates,
+		Online:      matches[2] != "DOWN",
+		Degraded:    matches[2] == "DEGRADED",
+	}
+	if record.Coordinates == nil {
+		record.Coordinates = regionCoordinatesFor(endpoint.Provider, endpoint.Region)
+	}
+
+	parts := strings.Split(matches[4], " | ")
+	for i := 0; i < len(parts); i++ {
+		key, value, ok := strings.Cut(parts[i], ": ")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Test", "Type":
+			record.TestType = TestType(strings.ToUpper(value))
+		case "Response":
+			// "805ms" from the monitor, "0.05s" from the legacy health monitor
+			if d, err := time.ParseDuration(value); err == nil {
+				record.ResponseTime = int64(d)
+			}
+		case "Trend":
+			record.Trend = value
+		case "Target":
+			record.Target = value
+		case "Error":
+			// Error text may itself contain " | "; keep everything up to the next known key
+			for i+1 < len(parts) && !strings.HasPrefix(parts[i+1], "Maintenance: ") &&
+				!strings.HasPrefix(parts[i+1], "Cycle: ") && !strings.HasPrefix(parts[i+1], "Detail: ") {
+				i++
+				value += " | " + parts[i]
+			}
+			record.Error = value
+		case "Detail":
+			record.Details = map[string]string{"output": value}
+		case "Maintenance":
+			record.Maintenance = value
+		case "Cycle":
+			record.Cycle = value
+		}
+	}
+	if record.TestType == "" {
+		return ArchiveRecord{}, errUnparsedLine
+	}
+	if !record.Online {
+		record.ResponseTime = 0
+		record.ErrorCategory = errorCategory(TestResult{
+			TestType:    record.TestType,
+			Error:       record.Error,
+			LocalOutage: record.Cycle == CycleLocalOutage,
+		})
+	}
+	record.Service = serviceKeyFor(CloudEndpoint{Location: record.Location, Provider: record.Provider}, record.TestType, record.Target)
+	return record, nil
+}
+
+// importLogFile reads one log file into archive records, dropping duplicates
+// and records the archive would prune
+func (li *logImporter) importLogFile(filename string, cutoff time.Time) ([]ArchiveRecord, LogImportStats, error) {
+	var stats LogImportStats
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, stats, err
+	}
+	defer file.Close()
+
+	// Rotated backups may be gzipped
+	var reader io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, stats, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var records []ArchiveRecord
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		stats.Lines++
+		if line == "" || strings.Contains(line, "| [LOCAL OUTAGE]") || strings.Contains(line, "| [DIAGNOSIS]") {
+			stats.Skipped++
+			continue
+		}
+		record, err := li.parseLine(line)
+		if err == errUnmappedName {
+			stats.Unmapped++
+			continue
+		}
+		if err != nil {
+			stats.Unparsed++
+			continue
+		}
+		if record.Timestamp.Before(cutoff) {
+			stats.TooOld++
+			continue
+		}
+		key := record.Service + "|" + strconv.FormatInt(record.Timestamp.Unix(), 10)
+		if li.seen[key] {
+			stats.Duplicates++
+			continue
+		}
+		li.seen[key] = true
+		records = append(records, record)
+		stats.Imported++
+		if !record.Online {
+			stats.Failures++
+		}
+	}
+	return records, stats, scanner.Err()
+}
+
+// runImportLogsCommand backfills the archive from cloud_latency.log and health_monitor.log:
+//
+//	go run main.go import-logs
+//	go run main.go import-logs -all
+//	go run main.go import-logs -dry-run -map names.json old/cloud_latency.log
+func runImportLogsCommand(args []string) error {
+	fs := flag.NewFlagSet("import-logs", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "parse and report without writing the archive")
+	mapFile := fs.String("map", "", `JSON file mapping logged names to "Location [Provider]"`)
+	all := fs.Bool("all", false, "import lines older than the retention period and keep those days from being pruned")
+	fs.Parse(args)
+
+	config, err := loadConfig("monitor_config.json")
+	if err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{config.Log.File, "health_monitor.log"}
+	}
+	archive, err := NewHistoryArchive(config.History)
+	if err != nil {
+		return err
+	}
+
+	importer := newLogImporter(append(defaultEndpoints(), config.Endpoints...))
+	if *mapFile != "" {
+		data, err := ioutil.ReadFile(*mapFile)
+		if err != nil {
+			return err
+		}
+		var names map[string]string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return fmt.Errorf("%s: %v", *mapFile, err)
+		}
+		for name, target := range names {
+			location, provider, ok := splitLocation(target)
+			if !ok {
+				return fmt.Errorf("%s: %q should look like \"Location [Provider]\"", *mapFile, target)
+			}
+			importer.names[name] = CloudEndpoint{Location: location, Provider: provider}
+		}
+	}
+
+	// Everything already archived counts as seen, so re-running the import is harmless
+	cutoff := time.Now().Add(-archive.retention)
+	from := cutoff
+	if *all {
+		from = time.Time{}
+	}
+	err = archive.Scan(from, time.Now(), func(record ArchiveRecord) {
+		importer.seen[record.Service+"|"+strconv.FormatInt(record.Timestamp.Unix(), 10)] = true
+	})
+	if err != nil {
+		return err
+	}
+
+	var records []ArchiveRecord
+	var total LogImportStats
+	for _, filename := range files {
+		imported, stats, err := importer.importLogFile(filename, from)
+		if os.IsNotExist(err) {
+			fmt.Printf("%sWarning: %s not found, skipping%s\n", ColorYellow, filename, ColorReset)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		fmt.Printf("%s: %d lines, %d imported (%d failures), %d duplicates, %d older than retention, %d skipped, %d unmapped, %d unparsed\n",
+			filename, stats.Lines, stats.Imported, stats.Failures, stats.Duplicates, stats.TooOld, stats.Skipped, stats.Unmapped, stats.Unparsed)
+		records = append(records, imported...)
+		total.Imported += stats.Imported
+		total.TooOld += stats.TooOld
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	services := make(map[string]bool)
+	var oldDays []string
+	for _, record := range records {
+		services[record.Service] = true
+		if day := record.Timestamp.Format("2006-01-02"); record.Timestamp.Before(cutoff) &&
+			(len(oldDays) == 0 || oldDays[len(oldDays)-1] != day) {
+			oldDays = append(oldDays, day)
+		}
+	}
+
+	if len(importer.unmapped) > 0 {
+		names := make([]string, 0, len(importer.unmapped))
+		for name, lines := range importer.unmapped {
+			names = append(names, fmt.Sprintf("%q (%d lines)", name, lines))
+		}
+		sort.Strings(names)
+		fmt.Printf("%sWarning: no endpoint for %s; add them to a -map file to import those lines%s\n",
+			ColorYellow, strings.Join(names, ", "), ColorReset)
+	}
+	if total.TooOld > 0 {
+		fmt.Printf("%sWarning: %d records are older than the %d-day retention; use -all to import them anyway%s\n",
+			ColorYellow, total.TooOld, int(archive.retention/(24*time.Hour)), ColorReset)
+	}
+	if *dryRun {
+		fmt.Printf("Dry run: would archive %d records for %d services\n", len(records), len(services))
+		if len(oldDays) > 0 {
+			fmt.Printf("Dry run: would keep %d days older than the retention period\n", len(oldDays))
+		}
+		return nil
+	}
+
+	// Mark the old days before writing, since Append prunes
+	if err := archive.Keep(oldDays); err != nil {
+		return err
+	}
+	if err := archive.Append(records); err != nil {
+		return err
+	}
+	fmt.Printf("Archived %d records for %d services in %s\n", len(records), len(services), archive.dir)
+	if len(oldDays) > 0 {
+		fmt.Printf("Kept %d days older than the retention period (%s to %s); delete their .keep files to let them expire\n",
+			len(oldDays), oldDays[0], oldDays[len(oldDays)-1])
+	}
+	return nil
+}
+
+// SLO objective kinds
+const (
+	SLOAvailability = "availability"
+	SLOLatency      = "latency"
+)
+
+// SLOConfig declares a service level objective for a group of endpoint tests.
+// "99.9% of HTTP checks succeed over 30 days" is an availability objective
+// with Target 99.9; "p95 PING < 120ms over 7 days" is a latency objective
+// with Target 95 and ThresholdMs 120.
+type SLOConfig struct {
+	Name            string
+	Match           EndpointMatcher
+	Objective       string     // availability or latency
+	Target          float64    // percentage of good checks required
+	ThresholdMs     float64    // latency: checks faster than this are good
+	Window          Duration   // compliance period, e.g. "30d"
+	BurnRateWindows []Duration // windows reported for burn rate (default 1h, 6h, 24h, 72h)
+}
+
+// BurnRate is how fast an SLO consumes its error budget over one window;
+// 1.0 spends exactly the budget by the end of the SLO window
+type BurnRate struct {
+	Window Duration
+	Rate   float64
+	Good   int
+	Total  int
+}
+
+// SLOStatus is the evaluated state of one SLO
+type SLOStatus struct {
+	Name                 string
+	Objective            string
+	Target               float64
+	ThresholdMs          float64 `json:",omitempty"`
+	Window               Duration
+	Good                 int
+	Total                int
+	SLI                  float64 // percentage of good checks over the window
+	ErrorBudgetRemaining float64 // percentage of the error budget left; negative when exhausted
+	BurnRates            []BurnRate
+	Compliant            bool
+	UpdatedAt            time.Time
+	Match                EndpointMatcher // endpoint tests the SLO covers
+}
+
+// sloBucket counts good and total checks for one five minute slot
+type sloBucket struct {
+	Good  int
+	Total int
+}
+
+const sloBucketSize = 5 * time.Minute
+
+// SLOTracker keeps bucketed good/total counts per SLO so objectives over
+// long windows can be evaluated every cycle without rereading the archive
+type SLOTracker struct {
+	slos    []SLOConfig
+	buckets []map[int64]*sloBucket
+	mu      sync.Mutex
+}
+
+// defaultBurnRateWindows are reported when an SLO lists no BurnRateWindows
+var defaultBurnRateWindows = []Duration{
+	Duration(time.Hour), Duration(6 * time.Hour),
+	Duration(24 * time.Hour), Duration(72 * time.Hour),
+}
+
+// NewSLOTracker validates the SLO definitions
+func NewSLOTracker(configs []SLOConfig) (*SLOTracker, error) {
+	tracker := &SLOTracker{}
+
+	for _, slo := range configs {
+		switch slo.Objective {
+		case SLOAvailability:
+		case SLOLatency:
+			if slo.ThresholdMs <= 0 {
+				return nil, fmt.Errorf("SLO %q: latency objectives need ThresholdMs", slo.Name)
+			}
+		default:
+			return nil, fmt.Errorf("SLO %q: unknown objective %q", slo.Name, slo.Objective)
+		}
+		if slo.Target <= 0 || slo.Target >= 100 {
+			return nil, fmt.Errorf("SLO %q: Target must be between 0 and 100", slo.Name)
+		}
+		if slo.Window == 0 {
+			slo.Window = Duration(30 * 24 * time.Hour)
+		}
+		if len(slo.BurnRateWindows) == 0 {
+			slo.BurnRateWindows = defaultBurnRateWindows
+		}
+
+		tracker.slos = append(tracker.slos, slo)
+		tracker.buckets = append(tracker.buckets, make(map[int64]*sloBucket))
+	}
+
+	return tracker, nil
+}
+
+// record counts one check against every SLO that selects it. Checks under
+// maintenance or during a local network outage are excluded.
+func (st *SLOTracker) record(endpoint CloudEndpoint, testType TestType, timestamp time.Time,
+	online bool, responseTime time.Duration, maintenance string, localOutage bool) {
+
+	if maintenance != "" || localOutage {
+		return
+	}
+
+	slot := timestamp.Unix() / int64(sloBucketSize/time.Second)
+
+	for i, slo := range st.slos {
+		if !slo.Match.Matches(endpoint, testType) {
+			continue
+		}
+
+		var good bool
+		switch slo.Objective {
+		case SLOAvailability:
+			good = online
+		case SLOLatency:
+			// Latency objectives only judge checks that got a response
+			if !online {
+				continue
+			}
+			good = float64(responseTime)/float64(time.Millisecond) < slo.ThresholdMs
+		}
+
+		bucket := st.buckets[i][slot]
+		if bucket == nil {
+			bucket = &sloBucket{}
+			st.buckets[i][slot] = bucket
+		}
+		bucket.Total++
+		if good {
+			bucket.Good++
+		}
+	}
+}
+
+// Record counts a cycle's results
+func (st *SLOTracker) Record(results []TestResult) {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, result := range results {
+		st.record(result.Endpoint, result.TestType, result.Timestamp,
+			result.Online, result.ResponseTime, result.Maintenance, result.LocalOutage)
+	}
+}
+
+// LoadFromArchive rebuilds the buckets from archived results after a restart
+func (st *SLOTracker) LoadFromArchive(archive *HistoryArchive) error {
+	if st == nil || archive == nil || len(st.slos) == 0 {
+		return nil
+	}
+
+	longest := time.Duration(0)
+	for _, slo := range st.slos {
+		if time.Duration(slo.Window) > longest {
+			longest = time.Duration(slo.Window)
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return archive.Scan(time.Now().Add(-longest), time.Now(), func(record ArchiveRecord) {
+		endpoint := CloudEndpoint{
+			Location: record.Location,
+			Region:   record.Region,
+			Provider: record.Provider,
+			Hostname: record.Hostname,
+			Labels:   record.Labels,
+		}
+		st.record(endpoint, record.TestType, record.Timestamp, record.Online,
+			time.Duration(record.ResponseTime), record.Maintenance, record.Cycle == CycleLocalOutage)
+	})
+}
+
+// sum totals the buckets of one SLO that fall inside the window ending now
+func (st *SLOTracker) sum(index int, now time.Time, window time.Duration) (good, total int) {
+	slotSeconds := int64(sloBucketSize / time.Second)
+	from := now.Add(-window).Unix() / slotSeconds
+	for slot, bucket := range st.buckets[index] {
+		if slot > from {
+			good += bucket.Good
+			total += bucket.Total
+		}
+	}
+	return good, total
+}
+
+// Evaluate computes the SLI, remaining error budget and burn rates of every SLO
+func (st *SLOTracker) Evaluate(now time.Time) []SLOStatus {
+	if st == nil {
+		return nil
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	statuses := make([]SLOStatus, 0, len(st.slos))
+	slotSeconds := int64(sloBucketSize / time.Second)
+
+	for i, slo := range st.slos {
+		// Drop buckets that have aged out of the SLO window
+		oldest := now.Add(-time.Duration(slo.Window)).Unix() / slotSeconds
+		for slot := range st.buckets[i] {
+			if slot <= oldest {
+				delete(st.buckets[i], slot)
+			}
+		}
+
+		good, total := st.sum(i, now, time.Duration(slo.Window))
+		budget := 1 - slo.Target/100
+
+		status := SLOStatus{
+			Name:                 slo.Name,
+			Objective:            slo.Objective,
+			Target:               slo.Target,
+			ThresholdMs:          slo.ThresholdMs,
+			Window:               slo.Window,
+			Good:                 good,
+			Total:                total,
+			SLI:                  100,
+			ErrorBudgetRemaining: 100,
+			Compliant:            true,
+			UpdatedAt:            now,
+			Match:                slo.Match,
+		}
+
+		if total > 0 {
+			badRatio := float64(total-good) / float64(total)
+			status.SLI = float64(good) / float64(total) * 100
+			status.ErrorBudgetRemaining = (1 - badRatio/budget) * 100
+			status.Compliant = status.SLI >= slo.Target
+		}
+
+		for _, window := range slo.BurnRateWindows {
+			windowGood, windowTotal := st.sum(i, now, time.Duration(window))
+			rate := 0.0
+			if windowTotal > 0 {
+				rate = float64(windowTotal-windowGood) / float64(windowTotal) / budget
+			}
+			status.BurnRates = append(status.BurnRates, BurnRate{
+				Window: window,
+				Rate:   rate,
+				Good:   windowGood,
+				Total:  windowTotal,
+			})
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// saveSLOStatus writes the evaluated SLOs for the analyzer, dashboard and CSV export
+func saveSLOStatus(filename string, statuses []SLOStatus) error {
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// printSLOStatus shows one line per SLO in the cycle summary
+func printSLOStatus(statuses []SLOStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s=== SLO STATUS ===%s\n", ColorCyan, ColorReset)
+	for _, status := range statuses {
+		color := ColorGreen
+		if !status.Compliant || status.ErrorBudgetRemaining < 0 {
+			color = ColorRed
+		} else if status.ErrorBudgetRemaining < 25 {
+			color = ColorYellow
+		}
+
+		fmt.Printf("%s%-30s SLI %7.3f%% (target %.3g%% over %s) | budget left %6.1f%%%s",
+			color, status.Name, status.SLI, status.Target, formatWindow(status.Window),
+			status.ErrorBudgetRemaining, ColorReset)
+		for _, burn := range status.BurnRates {
+			fmt.Printf(" | %s %.2fx", formatWindow(burn.Window), burn.Rate)
+		}
+		fmt.Println()
+	}
+}
+
+// formatWindow renders whole days as "30d" and shorter windows as "6h"
+func formatWindow(d Duration) string {
+	duration := time.Duration(d)
+	if duration >= 24*time.Hour && duration%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", duration/(24*time.Hour))
+	}
+	if duration%time.Hour == 0 {
+		return fmt.Sprintf("%dh", duration/time.Hour)
+	}
+	return duration.String()
+}
+
+// MetricsConfig controls the Prometheus metrics endpoint
+type MetricsConfig struct {
+	Listen  string    // address to serve on, e.g. ":9105"; empty disables the endpoint
+	Path    string    // default /metrics
+	Buckets []float64 // latency histogram buckets in seconds
+}
+
+// Default latency histogram buckets in seconds, from fast DNS lookups to slow
+// intercontinental HTTPS requests
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Error categories used to label failed checks
+const (
+	ErrorCategoryDNS     = "dns"
+	ErrorCategoryTimeout = "timeout"
+	ErrorCategoryRefused = "connection_refused"
+	ErrorCategoryReset   = "connection_reset"
+	ErrorCategoryTLS     = "tls"
+	ErrorCategoryHTTP    = "http_status"
+	ErrorCategoryNoReply = "no_reply"
+	ErrorCategoryLocal   = "local_network"
+	ErrorCategoryOther   = "other"
+)
+
+// errorCategory sorts a failed check into a coarse category for counting
+func errorCategory(result TestResult) string {
+	if result.LocalOutage {
+		return ErrorCategoryLocal
+	}
+
+	message := strings.ToLower(result.Error)
+	if phase := failedPhase(result); phase != nil {
+		message = strings.ToLower(phase.Error)
+		switch phase.Name {
+		case "dns":
+			return ErrorCategoryDNS
+		case "tls":
+			if !strings.Contains(message, "timeout") {
+				return ErrorCategoryTLS
+			}
+		}
+	}
+
+	switch {
+	case result.TestType == TestTypeDNS, strings.Contains(message, "dns resolution failed"),
+		strings.Contains(message, "no such host"):
+		return ErrorCategoryDNS
+	case strings.Contains(message, "timeout"), strings.Contains(message, "deadline exceeded"),
+		strings.Contains(message, "did not complete"):
+		return ErrorCategoryTimeout
+	case strings.Contains(message, "connection refused"):
+		return ErrorCategoryRefused
+	case strings.Contains(message, "connection reset"), strings.Contains(message, "eof"):
+		return ErrorCategoryReset
+	case strings.Contains(message, "tls"), strings.Contains(message, "x509"),
+		strings.Contains(message, "certificate"):
+		return ErrorCategoryTLS
+	case result.StatusCode >= 400:
+		return ErrorCategoryHTTP
+	case result.TestType == TestTypePing:
+		return ErrorCategoryNoReply
+	}
+	return ErrorCategoryOther
+}
+
+// probeMetrics accumulates the series for one endpoint test
+type probeMetrics struct {
+	endpoint  CloudEndpoint
+	testType  TestType
+	target    string
+	buckets   []uint64 // per-bucket counts, made cumulative when written
+	count     uint64
+	sum       float64
+	up        bool
+	degraded  bool
+	loss      float64
+	lastCheck time.Time
+	errors    map[string]uint64
+	fields    map[string]float64
+}
+
+// Metrics collects probe results and monitor internals for Prometheus
+type Metrics struct {
+	mu                    sync.Mutex
+	buckets               []float64
+	probes                map[string]*probeMetrics
+	cycles                map[string]uint64
+	lastCycleDuration     float64
+	lastCycleTime         time.Time
+	inFlight              int
+	maxInFlight           int // peak during the running cycle
+	cycleMaxInFlight      int // peak during the last completed cycle
+	historySaveFailures   uint64
+	archiveWriteFailures  uint64
+	alertStateSaveFailure uint64
+	startTime             time.Time
+	sinks                 *SinkPipeline
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics(config MetricsConfig) *Metrics {
+	buckets := config.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:   buckets,
+		probes:    make(map[string]*probeMetrics),
+		cycles:    make(map[string]uint64),
+		startTime: time.Now(),
+	}
+}
+
+// ProbeStarted and ProbeFinished track how many tests run concurrently
+func (m *Metrics) ProbeStarted() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.mu.Unlock()
+}
+
+func (m *Metrics) ProbeFinished() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+}
+
+// ObserveResults records the outcome of every test in a cycle
+func (m *Metrics) ObserveResults(results []TestResult) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, result := range results {
+		key := serviceKeyFor(result.Endpoint, result.TestType, result.Target)
+		probe := m.probes[key]
+		if probe == nil {
+			probe = &probeMetrics{
+				testType: result.TestType,
+				target:   result.Target,
+				buckets:  make([]uint64, len(m.buckets)),
+				errors:   make(map[string]uint64),
+			}
+			m.probes[key] = probe
+		}
+
+		probe.endpoint = result.Endpoint
+		probe.up = result.Online
+		probe.degraded = result.Degraded
+		probe.loss = result.Loss
+		probe.fields = result.Fields
+		probe.lastCheck = result.Timestamp
+
+		if result.Online {
+			seconds := result.ResponseTime.Seconds()
+			probe.count++
+			probe.sum += seconds
+			for i, bound := range m.buckets {
+				if seconds <= bound {
+					probe.buckets[i]++
+					break
+				}
+			}
+		} else {
+			probe.errors[errorCategory(result)]++
+		}
+	}
+}
+
+// ObserveCycle records how long a cycle took and how it was classified
+func (m *Metrics) ObserveCycle(duration time.Duration, cycle string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.cycles[cycle]++
+	m.lastCycleDuration = duration.Seconds()
+	m.lastCycleTime = time.Now()
+	m.cycleMaxInFlight = m.maxInFlight
+	m.maxInFlight = m.inFlight
+	m.mu.Unlock()
+}
+
+// WatchSinks exposes the health of the sink pipeline
+func (m *Metrics) WatchSinks(sinks *SinkPipeline) {
+	m.mu.Lock()
+	m.sinks = sinks
+	m.mu.Unlock()
+}
+
+// HistorySaveFailed, ArchiveWriteFailed and AlertStateSaveFailed count
+// persistence errors that would otherwise only appear on the console
+func (m *Metrics) HistorySaveFailed() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.historySaveFailures++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) ArchiveWriteFailed() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.archiveWriteFailures++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) AlertStateSaveFailed() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.alertStateSaveFailure++
+	m.mu.Unlock()
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// formatLabels renders name/value pairs as {a="1",b="2"}
+func formatLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], escapeLabelValue(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatFloat renders a sample value the way Prometheus expects
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+	header := func(name, kind, help string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	keys := make([]string, 0, len(m.probes))
+	for key := range m.probes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	endpointLabels := func(probe *probeMetrics, extra ...string) []string {
+		labels := []string{
+			"location", probe.endpoint.Location,
+			"region", probe.endpoint.Region,
+			"provider", probe.endpoint.Provider,
+			"hostname", probe.endpoint.Hostname,
+			"test_type", strings.ToLower(string(probe.testType)),
+		}
+		if probe.target != "" {
+			labels = append(labels, "target", probe.target)
+		}
+		return append(labels, extra...)
+	}
+
+	header("cloud_latency_response_seconds", "histogram", "Response time of successful checks.")
+	for _, key := range keys {
+		probe := m.probes[key]
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += probe.buckets[i]
+			fmt.Fprintf(&buf, "cloud_latency_response_seconds_bucket%s %d\n",
+				formatLabels(endpointLabels(probe, "le", formatFloat(bound))...), cumulative)
+		}
+		fmt.Fprintf(&buf, "cloud_latency_response_seconds_bucket%s %d\n",
+			formatLabels(endpointLabels(probe, "le", "+Inf")...), probe.count)
+		fmt.Fprintf(&buf, "cloud_latency_response_seconds_sum%s %s\n",
+			formatLabels(endpointLabels(probe)...), formatFloat(probe.sum))
+		fmt.Fprintf(&buf, "cloud_latency_response_seconds_count%s %d\n",
+			formatLabels(endpointLabels(probe)...), probe.count)
+	}
+
+	header("cloud_latency_up", "gauge", "Whether the last check succeeded (1) or failed (0).")
+	for _, key := range keys {
+		probe := m.probes[key]
+		up := 0.0
+		if probe.up {
+			up = 1
+		}
+		fmt.Fprintf(&buf, "cloud_latency_up%s %s\n", formatLabels(endpointLabels(probe)...), formatFloat(up))
+	}
+
+	header("cloud_latency_degraded", "gauge", "Whether the last check reported a degraded state (1), e.g. EXEC exit code 1.")
+	for _, key := range keys {
+		probe := m.probes[key]
+		degraded := 0.0
+		if probe.degraded {
+			degraded = 1
+		}
+		fmt.Fprintf(&buf, "cloud_latency_degraded%s %s\n", formatLabels(endpointLabels(probe)...), formatFloat(degraded))
+	}
+
+	header("cloud_latency_probe_value", "gauge", "Probe-specific measurements of the last check, such as tls_days_left or EXEC perfdata.")
+	for _, key := range keys {
+		probe := m.probes[key]
+		for _, name := range sortedFieldNames(probe.fields) {
+			fmt.Fprintf(&buf, "cloud_latency_probe_value%s %s\n",
+				formatLabels(endpointLabels(probe, "field", name)...), formatFloat(probe.fields[name]))
+		}
+	}
+
+	header("cloud_latency_packet_loss_ratio", "gauge", "ICMP packet loss of the last PING check, from 0 to 1.")
+	for _, key := range keys {
+		probe := m.probes[key]
+		if probe.testType != TestTypePing {
+			continue
+		}
+		fmt.Fprintf(&buf, "cloud_latency_packet_loss_ratio%s %s\n",
+			formatLabels(endpointLabels(probe)...), formatFloat(probe.loss/100))
+	}
+
+	header("cloud_latency_last_check_timestamp_seconds", "gauge", "Unix time of the last check.")
+	for _, key := range keys {
+		probe := m.probes[key]
+		fmt.Fprintf(&buf, "cloud_latency_last_check_timestamp_seconds%s %s\n",
+			formatLabels(endpointLabels(probe)...), formatFloat(float64(probe.lastCheck.UnixNano())/1e9))
+	}
+
+	header("cloud_latency_check_errors_total", "counter", "Failed checks by error category.")
+	for _, key := range keys {
+		probe := m.probes[key]
+		categories := make([]string, 0, len(probe.errors))
+		for category := range probe.errors {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			fmt.Fprintf(&buf, "cloud_latency_check_errors_total%s %d\n",
+				formatLabels(endpointLabels(probe, "category", category)...), probe.errors[category])
+		}
+	}
+
+	header("cloud_latency_cycle_duration_seconds", "gauge", "Duration of the last check cycle.")
+	fmt.Fprintf(&buf, "cloud_latency_cycle_duration_seconds %s\n", formatFloat(m.lastCycleDuration))
+
+	header("cloud_latency_last_cycle_timestamp_seconds", "gauge", "Unix time the last check cycle finished.")
+	lastCycle := 0.0
+	if !m.lastCycleTime.IsZero() {
+		lastCycle = float64(m.lastCycleTime.UnixNano()) / 1e9
+	}
+	fmt.Fprintf(&buf, "cloud_latency_last_cycle_timestamp_seconds %s\n", formatFloat(lastCycle))
+
+	header("cloud_latency_cycles_total", "counter", "Check cycles by classification.")
+	cycles := make([]string, 0, len(m.cycles))
+	for cycle := range m.cycles {
+		cycles = append(cycles, cycle)
+	}
+	sort.Strings(cycles)
+	for _, cycle := range cycles {
+		fmt.Fprintf(&buf, "cloud_latency_cycles_total%s %d\n", formatLabels("classification", cycle), m.cycles[cycle])
+	}
+
+	header("cloud_latency_probes_in_flight", "gauge", "Checks currently running.")
+	fmt.Fprintf(&buf, "cloud_latency_probes_in_flight %d\n", m.inFlight)
+
+	header("cloud_latency_probes_in_flight_max", "gauge", "Highest number of concurrent checks during the last cycle.")
+	fmt.Fprintf(&buf, "cloud_latency_probes_in_flight_max %d\n", m.cycleMaxInFlight)
+
+	header("cloud_latency_history_save_failures_total", "counter", "Failed writes of latency_history.json.")
+	fmt.Fprintf(&buf, "cloud_latency_history_save_failures_total %d\n", m.historySaveFailures)
+
+	header("cloud_latency_archive_write_failures_total", "counter", "Failed appends to the history archive.")
+	fmt.Fprintf(&buf, "cloud_latency_archive_write_failures_total %d\n", m.archiveWriteFailures)
+
+	header("cloud_latency_alert_state_save_failures_total", "counter", "Failed writes of alert_state.json.")
+	fmt.Fprintf(&buf, "cloud_latency_alert_state_save_failures_total %d\n", m.alertStateSaveFailure)
+
+	if m.sinks != nil {
+		health := m.sinks.Health()
+
+		header("cloud_latency_sink_healthy", "gauge", "Whether the sink is keeping up without errors.")
+		for _, sink := range health {
+			healthy := 0
+			if sink.Healthy {
+				healthy = 1
+			}
+			fmt.Fprintf(&buf, "cloud_latency_sink_healthy%s %d\n", formatLabels("sink", sink.Name), healthy)
+		}
+
+		header("cloud_latency_sink_queue_length", "gauge", "Cycles waiting to be written by the sink.")
+		for _, sink := range health {
+			fmt.Fprintf(&buf, "cloud_latency_sink_queue_length%s %d\n", formatLabels("sink", sink.Name), sink.Queued)
+		}
+
+		header("cloud_latency_sink_delivered_total", "counter", "Cycles written by the sink.")
+		for _, sink := range health {
+			fmt.Fprintf(&buf, "cloud_latency_sink_delivered_total%s %d\n", formatLabels("sink", sink.Name), sink.Delivered)
+		}
+
+		header("cloud_latency_sink_failures_total", "counter", "Cycles the sink failed to write.")
+		for _, sink := range health {
+			fmt.Fprintf(&buf, "cloud_latency_sink_failures_total%s %d\n", formatLabels("sink", sink.Name), sink.Failed)
+		}
+
+		header("cloud_latency_sink_dropped_total", "counter", "Cycles discarded because the sink's buffer was full.")
+		for _, sink := range health {
+			fmt.Fprintf(&buf, "cloud_latency_sink_dropped_total%s %d\n", formatLabels("sink", sink.Name), sink.Dropped)
+		}
+	}
+
+	header("cloud_latency_start_time_seconds", "gauge", "Unix time the monitor started.")
+	fmt.Fprintf(&buf, "cloud_latency_start_time_seconds %s\n", formatFloat(float64(m.startTime.UnixNano())/1e9))
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// startMetricsServer serves /metrics in the background
+func startMetricsServer(config MetricsConfig, metrics *Metrics) {
+	metricsPath := config.Path
+	if metricsPath == "" {
+		metricsPath = "/metrics"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, metrics)
+
+	server := &http.Server{
+		Addr:         config.Listen,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("%sWarning: Metrics endpoint stopped: %v%s\n", ColorYellow, err, ColorReset)
+		}
+	}()
+}
+
+// LiveConfig enables the live feed of results for the dashboard
+type LiveConfig struct {
+	Listen    string   // ":9106", "127.0.0.1:9106" or "unix:/tmp/cloud_latency.sock"; empty disables the feed
+	Path      string   // default /events
+	KeepAlive Duration // comment sent to idle clients, default 15s
+}
+
+// liveCycleEvent is sent when a cycle finishes
+type liveCycleEvent struct {
+	Start     time.Time           `json:"start"`
+	End       time.Time           `json:"end"`
+	Cycle     string              `json:"cycle"`
+	Failed    int                 `json:"failed"`
+	Total     int                 `json:"total"`
+	Diagnoses []logDiagnosisEntry `json:"diagnoses,omitempty"`
+}
+
+// liveEvent is one Server-Sent Event
+type liveEvent struct {
+	Name string // result or cycle
+	Data []byte
+}
+
+// liveClientBuffer is how many events a client may fall behind before it is dropped
+const liveClientBuffer = 512
+
+// LiveFeed streams every test result as it completes, and a summary at the
+// end of each cycle, as Server-Sent Events. New clients first receive the
+// latest result of every service so they start with a full picture.
+type LiveFeed struct {
+	keepAlive time.Duration
+	clients   map[chan liveEvent]bool
+	latest    map[string]liveEvent // by service key
+	mu        sync.Mutex
+}
+
+// NewLiveFeed creates an empty live feed
+func NewLiveFeed(config LiveConfig) *LiveFeed {
+	keepAlive := time.Duration(config.KeepAlive)
+	if keepAlive <= 0 {
+		keepAlive = 15 * time.Second
+	}
+	return &LiveFeed{
+		keepAlive: keepAlive,
+		clients:   make(map[chan liveEvent]bool),
+		latest:    make(map[string]liveEvent),
+	}
+}
+
+// broadcast sends an event to every client, dropping clients that have fallen too far behind
+func (lf *LiveFeed) broadcast(event liveEvent) {
+	for client := range lf.clients {
+		select {
+		case client <- event:
+		default:
+			// The client reconnects and gets a fresh snapshot
+			delete(lf.clients, client)
+			close(client)
+		}
+	}
+}
+
+// PublishResult sends one test result as soon as its probe finishes
+func (lf *LiveFeed) PublishResult(result TestResult) {
+	if lf == nil {
+		return
+	}
+	data, err := json.Marshal(newLogResultEntry(result))
+	if err != nil {
+		return
+	}
+	event := liveEvent{Name: "result", Data: data}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	lf.latest[serviceKeyFor(result.Endpoint, result.TestType, result.Target)] = event
+	lf.broadcast(event)
+}
+
+func (lf *LiveFeed) Name() string { return "live" }
+
+// WriteCycle sends the cycle summary once the results have been classified and diagnosed
+func (lf *LiveFeed) WriteCycle(report *CycleReport) error {
+	summary := liveCycleEvent{
+		Start:  report.Start,
+		End:    report.End,
+		Cycle:  report.Cycle,
+		Failed: countFailed(report.Results),
+		Total:  len(report.Results),
+	}
+	for _, diagnosis := range report.Diagnoses {
+		summary.Diagnoses = append(summary.Diagnoses, logDiagnosisEntry{
+			Time:     report.Start,
+			Event:    "diagnosis",
+			Location: diagnosis.Endpoint.Location,
+			Region:   diagnosis.Endpoint.Region,
+			Provider: diagnosis.Endpoint.Provider,
+			Verdict:  diagnosis.Verdict,
+			Reason:   diagnosis.Reason,
+		})
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	lf.broadcast(liveEvent{Name: "cycle", Data: data})
+	return nil
+}
+
+// subscribe registers a client and returns the snapshot it should be sent first
+func (lf *LiveFeed) subscribe() (chan liveEvent, []liveEvent) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	client := make(chan liveEvent, liveClientBuffer)
+	lf.clients[client] = true
+
+	keys := make([]string, 0, len(lf.latest))
+	for key := range lf.latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot := make([]liveEvent, 0, len(keys))
+	for _, key := range keys {
+		snapshot = append(snapshot, lf.latest[key])
+	}
+	return client, snapshot
+}
+
+func (lf *LiveFeed) unsubscribe(client chan liveEvent) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.clients[client] {
+		delete(lf.clients, client)
+		close(client)
+	}
+}
+
+// writeSSE writes one event in text/event-stream framing
+func writeSSE(w io.Writer, event liveEvent) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data)
+	return err
+}
+
+// ServeHTTP streams the feed to one client until it disconnects
+func (lf *LiveFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	client, snapshot := lf.subscribe()
+	defer lf.unsubscribe(client)
+
+	for _, event := range snapshot {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(lf.keepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-client:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// startLiveServer serves the live feed on a TCP address or a unix socket
+func startLiveServer(config LiveConfig, feed *LiveFeed) error {
+	feedPath := config.Path
+	if feedPath == "" {
+		feedPath = "/events"
+	}
+
+	network, address := "tcp", config.Listen
+	if socket, ok := strings.CutPrefix(config.Listen, "unix:"); ok {
+		network, address = "unix", socket
+		os.Remove(socket) // left behind by a previous run
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(feedPath, feed)
+
+	// No write timeout: event streams stay open
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("%sWarning: Live feed stopped: %v%s\n", ColorYellow, err, ColorReset)
+		}
+	}()
+	return nil
+}
+
+// OTLPConfig controls the optional OpenTelemetry exporter
+type OTLPConfig struct {
+	Endpoint       string            // collector base URL, e.g. http://localhost:4318; empty disables
+	Protocol       string            // http/protobuf (default) or http/json
+	Headers        map[string]string // extra request headers, e.g. an API key
+	Compression    string            // "gzip" or empty
+	Timeout        Duration          // per request, default 10s
+	ServiceName    string            // resource service.name, default cloud-latency-monitor
+	DisableMetrics bool
+	DisableTraces  bool
+}
+
+// OTLP protocols
+const (
+	OTLPProtocolProtobuf = "http/protobuf"
+	OTLPProtocolJSON     = "http/json"
+)
+
+// OTLP enum values
+const (
+	otlpSpanKindInternal      = 1
+	otlpSpanKindClient        = 3
+	otlpStatusOK              = 1
+	otlpStatusError           = 2
+	otlpTemporalityCumulative = 2
+)
+
+// Protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// protoTag appends a field tag
+func protoTag(b []byte, field, wireType int) []byte {
+	return protoVarintValue(b, uint64(field)<<3|uint64(wireType))
+}
+
+// protoVarintValue appends v as a base-128 varint
+func protoVarintValue(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func protoVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return protoVarintValue(protoTag(b, field, protoVarint), v)
+}
+
+func protoFixed64Field(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protoTag(b, field, protoFixed64)
+	for i := 0; i < 8; i++ {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
+
+func protoBytesField(b []byte, field int, data []byte) []byte {
+	if len(data) == 0 {
+		return b
+	}
+	b = protoVarintValue(protoTag(b, field, protoBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+func protoStringField(b []byte, field int, s string) []byte {
+	return protoBytesField(b, field, []byte(s))
+}
+
+// protoMessageField appends an embedded message, which is written even when empty
+func protoMessageField(b []byte, field int, encode func([]byte) []byte) []byte {
+	data := encode(nil)
+	b = protoVarintValue(protoTag(b, field, protoBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+// otlpID is a trace or span ID; OTLP/JSON encodes it as hex rather than base64
+type otlpID []byte
+
+func (id otlpID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(id))
+}
+
+// newOTLPID returns a random trace (16 byte) or span (8 byte) ID
+func newOTLPID(size int) otlpID {
+	id := make([]byte, size)
+	rand.Read(id)
+	return id
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *int64   `json:"intValue,omitempty,string"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (v otlpAnyValue) appendProto(b []byte) []byte {
+	switch {
+	case v.StringValue != nil:
+		b = protoVarintValue(protoTag(b, 1, protoBytes), uint64(len(*v.StringValue)))
+		b = append(b, *v.StringValue...)
+	case v.BoolValue != nil:
+		b = protoTag(b, 2, protoVarint)
+		if *v.BoolValue {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	case v.IntValue != nil:
+		b = protoVarintValue(protoTag(b, 3, protoVarint), uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		b = protoTag(b, 4, protoFixed64)
+		bits := math.Float64bits(*v.DoubleValue)
+		for i := 0; i < 8; i++ {
+			b = append(b, byte(bits>>(8*i)))
+		}
+	}
+	return b
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+func (kv otlpKeyValue) appendProto(b []byte) []byte {
+	b = protoStringField(b, 1, kv.Key)
+	return protoMessageField(b, 2, kv.Value.appendProto)
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpBool(key string, value bool) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &value}}
+}
+
+func otlpDouble(key string, value float64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &value}}
+}
+
+func appendOTLPAttributes(b []byte, field int, attributes []otlpKeyValue) []byte {
+	for _, kv := range attributes {
+		b = protoMessageField(b, field, kv.appendProto)
+	}
+	return b
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+func (r otlpResource) appendProto(b []byte) []byte {
+	return appendOTLPAttributes(b, 1, r.Attributes)
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+func (s otlpScope) appendProto(b []byte) []byte {
+	b = protoStringField(b, 1, s.Name)
+	return protoStringField(b, 2, s.Version)
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code"`
+}
+
+func (s otlpStatus) appendProto(b []byte) []byte {
+	b = protoStringField(b, 2, s.Message)
+	return protoVarintField(b, 3, uint64(s.Code))
+}
+
+type otlpSpan struct {
+	TraceID           otlpID         `json:"traceId"`
+	SpanID            otlpID         `json:"spanId"`
+	ParentSpanID      otlpID         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano   uint64         `json:"endTimeUnixNano,string"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+func (s otlpSpan) appendProto(b []byte) []byte {
+	b = protoBytesField(b, 1, s.TraceID)
+	b = protoBytesField(b, 2, s.SpanID)
+	b = protoBytesField(b, 4, s.ParentSpanID)
+	b = protoStringField(b, 5, s.Name)
+	b = protoVarintField(b, 6, uint64(s.Kind))
+	b = protoFixed64Field(b, 7, s.StartTimeUnixNano)
+	b = protoFixed64Field(b, 8, s.EndTimeUnixNano)
+	b = appendOTLPAttributes(b, 9, s.Attributes)
+	return protoMessageField(b, 15, s.Status.appendProto)
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+// otlpTraceRequest is an ExportTraceServiceRequest
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func (r otlpTraceRequest) appendProto(b []byte) []byte {
+	for _, rs := range r.ResourceSpans {
+		b = protoMessageField(b, 1, func(b []byte) []byte {
+			b = protoMessageField(b, 1, rs.Resource.appendProto)
+			for _, ss := range rs.ScopeSpans {
+				b = protoMessageField(b, 2, func(b []byte) []byte {
+					b = protoMessageField(b, 1, ss.Scope.appendProto)
+					for _, span := range ss.Spans {
+						b = protoMessageField(b, 2, span.appendProto)
+					}
+					return b
+				})
+			}
+			return b
+		})
+	}
+	return b
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,omitempty,string"`
+	TimeUnixNano      uint64         `json:"timeUnixNano,string"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+func (p otlpNumberDataPoint) appendProto(b []byte) []byte {
+	b = protoFixed64Field(b, 2, p.StartTimeUnixNano)
+	b = protoFixed64Field(b, 3, p.TimeUnixNano)
+	// as_double is part of a oneof, so it is written even when zero
+	b = protoTag(b, 4, protoFixed64)
+	bits := math.Float64bits(p.AsDouble)
+	for i := 0; i < 8; i++ {
+		b = append(b, byte(bits>>(8*i)))
+	}
+	return appendOTLPAttributes(b, 7, p.Attributes)
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+}
+
+func (m otlpMetric) appendProto(b []byte) []byte {
+	b = protoStringField(b, 1, m.Name)
+	b = protoStringField(b, 2, m.Description)
+	b = protoStringField(b, 3, m.Unit)
+	if m.Gauge != nil {
+		b = protoMessageField(b, 5, func(b []byte) []byte {
+			for _, point := range m.Gauge.DataPoints {
+				b = protoMessageField(b, 1, point.appendProto)
+			}
+			return b
+		})
+	}
+	if m.Sum != nil {
+		b = protoMessageField(b, 7, func(b []byte) []byte {
+			for _, point := range m.Sum.DataPoints {
+				b = protoMessageField(b, 1, point.appendProto)
+			}
+			b = protoVarintField(b, 2, uint64(m.Sum.AggregationTemporality))
+			if m.Sum.IsMonotonic {
+				b = protoVarintField(b, 3, 1)
+			}
+			return b
+		})
+	}
+	return b
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+// otlpMetricsRequest is an ExportMetricsServiceRequest
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func (r otlpMetricsRequest) appendProto(b []byte) []byte {
+	for _, rm := range r.ResourceMetrics {
+		b = protoMessageField(b, 1, func(b []byte) []byte {
+			b = protoMessageField(b, 1, rm.Resource.appendProto)
+			for _, sm := range rm.ScopeMetrics {
+				b = protoMessageField(b, 2, func(b []byte) []byte {
+					b = protoMessageField(b, 1, sm.Scope.appendProto)
+					for _, metric := range sm.Metrics {
+						b = protoMessageField(b, 2, metric.appendProto)
+					}
+					return b
+				})
+			}
+			return b
+		})
+	}
+	return b
+}
+
+// OTLPExporter sends probe metrics and cycle traces to an OpenTelemetry Collector
+type OTLPExporter struct {
+	config    OTLPConfig
+	client    *http.Client
+	resource  otlpResource
+	scope     otlpScope
+	startTime time.Time
+	errors    map[string]float64 // cumulative failed checks by service and category
+	mu        sync.Mutex
+}
+
+// NewOTLPExporter validates the configuration and prepares the exporter
+func NewOTLPExporter(config OTLPConfig) (*OTLPExporter, error) {
+	if config.Endpoint == "" {
+		return nil, nil
+	}
+	if config.Protocol == "" {
+		config.Protocol = OTLPProtocolProtobuf
+	}
+	if config.Protocol != OTLPProtocolProtobuf && config.Protocol != OTLPProtocolJSON {
+		return nil, fmt.Errorf("unknown OTLP protocol %q", config.Protocol)
+	}
+	if config.Compression != "" && config.Compression != "gzip" {
+		return nil, fmt.Errorf("unknown OTLP compression %q", config.Compression)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = Duration(10 * time.Second)
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "cloud-latency-monitor"
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+
+	attributes := []otlpKeyValue{otlpString("service.name", config.ServiceName)}
+	if hostname, err := os.Hostname(); err == nil {
+		attributes = append(attributes, otlpString("host.name", hostname))
+	}
+
+	return &OTLPExporter{
+		config:    config,
+		client:    &http.Client{Timeout: time.Duration(config.Timeout)},
+		resource:  otlpResource{Attributes: attributes},
+		scope:     otlpScope{Name: "cloud-latency-monitor"},
+		startTime: time.Now(),
+		errors:    make(map[string]float64),
+	}, nil
+}
+
+// endpointAttributes describes an endpoint test with the CloudEndpoint fields
+func endpointAttributes(endpoint CloudEndpoint, testType TestType, target string) []otlpKeyValue {
+	attributes := []otlpKeyValue{
+		otlpString("endpoint.location", endpoint.Location),
+		otlpString("endpoint.region", endpoint.Region),
+		otlpString("endpoint.provider", endpoint.Provider),
+		otlpString("endpoint.hostname", endpoint.Hostname),
+		otlpString("test.type", string(testType)),
+	}
+	if target != "" {
+		attributes = append(attributes, otlpString("test.target", target))
+	}
+
+	keys := make([]string, 0, len(endpoint.Labels))
+	for key := range endpoint.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attributes = append(attributes, otlpString("endpoint.label."+key, endpoint.Labels[key]))
+	}
+	return attributes
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// buildTrace turns a cycle into one trace: a root span for the cycle, a span
+// per test and a child span per DNS, ICMP, connect, TLS and TTFB phase
+func (e *OTLPExporter) buildTrace(start, end time.Time, cycle string, results []TestResult) otlpTraceRequest {
+	traceID := newOTLPID(16)
+	root := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            newOTLPID(8),
+		Name:              "health_check_cycle",
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes: []otlpKeyValue{
+			otlpString("cycle.classification", cycle),
+			otlpInt("cycle.tests", int64(len(results))),
+		},
+		Status: otlpStatus{Code: otlpStatusOK},
+	}
+
+	spans := []otlpSpan{root}
+	failed := 0
+	for _, result := range results {
+		attributes := endpointAttributes(result.Endpoint, result.TestType, result.Target)
+		attributes = append(attributes,
+			otlpBool("test.online", result.Online),
+			otlpBool("test.degraded", result.Degraded),
+			otlpDouble("test.response_time_ms", float64(result.ResponseTime)/float64(time.Millisecond)))
+		if result.ResolvedIP != "" {
+			attributes = append(attributes, otlpString("net.peer.ip", result.ResolvedIP))
+		}
+		if result.StatusCode != 0 {
+			attributes = append(attributes, otlpInt("http.status_code", int64(result.StatusCode)))
+		}
+		if result.TestType == TestTypePing {
+			attributes = append(attributes, otlpDouble("test.packet_loss", result.Loss))
+		}
+		if result.Trend != "" {
+			attributes = append(attributes, otlpString("test.trend", result.Trend))
+		}
+		if result.Maintenance != "" {
+			attributes = append(attributes, otlpString("test.maintenance", result.Maintenance))
+		}
+		for _, name := range sortedFieldNames(result.Fields) {
+			attributes = append(attributes, otlpDouble("probe."+name, result.Fields[name]))
+		}
+
+		status := otlpStatus{Code: otlpStatusOK}
+		if !result.Online {
+			failed++
+			status = otlpStatus{Code: otlpStatusError, Message: result.Error}
+			attributes = append(attributes, otlpString("error.category", errorCategory(result)))
+		}
+
+		span := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            newOTLPID(8),
+			ParentSpanID:      root.SpanID,
+			Name:              "probe " + strings.ToLower(string(result.TestType)),
+			Kind:              otlpSpanKindClient,
+			StartTimeUnixNano: unixNano(result.Timestamp),
+			EndTimeUnixNano:   unixNano(result.Timestamp.Add(result.Elapsed)),
+			Attributes:        attributes,
+			Status:            status,
+		}
+		spans = append(spans, span)
+
+		for _, phase := range result.Phases {
+			phaseStatus := otlpStatus{Code: otlpStatusOK}
+			if phase.Error != "" {
+				phaseStatus = otlpStatus{Code: otlpStatusError, Message: phase.Error}
+			}
+			spans = append(spans, otlpSpan{
+				TraceID:           traceID,
+				SpanID:            newOTLPID(8),
+				ParentSpanID:      span.SpanID,
+				Name:              phase.Name,
+				Kind:              otlpSpanKindInternal,
+				StartTimeUnixNano: unixNano(phase.Start),
+				EndTimeUnixNano:   unixNano(phase.Start.Add(phase.Duration)),
+				Attributes:        []otlpKeyValue{otlpString("endpoint.hostname", result.Endpoint.Hostname)},
+				Status:            phaseStatus,
+			})
+		}
+	}
+	spans[0].Attributes = append(spans[0].Attributes, otlpInt("cycle.failed", int64(failed)))
+
+	return otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: e.scope, Spans: spans}},
+	}}}
+}
+
+// buildMetrics reports the cycle as gauges plus a cumulative error counter
+func (e *OTLPExporter) buildMetrics(end time.Time, duration time.Duration, results []TestResult) otlpMetricsRequest {
+	now := unixNano(end)
+	responseTime := &otlpGauge{}
+	up := &otlpGauge{}
+	loss := &otlpGauge{}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	errorAttributes := make(map[string][]otlpKeyValue)
+	for _, result := range results {
+		attributes := endpointAttributes(result.Endpoint, result.TestType, result.Target)
+
+		value := 0.0
+		if result.Online {
+			value = 1
+			responseTime.DataPoints = append(responseTime.DataPoints, otlpNumberDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: unixNano(result.Timestamp),
+				AsDouble:     result.ResponseTime.Seconds(),
+			})
+		} else {
+			category := errorCategory(result)
+			key := serviceKeyFor(result.Endpoint, result.TestType, result.Target) + "|" + category
+			e.errors[key]++
+			errorAttributes[key] = append(attributes, otlpString("error.category", category))
+		}
+		up.DataPoints = append(up.DataPoints, otlpNumberDataPoint{
+			Attributes:   attributes,
+			TimeUnixNano: unixNano(result.Timestamp),
+			AsDouble:     value,
+		})
+
+		if result.TestType == TestTypePing {
+			loss.DataPoints = append(loss.DataPoints, otlpNumberDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: unixNano(result.Timestamp),
+				AsDouble:     result.Loss / 100,
+			})
+		}
+	}
+
+	// Only series that have failed at least once are reported
+	errorCounts := &otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+	keys := make([]string, 0, len(errorAttributes))
+	for key := range errorAttributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		errorCounts.DataPoints = append(errorCounts.DataPoints, otlpNumberDataPoint{
+			Attributes:        errorAttributes[key],
+			StartTimeUnixNano: unixNano(e.startTime),
+			TimeUnixNano:      now,
+			AsDouble:          e.errors[key],
+		})
+	}
+
+	metrics := []otlpMetric{
+		{Name: "cloud_latency.response_time", Description: "Response time of successful checks", Unit: "s", Gauge: responseTime},
+		{Name: "cloud_latency.up", Description: "Whether the check succeeded (1) or failed (0)", Unit: "1", Gauge: up},
+		{Name: "cloud_latency.packet_loss", Description: "ICMP packet loss ratio", Unit: "1", Gauge: loss},
+		{Name: "cloud_latency.cycle.duration", Description: "Duration of the check cycle", Unit: "s",
+			Gauge: &otlpGauge{DataPoints: []otlpNumberDataPoint{{TimeUnixNano: now, AsDouble: duration.Seconds()}}}},
+	}
+	if len(errorCounts.DataPoints) > 0 {
+		metrics = append(metrics, otlpMetric{Name: "cloud_latency.check.errors",
+			Description: "Failed checks by error category", Unit: "1", Sum: errorCounts})
+	}
+
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     e.resource,
+		ScopeMetrics: []otlpScopeMetrics{{Scope: e.scope, Metrics: metrics}},
+	}}}
+}
+
+// post sends one export request in the configured protocol
+func (e *OTLPExporter) post(path string, message interface {
+	appendProto([]byte) []byte
+}) error {
+	var body []byte
+	contentType := "application/x-protobuf"
+	if e.config.Protocol == OTLPProtocolJSON {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		body = data
+		contentType = "application/json"
+	} else {
+		body = message.appendProto(nil)
+	}
+
+	var reader io.Reader = bytes.NewReader(body)
+	if e.config.Compression == "gzip" {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		reader = &buf
+	}
+
+	req, err := http.NewRequest("POST", e.config.Endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if e.config.Compression == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return nil
+}
+
+// Export sends the cycle's metrics and trace
+func (e *OTLPExporter) Export(start, end time.Time, cycle string, results []TestResult) error {
+	if e == nil {
+		return nil
+	}
+
+	var errs []string
+	if !e.config.DisableMetrics {
+		if err := e.post("/v1/metrics", e.buildMetrics(end, end.Sub(start), results)); err != nil {
+			errs = append(errs, "metrics: "+err.Error())
+		}
+	}
+	if !e.config.DisableTraces {
+		if err := e.post("/v1/traces", e.buildTrace(start, end, cycle, results)); err != nil {
+			errs = append(errs, "traces: "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("OTLP export failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// InfluxConfig controls the InfluxDB line protocol output
+type InfluxConfig struct {
+	URL             string            // e.g. http://localhost:8086; empty disables
+	Version         int               // write API: 1 or 2 (default 2 when Bucket is set, else 1)
+	Database        string            // v1 database
+	RetentionPolicy string            // v1 retention policy
+	Username        string            // v1 credentials
+	Password        string            //
+	Org             string            // v2 organization
+	Bucket          string            // v2 bucket
+	Token           string            // v2 API token
+	Measurement     string            // default cloud_latency; "{test_type}" is replaced by ping, dns or http
+	Tags            map[string]string // extra tags added to every point
+	Output          OutputConfig
+}
+
+// GraphiteConfig controls the Graphite plaintext output
+type GraphiteConfig struct {
+	Address string // carbon plaintext listener, e.g. localhost:2003; empty disables
+	Prefix  string // default cloud_latency
+	Tagged  bool   // use Graphite 1.1 tags instead of a dotted path
+	Output  OutputConfig
+}
+
+// OutputConfig controls batching, retry and disk spooling for a push output
+type OutputConfig struct {
+	BatchSize     int      // lines per request, default 500
+	Retries       int      // attempts per batch before spooling, default 3
+	RetryDelay    Duration // first backoff, doubled per attempt, default 1s
+	Timeout       Duration // per request, default 10s
+	SpoolDir      string   // default "spool"
+	MaxSpoolBytes int64    // oldest lines are dropped beyond this, default 50MB
+}
+
+// withDefaults fills in unset OutputConfig fields
+func (c OutputConfig) withDefaults() OutputConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.Retries <= 0 {
+		c.Retries = 3
+	}
+	if c.RetryDelay == 0 {
+		c.RetryDelay = Duration(time.Second)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = Duration(10 * time.Second)
+	}
+	if c.SpoolDir == "" {
+		c.SpoolDir = "spool"
+	}
+	if c.MaxSpoolBytes <= 0 {
+		c.MaxSpoolBytes = 50 << 20
+	}
+	return c
+}
+
+// rejectedError marks a batch the receiver refused as invalid; retrying or
+// spooling it would only block everything queued behind it
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string { return e.err.Error() }
+
+// SpoolingWriter delivers text lines in batches with retry. Lines that can't
+// be delivered are appended to a spool file and replayed, oldest first, on
+// the next write, so nothing is lost while the receiver is down.
+type SpoolingWriter struct {
+	name      string
+	config    OutputConfig
+	spoolFile string
+	send      func(lines []string) error
+	mu        sync.Mutex
+}
+
+// NewSpoolingWriter creates a writer that spools to <SpoolDir>/<name>.lines
+func NewSpoolingWriter(name string, config OutputConfig, send func(lines []string) error) (*SpoolingWriter, error) {
+	config = config.withDefaults()
+	if err := os.MkdirAll(config.SpoolDir, 0755); err != nil {
+		return nil, err
+	}
+	return &SpoolingWriter{
+		name:      name,
+		config:    config,
+		spoolFile: filepath.Join(config.SpoolDir, name+".lines"),
+		send:      send,
+	}, nil
+}
+
+// readSpool loads lines left over from earlier failed deliveries
+func (sw *SpoolingWriter) readSpool() ([]string, error) {
+	data, err := ioutil.ReadFile(sw.spoolFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// writeSpool replaces the spool with the undelivered lines, keeping the newest
+// lines when the spool would exceed MaxSpoolBytes
+func (sw *SpoolingWriter) writeSpool(lines []string) error {
+	if len(lines) == 0 {
+		err := os.Remove(sw.spoolFile)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	size := int64(0)
+	first := len(lines)
+	for first > 0 && size+int64(len(lines[first-1])+1) <= sw.config.MaxSpoolBytes {
+		first--
+		size += int64(len(lines[first]) + 1)
+	}
+	if first > 0 {
+		fmt.Printf("%sWarning: %s spool full, dropped %d oldest lines%s\n", ColorYellow, sw.name, first, ColorReset)
+	}
+
+	tmp := sw.spoolFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(lines[first:], "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sw.spoolFile)
+}
+
+// sendWithRetry tries a batch with exponential backoff
+func (sw *SpoolingWriter) sendWithRetry(batch []string) error {
+	delay := time.Duration(sw.config.RetryDelay)
+	var err error
+	for attempt := 1; attempt <= sw.config.Retries; attempt++ {
+		if err = sw.send(batch); err == nil {
+			return nil
+		}
+		if _, rejected := err.(*rejectedError); rejected {
+			return err
+		}
+		if attempt < sw.config.Retries {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+// Write delivers any spooled lines followed by the new ones
+func (sw *SpoolingWriter) Write(lines []string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	spooled, err := sw.readSpool()
+	if err != nil {
+		return fmt.Errorf("reading spool: %v", err)
+	}
+	pending := append(spooled, lines...)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var sendErr error
+	for len(pending) > 0 {
+		size := sw.config.BatchSize
+		if size > len(pending) {
+			size = len(pending)
+		}
+		if err := sw.sendWithRetry(pending[:size]); err != nil {
+			if _, rejected := err.(*rejectedError); rejected {
+				fmt.Printf("%sWarning: %s rejected %d lines: %v%s\n", ColorYellow, sw.name, size, err, ColorReset)
+				pending = pending[size:]
+				continue
+			}
+			sendErr = err
+			break
+		}
+		pending = pending[size:]
+	}
+
+	if err := sw.writeSpool(pending); err != nil {
+		return fmt.Errorf("writing spool: %v", err)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("%d lines spooled: %v", len(pending), sendErr)
+	}
+	return nil
+}
+
+// Spooled reports how many lines are waiting in the spool
+func (sw *SpoolingWriter) Spooled() int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	lines, _ := sw.readSpool()
+	return len(lines)
+}
+
+// LineOutput pushes results to a time-series database as text lines
+type LineOutput struct {
+	name   string
+	format func(results []TestResult) []string
+	writer *SpoolingWriter
+}
+
+// Name identifies the output in sink health reports
+func (o *LineOutput) Name() string { return o.name }
+
+// sortedFieldNames returns the keys of a result's Fields in a stable order
+func sortedFieldNames(fields map[string]float64) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// escapeInflux escapes the given characters in a measurement, tag key or tag value
+func escapeInflux(s, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\n' {
+			b.WriteString(" ")
+			continue
+		}
+		if strings.ContainsRune(chars, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// influxFieldString quotes a string field value
+func influxFieldString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", " ") + `"`
+}
+
+// formatInfluxLines renders results as InfluxDB line protocol with
+// nanosecond timestamps, tagging each point with the CloudEndpoint fields
+func formatInfluxLines(config InfluxConfig, results []TestResult) []string {
+	measurement := config.Measurement
+	if measurement == "" {
+		measurement = "cloud_latency"
+	}
+
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		name := strings.ReplaceAll(measurement, "{test_type}", strings.ToLower(string(result.TestType)))
+
+		tags := map[string]string{
+			"location":  result.Endpoint.Location,
+			"region":    result.Endpoint.Region,
+			"provider":  result.Endpoint.Provider,
+			"hostname":  result.Endpoint.Hostname,
+			"test_type": string(result.TestType),
+		}
+		if result.Target != "" {
+			tags["target"] = result.Target
+		}
+		for key, value := range result.Endpoint.Labels {
+			tags["label_"+key] = value
+		}
+		for key, value := range config.Tags {
+			tags[key] = value
+		}
+
+		// Influx wants tags sorted by key
+		keys := make([]string, 0, len(tags))
+		for key, value := range tags {
+			if value != "" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		var line strings.Builder
+		line.WriteString(escapeInflux(name, ", "))
+		for _, key := range keys {
+			line.WriteString("," + escapeInflux(key, ",= ") + "=" + escapeInflux(tags[key], ",= "))
+		}
+
+		fields := []string{fmt.Sprintf("online=%t", result.Online)}
+		if result.Online {
+			fields = append(fields, "response_ms="+strconv.FormatFloat(float64(result.ResponseTime)/float64(time.Millisecond), 'f', -1, 64))
+		}
+		if result.Degraded {
+			fields = append(fields, "degraded=true")
+		}
+		if result.TestType == TestTypePing {
+			fields = append(fields, "loss="+strconv.FormatFloat(result.Loss, 'f', -1, 64))
+		}
+		for _, name := range sortedFieldNames(result.Fields) {
+			fields = append(fields, escapeInflux(name, ",= ")+"="+strconv.FormatFloat(result.Fields[name], 'f', -1, 64))
+		}
+		if result.StatusCode != 0 {
+			fields = append(fields, fmt.Sprintf("status_code=%di", result.StatusCode))
+		}
+		if result.Trend != "" {
+			fields = append(fields, "trend="+influxFieldString(result.Trend))
+		}
+		if result.ResolvedIP != "" {
+			fields = append(fields, "resolved_ip="+influxFieldString(result.ResolvedIP))
+		}
+		if result.Error != "" {
+			fields = append(fields, "error="+influxFieldString(result.Error),
+				"error_category="+influxFieldString(errorCategory(result)))
+		}
+		if result.Maintenance != "" {
+			fields = append(fields, "maintenance="+influxFieldString(result.Maintenance))
+		}
+		if result.LocalOutage {
+			fields = append(fields, "local_outage=true")
+		}
+
+		line.WriteString(" " + strings.Join(fields, ",") + " " + strconv.FormatInt(result.Timestamp.UnixNano(), 10))
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// NewInfluxOutput creates an output for the v1 (/write) or v2 (/api/v2/write) API
+func NewInfluxOutput(config InfluxConfig) (*LineOutput, error) {
+	if config.Version == 0 {
+		config.Version = 1
+		if config.Bucket != "" {
+			config.Version = 2
+		}
+	}
+
+	base := strings.TrimRight(config.URL, "/")
+	query := url.Values{"precision": {"ns"}}
+	var writeURL string
+	switch config.Version {
+	case 1:
+		if config.Database == "" {
+			return nil, fmt.Errorf("influx v1 output needs Database")
+		}
+		query.Set("db", config.Database)
+		if config.RetentionPolicy != "" {
+			query.Set("rp", config.RetentionPolicy)
+		}
+		writeURL = base + "/write?" + query.Encode()
+	case 2:
+		if config.Org == "" || config.Bucket == "" {
+			return nil, fmt.Errorf("influx v2 output needs Org and Bucket")
+		}
+		query.Set("org", config.Org)
+		query.Set("bucket", config.Bucket)
+		writeURL = base + "/api/v2/write?" + query.Encode()
+	default:
+		return nil, fmt.Errorf("unknown influx version %d", config.Version)
+	}
+
+	client := &http.Client{Timeout: time.Duration(config.Output.withDefaults().Timeout)}
+	send := func(lines []string) error {
+		req, err := http.NewRequest("POST", writeURL, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if config.Version == 2 {
+			req.Header.Set("Authorization", "Token "+config.Token)
+		} else if config.Username != "" {
+			req.SetBasicAuth(config.Username, config.Password)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		err = fmt.Errorf("influx returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		// Malformed data is rejected for good; auth, rate limit and server errors are retried
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge {
+			return &rejectedError{err}
+		}
+		return err
+	}
+
+	writer, err := NewSpoolingWriter("influx", config.Output, send)
+	if err != nil {
+		return nil, err
+	}
+	return &LineOutput{
+		name:   "influx",
+		format: func(results []TestResult) []string { return formatInfluxLines(config, results) },
+		writer: writer,
+	}, nil
+}
+
+// graphiteName makes a value safe for one component of a dotted Graphite path
+func graphiteName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// graphiteTagValue makes a value safe for a Graphite 1.1 tag
+func graphiteTagValue(s string) string {
+	return strings.NewReplacer(";", "_", "~", "_", " ", "_", "!", "_", "^", "_").Replace(s)
+}
+
+// formatGraphiteLines renders results as Graphite plaintext, either as
+// prefix.provider.region.test_type.metric paths or as tagged series
+func formatGraphiteLines(config GraphiteConfig, results []TestResult) []string {
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "cloud_latency"
+	}
+
+	var lines []string
+	for _, result := range results {
+		timestamp := result.Timestamp.Unix()
+		name := func(metric string) string {
+			if config.Tagged {
+				tags := []string{
+					"location=" + graphiteTagValue(result.Endpoint.Location),
+					"region=" + graphiteTagValue(result.Endpoint.Region),
+					"provider=" + graphiteTagValue(result.Endpoint.Provider),
+					"hostname=" + graphiteTagValue(result.Endpoint.Hostname),
+					"test_type=" + strings.ToLower(string(result.TestType)),
+				}
+				if result.Target != "" {
+					tags = append(tags, "target="+graphiteTagValue(result.Target))
+				}
+				for key, value := range result.Endpoint.Labels {
+					tags = append(tags, graphiteTagValue(key)+"="+graphiteTagValue(value))
+				}
+				sort.Strings(tags)
+				return prefix + "." + metric + ";" + strings.Join(tags, ";")
+			}
+			testType := strings.ToLower(string(result.TestType))
+			if result.Target != "" {
+				testType += "_" + graphiteName(result.Target)
+			}
+			return strings.Join([]string{prefix, graphiteName(result.Endpoint.Provider), graphiteName(result.Endpoint.Region),
+				testType, metric}, ".")
+		}
+
+		up := 0
+		if result.Online {
+			up = 1
+			lines = append(lines, fmt.Sprintf("%s %s %d", name("response_ms"),
+				strconv.FormatFloat(float64(result.ResponseTime)/float64(time.Millisecond), 'f', 3, 64), timestamp))
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", name("up"), up, timestamp))
+		if result.Degraded {
+			lines = append(lines, fmt.Sprintf("%s 1 %d", name("degraded"), timestamp))
+		}
+		if result.TestType == TestTypePing {
+			lines = append(lines, fmt.Sprintf("%s %s %d", name("loss"), strconv.FormatFloat(result.Loss, 'f', -1, 64), timestamp))
+		}
+		for _, field := range sortedFieldNames(result.Fields) {
+			lines = append(lines, fmt.Sprintf("%s %s %d", name(graphiteName(field)),
+				strconv.FormatFloat(result.Fields[field], 'f', -1, 64), timestamp))
+		}
+	}
+	return lines
+}
+
+// NewGraphiteOutput creates an output for a carbon plaintext listener
+func NewGraphiteOutput(config GraphiteConfig) (*LineOutput, error) {
+	timeout := time.Duration(config.Output.withDefaults().Timeout)
+	send := func(lines []string) error {
+		conn, err := net.DialTimeout("tcp", config.Address, timeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(timeout))
+		if _, err := io.WriteString(conn, strings.Join(lines, "\n")+"\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	writer, err := NewSpoolingWriter("graphite", config.Output, send)
+	if err != nil {
+		return nil, err
+	}
+	return &LineOutput{
+		name:   "graphite",
+		format: func(results []TestResult) []string { return formatGraphiteLines(config, results) },
+		writer: writer,
+	}, nil
+}
+
+// CycleReport is everything the sinks receive about one completed check cycle
+type CycleReport struct {
+	Start     time.Time
+	End       time.Time // when the last test finished
+	Cycle     string    // CycleNormal or CycleLocalOutage
+	Local     LocalStatus
+	Results   []TestResult
+	Diagnoses []EndpointDiagnosis
+	SLOs      []SLOStatus
+	Sinks     []SinkHealth // state of every sink when the cycle was published
+}
+
+// ResultSink consumes the results of each check cycle. Console output, the
+// log file, history, alerting and every exporter are sinks, so adding an
+// output never means touching the probe code.
+type ResultSink interface {
+	Name() string
+	WriteCycle(report *CycleReport) error
+}
+
+// Sink overflow policies, applied when a sink's buffer is full
+const (
+	SinkOverflowBlock      = "block"       // wait for the sink, delaying the next cycle
+	SinkOverflowDropOldest = "drop_oldest" // discard the oldest queued cycle
+)
+
+// SinkOptions controls buffering for one sink
+type SinkOptions struct {
+	Buffer   int    // cycles queued before the overflow policy applies, default 10
+	Overflow string // block (default) or drop_oldest
+	Disabled bool
+}
+
+// SinkHealth reports how a sink is keeping up
+type SinkHealth struct {
+	Name                string
+	Healthy             bool
+	Queued              int
+	Capacity            int
+	Delivered           uint64
+	Failed              uint64
+	Dropped             uint64
+	ConsecutiveFailures int
+	LastError           string `json:",omitempty"`
+	LastErrorAt         time.Time
+	LastSuccess         time.Time
+	LastDuration        Duration
+}
+
+// sinkWorker feeds one sink from its own queue so a slow sink can't hold up the others
+type sinkWorker struct {
+	sink    ResultSink
+	options SinkOptions
+	queue   chan *CycleReport
+	done    chan struct{}
+	health  SinkHealth
+	mu      sync.Mutex
+}
+
+func (w *sinkWorker) run() {
+	defer close(w.done)
+	for report := range w.queue {
+		start := time.Now()
+		err := w.sink.WriteCycle(report)
+
+		w.mu.Lock()
+		w.health.LastDuration = Duration(time.Since(start))
+		if err != nil {
+			w.health.Failed++
+			w.health.ConsecutiveFailures++
+			w.health.LastError = err.Error()
+			w.health.LastErrorAt = time.Now()
+		} else {
+			w.health.Delivered++
+			w.health.ConsecutiveFailures = 0
+			w.health.LastSuccess = time.Now()
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			fmt.Printf("%sWarning: %s sink: %v%s\n", ColorYellow, w.sink.Name(), err, ColorReset)
+		}
+	}
+}
+
+// enqueue hands a report to the worker, applying the overflow policy
+func (w *sinkWorker) enqueue(report *CycleReport) {
+	if w.options.Overflow != SinkOverflowDropOldest {
+		w.queue <- report
+		return
+	}
+
+	for {
+		select {
+		case w.queue <- report:
+			return
+		default:
+		}
+		select {
+		case <-w.queue:
+			w.mu.Lock()
+			w.health.Dropped++
+			w.mu.Unlock()
+		default:
+		}
+	}
+}
+
+func (w *sinkWorker) snapshot() SinkHealth {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	health := w.health
+	health.Queued = len(w.queue)
+	health.Capacity = cap(w.queue)
+	health.Healthy = health.ConsecutiveFailures == 0 && health.Queued < health.Capacity
+	return health
+}
+
+// SinkPipeline fans each cycle out to every registered sink
+type SinkPipeline struct {
+	workers []*sinkWorker
+	options map[string]SinkOptions
+}
+
+// NewSinkPipeline creates a pipeline; options are keyed by sink name
+func NewSinkPipeline(options map[string]SinkOptions) *SinkPipeline {
+	return &SinkPipeline{options: options}
+}
+
+// Add registers a sink and starts its worker
+func (p *SinkPipeline) Add(sink ResultSink) {
+	options := p.options[sink.Name()]
+	if options.Disabled {
+		return
+	}
+	if options.Buffer <= 0 {
+		options.Buffer = 10
+	}
+	if options.Overflow == "" {
+		options.Overflow = SinkOverflowBlock
+	}
+
+	worker := &sinkWorker{
+		sink:    sink,
+		options: options,
+		queue:   make(chan *CycleReport, options.Buffer),
+		done:    make(chan struct{}),
+		health:  SinkHealth{Name: sink.Name()},
+	}
+	p.workers = append(p.workers, worker)
+	go worker.run()
+}
+
+// Publish sends a cycle to every sink
+func (p *SinkPipeline) Publish(report *CycleReport) {
+	report.Sinks = p.Health()
+	for _, worker := range p.workers {
+		worker.enqueue(report)
+	}
+}
+
+// Health reports the state of every sink
+func (p *SinkPipeline) Health() []SinkHealth {
+	health := make([]SinkHealth, 0, len(p.workers))
+	for _, worker := range p.workers {
+		health = append(health, worker.snapshot())
+	}
+	return health
+}
+
+// Close stops accepting cycles and waits for every sink to drain its queue
+func (p *SinkPipeline) Close() {
+	for _, worker := range p.workers {
+		close(worker.queue)
+	}
+	for _, worker := range p.workers {
+		<-worker.done
+	}
+}
+
+// resultGroup is the results of one test type, shown as one console section
+type resultGroup struct {
+	TestType TestType
+	Title    string
+	Results  []TestResult
+}
+
+// groupByTestType splits results into one section per test type, in prober
+// registration order (PING, DNS, HTTP, then any others)
+func groupByTestType(results []TestResult) []resultGroup {
+	byType := make(map[TestType][]TestResult)
+	for _, result := range results {
+		byType[result.TestType] = append(byType[result.TestType], result)
+	}
+
+	var groups []resultGroup
+	for _, testType := range proberOrder {
+		if len(byType[testType]) == 0 {
+			continue
+		}
+		groups = append(groups, resultGroup{TestType: testType, Title: probers[testType].Title(), Results: byType[testType]})
+		delete(byType, testType)
+	}
+	var others []TestType
+	for testType := range byType {
+		others = append(others, testType)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i] < others[j] })
+	for _, testType := range others {
+		groups = append(groups, resultGroup{TestType: testType, Title: string(testType) + " TESTS", Results: byType[testType]})
+	}
+	return groups
+}
+
+// ConsoleSink prints each cycle to stdout
+type ConsoleSink struct{}
+
+func (ConsoleSink) Name() string { return "console" }
+
+func (ConsoleSink) WriteCycle(report *CycleReport) error {
+	failedTests := 0
+	for _, result := range report.Results {
+		if !result.Online {
+			failedTests++
+		}
+	}
+
+	if report.Cycle == CycleLocalOutage {
+		fmt.Printf("\n%s=== LOCAL NETWORK OUTAGE ===%s\n", ColorRed, ColorReset)
+		fmt.Printf("%s\n", report.Local)
+		fmt.Printf("%d/%d tests failed; failures are attributed to the local network, not the remote endpoints\n",
+			failedTests, len(report.Results))
+	}
+
+	totalTests := 0
+	successfulTests := 0
+	maintenanceTests := 0
+	degradedTests := 0
+	var totalResponseTime time.Duration
+
+	for _, result := range report.Results {
+		// Tests under maintenance don't count toward availability
+		if result.Maintenance != "" {
+			maintenanceTests++
+		} else {
+			totalTests++
+		}
+		if result.Degraded {
+			degradedTests++
+		}
+		if result.Online && result.Maintenance == "" {
+			successfulTests++
+			totalResponseTime += result.ResponseTime
+		}
+	}
+
+	// Print results grouped by test type, with the home network in its own section
+	var cloudResults, homeResults []TestResult
+	for _, result := range report.Results {
+		if result.Endpoint.Class == EndpointClassHome {
+			homeResults = append(homeResults, result)
+		} else {
+			cloudResults = append(cloudResults, result)
+		}
+	}
+	for _, group := range groupByTestType(cloudResults) {
+		fmt.Printf("\n%s=== %s ===%s\n", ColorMagenta, group.Title, ColorReset)
+		for _, result := range group.Results {
+			printResult(result)
+		}
+	}
+	printHomeResults(homeResults)
+
+	verdictCounts := printDiagnoses(report.Diagnoses)
+
+	// Print summary
+	elapsed := report.End.Sub(report.Start)
+	successRate := 0.0
+	if totalTests > 0 {
+		successRate = float64(successfulTests) / float64(totalTests) * 100
+	}
+	avgResponseTime := time.Duration(0)
+	if successfulTests > 0 {
+		avgResponseTime = totalResponseTime / time.Duration(successfulTests)
+	}
+
+	fmt.Printf("\n%s=== SUMMARY ===%s", ColorCyan, ColorReset)
+	fmt.Printf("\nTotal tests executed: %d", totalTests+maintenanceTests)
+	fmt.Printf("\n%sSuccess rate: %.1f%% (%d/%d)%s",
+		ColorGreen, successRate, successfulTests, totalTests, ColorReset)
+	if maintenanceTests > 0 {
+		fmt.Printf("\n%sUnder maintenance: %d tests (excluded from success rate)%s",
+			ColorBlue, maintenanceTests, ColorReset)
+	}
+	if degradedTests > 0 {
+		fmt.Printf("\n%sDegraded: %d tests (reachable, counted as successful)%s",
+			ColorYellow, degradedTests, ColorReset)
+	}
+	fmt.Printf("\nAverage response time: %dms", avgResponseTime.Milliseconds())
+	fmt.Printf("\nDiagnosis: %d healthy", verdictCounts[DiagnosisHealthy])
+	for _, verdict := range []string{DiagnosisDNS, DiagnosisNetworkPath, DiagnosisTLS, DiagnosisService, DiagnosisLocalNetwork} {
+		if verdictCounts[verdict] > 0 {
+			fmt.Printf(", %s%d %s%s", ColorRed, verdictCounts[verdict], verdict, ColorReset)
+		}
+	}
+	fmt.Printf("\nTotal execution time: %.2fs\n", elapsed.Seconds())
+	if report.Local.Checked {
+		localColor := ColorGreen
+		if !report.Local.Healthy() {
+			localColor = ColorYellow
+		}
+		fmt.Printf("%sLocal network: %s%s\n", localColor, report.Local, ColorReset)
+	}
+	printSinkHealth(report.Sinks)
+
+	printSLOStatus(report.SLOs)
+	return nil
+}
+
+// printSinkHealth summarizes the sinks, naming any that are failing or backed up
+func printSinkHealth(health []SinkHealth) {
+	var unhealthy []string
+	for _, sink := range health {
+		if sink.Healthy {
+			continue
+		}
+		detail := fmt.Sprintf("%s (%d/%d queued", sink.Name, sink.Queued, sink.Capacity)
+		if sink.ConsecutiveFailures > 0 {
+			detail += fmt.Sprintf(", %d failures: %s", sink.ConsecutiveFailures, sink.LastError)
+		}
+		unhealthy = append(unhealthy, detail+")")
+	}
+
+	if len(unhealthy) == 0 {
+		return
+	}
+	fmt.Printf("%sSinks: %d/%d healthy; %s%s\n", ColorYellow, len(health)-len(unhealthy), len(health),
+		strings.Join(unhealthy, "; "), ColorReset)
+}
+
+// LogFileSink appends each cycle to cloud_latency.log in the configured format
+type LogFileSink struct {
+	file     io.Writer
+	format   string
+	verdicts map[string]string // last logged verdict per endpoint
+}
+
+func (s *LogFileSink) Name() string { return "logfile" }
+
+// changedDiagnoses returns the verdicts that differ from the last ones logged,
+// so a steady endpoint is logged once rather than every cycle. Endpoints
+// first seen healthy are not logged at all.
+func (s *LogFileSink) changedDiagnoses(diagnoses []EndpointDiagnosis) []EndpointDiagnosis {
+	if s.verdicts == nil {
+		s.verdicts = make(map[string]string)
+	}
+
+	var changed []EndpointDiagnosis
+	for _, diagnosis := range diagnoses {
+		key := diagnosis.Endpoint.Location + "|" + diagnosis.Endpoint.Provider
+		last, seen := s.verdicts[key]
+		s.verdicts[key] = diagnosis.Verdict
+		if last == diagnosis.Verdict || (!seen && diagnosis.Verdict == DiagnosisHealthy) {
+			continue
+		}
+		changed = append(changed, diagnosis)
+	}
+	return changed
+}
+
+func (s *LogFileSink) WriteCycle(report *CycleReport) error {
+	logged := *report
+	logged.Diagnoses = s.changedDiagnoses(report.Diagnoses)
+	report = &logged
+
+	var results []TestResult
+	for _, group := range groupByTestType(report.Results) {
+		results = append(results, group.Results...)
+	}
+
+	// Build the whole cycle first so a rotation never splits it across files
+	var buf bytes.Buffer
+	switch s.format {
+	case LogFormatJSON:
+		if err := writeJSONLog(report, results, &buf); err != nil {
+			return err
+		}
+	case LogFormatLogfmt:
+		if err := writeLogfmtLog(report, results, &buf); err != nil {
+			return err
+		}
+	default:
+		if report.Cycle == CycleLocalOutage {
+			writeCycleToLog(report.Start, report.Local, countFailed(report.Results), len(report.Results), &buf)
+		}
+		for _, result := range results {
+			writeToLog(result, &buf)
+		}
+		writeDiagnosisToLog(report.Start, report.Diagnoses, &buf)
+	}
+
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+// Log formats for cloud_latency.log
+const (
+	LogFormatText   = "text"   // pipe-delimited lines
+	LogFormatJSON   = "json"   // JSON Lines, one object per result
+	LogFormatLogfmt = "logfmt" // key=value pairs
+)
+
+// LogConfig selects the log format and rotation for cloud_latency.log
+type LogConfig struct {
+	File       string   // default cloud_latency.log
+	Format     string   // text (default), json or logfmt
+	MaxSizeMB  int      // rotate when the file grows past this size; 0 disables
+	MaxAge     Duration // rotate when the oldest line is older than this, e.g. "1d"; 0 disables
+	MaxBackups int      // rotated files to keep; 0 keeps them all
+	Compress   bool     // gzip rotated files
+}
+
+// resultStatus is the status word shown on the console and in the logs
+func resultStatus(result TestResult) string {
+	switch {
+	case result.Degraded:
+		return "DEGRADED"
+	case result.Online:
+		return "UP"
+	}
+	return "DOWN"
+}
+
+// logPhase is one timed phase in a JSON log entry
+type logPhase struct {
+	Name       string    `json:"name"`
+	Start      time.Time `json:"start"`
+	DurationMs float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// logResultEntry is one test result in the JSON Lines log, with every TestResult field
+type logResultEntry struct {
+	Time          time.Time          `json:"time"`
+	Event         string             `json:"event"`
+	Status        string             `json:"status"`
+	Service       string             `json:"service"`
+	Location      string             `json:"location"`
+	Region        string             `json:"region"`
+	Provider      string             `json:"provider"`
+	Hostname      string             `json:"hostname"`
+	Labels        map[string]string  `json:"labels,omitempty"`
+	Class         string             `json:"class,omitempty"`
+	TestType      TestType           `json:"test_type"`
+	Target        string             `json:"target,omitempty"`
+	Online        bool               `json:"online"`
+	Degraded      bool               `json:"degraded,omitempty"`
+	ResponseMs    float64            `json:"response_ms"`
+	ResolvedIP    string             `json:"resolved_ip,omitempty"`
+	Error         string             `json:"error,omitempty"`
+	ErrorCategory string             `json:"error_category,omitempty"`
+	Trend         string             `json:"trend,omitempty"`
+	BaselineMs    float64            `json:"baseline_ms,omitempty"`
+	Loss          *float64           `json:"loss,omitempty"` // PING only
+	Maintenance   string             `json:"maintenance,omitempty"`
+	LocalOutage   bool               `json:"local_outage,omitempty"`
+	StatusCode    int                `json:"status_code,omitempty"`
+	Phases        []logPhase         `json:"phases,omitempty"`
+	ElapsedMs     float64            `json:"elapsed_ms"`
+	Fields        map[string]float64 `json:"fields,omitempty"`
+	Details       map[string]string  `json:"details,omitempty"`
+	Note          string             `json:"note,omitempty"`
+}
+
+// logDiagnosisEntry is one endpoint verdict in the JSON Lines log
+type logDiagnosisEntry struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Location string    `json:"location"`
+	Region   string    `json:"region"`
+	Provider string    `json:"provider"`
+	Verdict  string    `json:"verdict"`
+	Reason   string    `json:"reason"`
+}
+
+// logOutageEntry records a local network outage in the JSON Lines log
+type logOutageEntry struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	Gateway      string    `json:"gateway"`
+	GatewayOK    bool      `json:"gateway_ok"`
+	Resolver     string    `json:"resolver"`
+	ResolverOK   bool      `json:"resolver_ok"`
+	AnchorsOK    int       `json:"anchors_ok"`
+	AnchorsTotal int       `json:"anchors_total"`
+	Failed       int       `json:"failed"`
+	Total        int       `json:"total"`
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newLogResultEntry converts a test result for the JSON log
+func newLogResultEntry(result TestResult) logResultEntry {
+	entry := logResultEntry{
+		Time:        result.Timestamp,
+		Event:       "result",
+		Status:      resultStatus(result),
+		Service:     serviceKeyFor(result.Endpoint, result.TestType, result.Target),
+		Location:    result.Endpoint.Location,
+		Region:      result.Endpoint.Region,
+		Provider:    result.Endpoint.Provider,
+		Hostname:    result.Endpoint.Hostname,
+		Labels:      result.Endpoint.Labels,
+		Class:       result.Endpoint.Class,
+		TestType:    result.TestType,
+		Target:      result.Target,
+		Online:      result.Online,
+		Degraded:    result.Degraded,
+		ResponseMs:  milliseconds(result.ResponseTime),
+		ResolvedIP:  result.ResolvedIP,
+		Error:       result.Error,
+		Trend:       result.Trend,
+		BaselineMs:  milliseconds(result.Baseline),
+		Maintenance: result.Maintenance,
+		LocalOutage: result.LocalOutage,
+		StatusCode:  result.StatusCode,
+		ElapsedMs:   milliseconds(result.Elapsed),
+		Fields:      result.Fields,
+		Details:     result.Details,
+		Note:        result.Note,
+	}
+	if !result.Online {
+		entry.ErrorCategory = errorCategory(result)
+	}
+	if result.TestType == TestTypePing {
+		loss := result.Loss
+		entry.Loss = &loss
+	}
+	for _, phase := range result.Phases {
+		entry.Phases = append(entry.Phases, logPhase{
+			Name:       phase.Name,
+			Start:      phase.Start,
+			DurationMs: milliseconds(phase.Duration),
+			Error:      phase.Error,
+		})
+	}
+	return entry
+}
+
+// writeJSONLog writes a cycle as JSON Lines
+func writeJSONLog(report *CycleReport, results []TestResult, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	if report.Cycle == CycleLocalOutage {
+		entry := logOutageEntry{
+			Time:         report.Start,
+			Event:        "local_outage",
+			Gateway:      report.Local.Gateway,
+			GatewayOK:    report.Local.GatewayOK,
+			Resolver:     report.Local.Resolver,
+			ResolverOK:   report.Local.ResolverOK,
+			AnchorsOK:    report.Local.AnchorsOK,
+			AnchorsTotal: report.Local.AnchorsTotal,
+			Failed:       countFailed(report.Results),
+			Total:        len(report.Results),
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range results {
+		if err := encoder.Encode(newLogResultEntry(result)); err != nil {
+			return err
+		}
+	}
+
+	for _, diagnosis := range report.Diagnoses {
+		err := encoder.Encode(logDiagnosisEntry{
+			Time:     report.Start,
+			Event:    "diagnosis",
+			Location: diagnosis.Endpoint.Location,
+			Region:   diagnosis.Endpoint.Region,
+			Provider: diagnosis.Endpoint.Provider,
+			Verdict:  diagnosis.Verdict,
+			Reason:   diagnosis.Reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logfmtValue quotes a logfmt value when it contains spaces, quotes or '='
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=\t\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// logfmtLine renders alternating keys and values as one logfmt line
+func logfmtLine(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(pairs[i] + "=" + logfmtValue(pairs[i+1]))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// formatMs renders milliseconds without trailing zeros
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(milliseconds(d), 'f', -1, 64)
+}
+
+// writeLogfmtLog writes a cycle as logfmt lines
+func writeLogfmtLog(report *CycleReport, results []TestResult, w io.Writer) error {
+	var b strings.Builder
+
+	if report.Cycle == CycleLocalOutage {
+		b.WriteString(logfmtLine(
+			"time", report.Start.Format(time.RFC3339), "event", "local_outage",
+			"gateway", report.Local.Gateway, "gateway_ok", strconv.FormatBool(report.Local.GatewayOK),
+			"resolver", report.Local.Resolver, "resolver_ok", strconv.FormatBool(report.Local.ResolverOK),
+			"anchors_ok", strconv.Itoa(report.Local.AnchorsOK), "anchors_total", strconv.Itoa(report.Local.AnchorsTotal),
+			"failed", strconv.Itoa(countFailed(report.Results)), "total", strconv.Itoa(len(report.Results))))
+	}
+
+	for _, result := range results {
+		pairs := []string{
+			"time", result.Timestamp.Format(time.RFC3339), "event", "result", "status", resultStatus(result),
+			"location", result.Endpoint.Location, "provider", result.Endpoint.Provider,
+			"region", result.Endpoint.Region, "hostname", result.Endpoint.Hostname,
+			"test_type", string(result.TestType), "response_ms", formatMs(result.ResponseTime),
+		}
+		if result.Target != "" {
+			pairs = append(pairs, "target", result.Target)
+		}
+		if result.Trend != "" {
+			pairs = append(pairs, "trend", result.Trend)
+		}
+		if result.Baseline > 0 {
+			pairs = append(pairs, "baseline_ms", formatMs(result.Baseline))
+		}
+		if result.ResolvedIP != "" {
+			pairs = append(pairs, "resolved_ip", result.ResolvedIP)
+		}
+		if result.TestType == TestTypePing {
+			pairs = append(pairs, "loss", strconv.FormatFloat(result.Loss, 'f', -1, 64))
+		}
+		if result.StatusCode != 0 {
+			pairs = append(pairs, "status_code", strconv.Itoa(result.StatusCode))
+		}
+		if result.Error != "" {
+			pairs = append(pairs, "error", result.Error, "error_category", errorCategory(result))
+		}
+		if result.Maintenance != "" {
+			pairs = append(pairs, "maintenance", result.Maintenance)
+		}
+		if result.LocalOutage {
+			pairs = append(pairs, "local_outage", "true")
+		}
+		if result.Note != "" {
+			pairs = append(pairs, "note", result.Note)
+		}
+		for _, phase := range result.Phases {
+			pairs = append(pairs, "phase."+phase.Name+"_ms", formatMs(phase.Duration))
+		}
+		for _, name := range sortedFieldNames(result.Fields) {
+			pairs = append(pairs, "field."+name, strconv.FormatFloat(result.Fields[name], 'f', -1, 64))
 		}
+		for _, key := range sortedKeys(result.Details) {
+			pairs = append(pairs, "detail."+key, result.Details[key])
+		}
+		for _, key := range sortedKeys(result.Endpoint.Labels) {
+			pairs = append(pairs, "label."+key, result.Endpoint.Labels[key])
+		}
+		pairs = append(pairs, "elapsed_ms", formatMs(result.Elapsed))
+		b.WriteString(logfmtLine(pairs...))
 	}
 
-	// Print results grouped by test type
-	if len(pingResults) > 0 {
-		fmt.Printf("\n%s=== ICMP PING TESTS (Network Layer Latency) ===%s\n", ColorMagenta, ColorReset)
-		for _, result := range pingResults {
-			printResult(result)
-			writeToLog(result, logFile)
+	for _, diagnosis := range report.Diagnoses {
+		b.WriteString(logfmtLine(
+			"time", report.Start.Format(time.RFC3339), "event", "diagnosis",
+			"location", diagnosis.Endpoint.Location, "provider", diagnosis.Endpoint.Provider,
+			"verdict", diagnosis.Verdict, "reason", diagnosis.Reason))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedKeys returns the keys of a string map in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countFailed counts the results that failed
+func countFailed(results []TestResult) int {
+	failed := 0
+	for _, result := range results {
+		if !result.Online {
+			failed++
 		}
 	}
+	return failed
+}
 
-	if len(dnsResults) > 0 {
-		fmt.Printf("\n%s=== DNS RESOLUTION TESTS ===%s\n", ColorMagenta, ColorReset)
-		for _, result := range dnsResults {
-			printResult(result)
-			writeToLog(result, logFile)
+// RotatingLog is an append-only log file that is rotated by size or age.
+// Rotated files are renamed with their rotation time, e.g.
+// cloud_latency-20260118-150405.log, optionally gzipped, and only the newest
+// MaxBackups are kept.
+type RotatingLog struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	file       *os.File
+	size       int64
+	started    time.Time // time of the oldest line in the current file
+	mu         sync.Mutex
+}
+
+// OpenRotatingLog opens (or creates) the log file for appending
+func OpenRotatingLog(config LogConfig) (*RotatingLog, error) {
+	path := config.File
+	if path == "" {
+		path = "cloud_latency.log"
+	}
+	rl := &RotatingLog{
+		path:       path,
+		maxSize:    int64(config.MaxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(config.MaxAge),
+		maxBackups: config.MaxBackups,
+		compress:   config.Compress,
+	}
+	if err := rl.open(); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// firstLineTimeRe finds the timestamp at the start of a text, JSON or logfmt log line
+var firstLineTimeRe = regexp.MustCompile(`^(?:(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})|\{"time":"([^"]+)"|time=(\S+))`)
+
+// oldestLineTime reads the timestamp of the first line, falling back to the modification time
+func oldestLineTime(path string, info os.FileInfo) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return info.ModTime()
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	if matches := firstLineTimeRe.FindStringSubmatch(line); matches != nil {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", matches[1], time.Local); err == nil {
+			return t
+		}
+		for _, value := range matches[2:] {
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t
+			}
 		}
 	}
+	return info.ModTime()
+}
 
-	if len(httpResults) > 0 {
-		fmt.Printf("\n%s=== HTTP/HTTPS TESTS (Application Layer Latency) ===%s\n", ColorMagenta, ColorReset)
-		for _, result := range httpResults {
-			printResult(result)
-			writeToLog(result, logFile)
+func (rl *RotatingLog) open() error {
+	file, err := os.OpenFile(rl.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rl.file = file
+	rl.size = info.Size()
+	rl.started = time.Now()
+	if rl.size > 0 {
+		rl.started = oldestLineTime(rl.path, info)
+	}
+	return nil
+}
+
+// Write appends p, rotating first when the file is too large or too old
+func (rl *RotatingLog) Write(p []byte) (int, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rl.size > 0 && ((rl.maxSize > 0 && rl.size+int64(len(p)) > rl.maxSize) ||
+		(rl.maxAge > 0 && time.Since(rl.started) >= rl.maxAge)) {
+		if err := rl.rotate(); err != nil {
+			return 0, err
 		}
 	}
 
-	// Print summary
-	elapsed := time.Since(startTime)
-	successRate := float64(successfulTests) / float64(totalTests) * 100
-	avgResponseTime := time.Duration(0)
-	if successfulTests > 0 {
-		avgResponseTime = totalResponseTime / time.Duration(successfulTests)
+	n, err := rl.file.Write(p)
+	rl.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file, reopens a fresh one and trims old backups
+func (rl *RotatingLog) rotate() error {
+	if err := rl.file.Close(); err != nil {
+		return err
 	}
+	rl.file = nil
 
-	fmt.Printf("\n%s=== SUMMARY ===%s", ColorCyan, ColorReset)
-	fmt.Printf("\nTotal tests executed: %d", totalTests)
-	fmt.Printf("\n%sSuccess rate: %.1f%% (%d/%d)%s",
-		ColorGreen, successRate, successfulTests, totalTests, ColorReset)
-	fmt.Printf("\nAverage response time: %dms", avgResponseTime.Milliseconds())
-	fmt.Printf("\nTotal execution time: %.2fs\n", elapsed.Seconds())
+	ext := filepath.Ext(rl.path)
+	base := strings.TrimSuffix(rl.path, ext)
+	stamp := time.Now().Format("20060102-150405")
+	backup := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			if _, err := os.Stat(backup + ".gz"); os.IsNotExist(err) {
+				break
+			}
+		}
+		backup = fmt.Sprintf("%s-%s.%d%s", base, stamp, i, ext)
+	}
+
+	if err := os.Rename(rl.path, backup); err != nil {
+		rl.open()
+		return err
+	}
+	if err := rl.open(); err != nil {
+		return err
+	}
 
-	// Save history
-	if err := history.SaveToFile("latency_history.json"); err != nil {
-		fmt.Printf("%sWarning: Could not save history: %v%s\n", ColorYellow, err, ColorReset)
+	if rl.compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Printf("%sWarning: Could not compress %s: %v%s\n", ColorYellow, backup, err, ColorReset)
+		}
 	}
+	rl.pruneBackups(base, ext)
+	return nil
 }
 
-func main() {
-	fmt.Printf("%s=== CLOUD INFRASTRUCTURE LATENCY MONITOR ===%s\n", ColorCyan, ColorReset)
-	fmt.Println("Testing AWS regional S3 endpoints")
-	fmt.Println("Press Ctrl+C to stop monitoring")
+// gzipFile replaces path with path.gz
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
 
-	// Initialize history store
-	history := NewHistoryStore()
-	if err := history.LoadFromFile("latency_history.json"); err != nil {
-		fmt.Printf("%sWarning: Could not load history: %v%s\n", ColorYellow, err, ColorReset)
-	} else {
-		fmt.Printf("%sLoaded historical data from: latency_history.json%s\n", ColorYellow, ColorReset)
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
 	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
 
-	// Open log file
-	logFile, err := os.OpenFile("cloud_latency.log",
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// pruneBackups deletes the oldest rotated files beyond maxBackups. Only
+// names rotate produces are considered: base-20060102-150405[.N]ext[.gz]
+func (rl *RotatingLog) pruneBackups(base, ext string) {
+	if rl.maxBackups <= 0 {
+		return
+	}
+	candidates, err := filepath.Glob(base + "-*" + ext + "*")
 	if err != nil {
-		fmt.Printf("%sWarning: Could not open log file: %v%s\n",
-			ColorYellow, err, ColorReset)
-		logFile = nil
-	} else {
-		defer logFile.Close()
-		fmt.Printf("%sLogging to: cloud_latency.log%s\n\n", ColorYellow, ColorReset)
+		return
+	}
+
+	type backup struct {
+		path  string
+		stamp string
+		index int // a second rotation within the same second gets .1, .2, ...
+	}
+	backupRe := regexp.MustCompile(`^` + regexp.QuoteMeta(filepath.Base(base)) +
+		`-(\d{8}-\d{6})(?:\.(\d+))?` + regexp.QuoteMeta(ext) + `(?:\.gz)?$`)
+	var backups []backup
+	for _, path := range candidates {
+		matches := backupRe.FindStringSubmatch(filepath.Base(path))
+		if matches == nil {
+			continue
+		}
+		index := 0
+		if matches[2] != "" {
+			index, _ = strconv.Atoi(matches[2])
+		}
+		backups = append(backups, backup{path: path, stamp: matches[1], index: index})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp < backups[j].stamp
+		}
+		return backups[i].index < backups[j].index
+	})
+	for len(backups) > rl.maxBackups {
+		os.Remove(backups[0].path)
+		backups = backups[1:]
+	}
+}
+
+// Close closes the current file
+func (rl *RotatingLog) Close() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.file == nil {
+		return nil
+	}
+	err := rl.file.Close()
+	rl.file = nil
+	return err
+}
+
+// HistorySink saves the rolling baselines to latency_history.json
+type HistorySink struct {
+	history  *HistoryStore
+	filename string
+	metrics  *Metrics
+}
+
+func (s *HistorySink) Name() string { return "history" }
+
+func (s *HistorySink) WriteCycle(report *CycleReport) error {
+	if err := s.history.SaveToFile(s.filename); err != nil {
+		s.metrics.HistorySaveFailed()
+		return fmt.Errorf("could not save history: %v", err)
+	}
+	return nil
+}
+
+// ArchiveSink appends every result, with its diagnosis, to the long-term archive
+type ArchiveSink struct {
+	archive *HistoryArchive
+	metrics *Metrics
+}
+
+func (s *ArchiveSink) Name() string { return "archive" }
+
+func (s *ArchiveSink) WriteCycle(report *CycleReport) error {
+	verdicts := make(map[string]EndpointDiagnosis)
+	for _, diagnosis := range report.Diagnoses {
+		verdicts[diagnosis.Endpoint.Location+"|"+diagnosis.Endpoint.Provider] = diagnosis
+	}
+
+	records := make([]ArchiveRecord, 0, len(report.Results))
+	for _, result := range report.Results {
+		diagnosis := verdicts[result.Endpoint.Location+"|"+result.Endpoint.Provider]
+		records = append(records, newArchiveRecord(result, report.Cycle, diagnosis))
+	}
+	if err := s.archive.Append(records); err != nil {
+		s.metrics.ArchiveWriteFailed()
+		return fmt.Errorf("could not archive results: %v", err)
+	}
+	return nil
+}
+
+// SLOStatusSink writes slo_status.json for the analyzer, dashboard and CSV export
+type SLOStatusSink struct {
+	filename string
+}
+
+func (s *SLOStatusSink) Name() string { return "slo" }
+
+func (s *SLOStatusSink) WriteCycle(report *CycleReport) error {
+	if len(report.SLOs) == 0 {
+		return nil
+	}
+	if err := saveSLOStatus(s.filename, report.SLOs); err != nil {
+		return fmt.Errorf("could not save SLO status: %v", err)
+	}
+	return nil
+}
+
+// AlertSink evaluates alert rules and persists their state
+type AlertSink struct {
+	alerts   *AlertEngine
+	filename string
+	metrics  *Metrics
+}
+
+func (s *AlertSink) Name() string { return "alerts" }
+
+func (s *AlertSink) WriteCycle(report *CycleReport) error {
+	s.alerts.Process(report.Results)
+	s.alerts.ProcessSLOs(report.SLOs)
+	if err := s.alerts.SaveToFile(s.filename); err != nil {
+		s.metrics.AlertStateSaveFailed()
+		return fmt.Errorf("could not save alert state: %v", err)
+	}
+	return nil
+}
+
+// Name and WriteCycle make the Prometheus registry a sink
+func (m *Metrics) Name() string { return "prometheus" }
+
+func (m *Metrics) WriteCycle(report *CycleReport) error {
+	m.ObserveResults(report.Results)
+	m.ObserveCycle(report.End.Sub(report.Start), report.Cycle)
+	return nil
+}
+
+// Name and WriteCycle make the OTLP exporter a sink
+func (e *OTLPExporter) Name() string { return "otlp" }
+
+func (e *OTLPExporter) WriteCycle(report *CycleReport) error {
+	return e.Export(report.Start, report.End, report.Cycle, report.Results)
+}
+
+// WriteCycle formats and delivers a cycle's results
+func (o *LineOutput) WriteCycle(report *CycleReport) error {
+	return o.writer.Write(o.format(report.Results))
+}
+
+// runHealthCheck performs one complete health check cycle and publishes it to the sinks
+func runHealthCheck(endpoints []CloudEndpoint, history *HistoryStore, maintenance *MaintenanceSchedule, localChecks LocalCheckConfig, slos *SLOTracker, metrics *Metrics, live *LiveFeed, sinks *SinkPipeline) {
+	var wg sync.WaitGroup
+
+	startTime := time.Now()
+	suppressions := maintenance.Active(startTime)
+
+	// Check the local network first so a dead uplink isn't reported as remote outages
+	local := runLocalChecks(localChecks)
+
+	// Launch tests
+	probeCount := 0
+	for _, endpoint := range endpoints {
+		probeCount += len(endpoint.Probes)
+	}
+	results := make(chan TestResult, probeCount)
+	for _, endpoint := range endpoints {
+		for _, spec := range endpoint.Probes {
+			wg.Add(1)
+			go runTest(endpoint, spec, suppressionFor(suppressions, endpoint, spec.Type), results, &wg, history, metrics)
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Stream each result to the live feed as its probe finishes
+	allResults := []TestResult{}
+	for result := range results {
+		live.PublishResult(result)
+		allResults = append(allResults, result)
+	}
+	endTime := time.Now()
+
+	cycle := classifyCycle(local, allResults, localChecks.FailureRatio)
+	for i := range allResults {
+		if !allResults[i].Online && allResults[i].Endpoint.Class != EndpointClassHome {
+			allResults[i].LocalOutage = cycle == CycleLocalOutage
+		}
+	}
+
+	// Combine the layers into one verdict per endpoint
+	diagnoses := diagnoseCycle(allResults, cycle)
+
+	// Evaluate SLOs
+	slos.Record(allResults)
+	sloStatuses := slos.Evaluate(time.Now())
+
+	sinks.Publish(&CycleReport{
+		Start:     startTime,
+		End:       endTime,
+		Cycle:     cycle,
+		Local:     local,
+		Results:   allResults,
+		Diagnoses: diagnoses,
+		SLOs:      sloStatuses,
+	})
+}
+
+// regionCoordinates places the regions of each provider at the city hosting
+// them, or the nearest large city
+var regionCoordinates = map[string]map[string]Coordinates{
+	"AWS": {
+		"us-east-1":      {39.04, -77.49},  // N. Virginia
+		"us-east-2":      {39.96, -83.00},  // Ohio
+		"us-west-1":      {37.34, -121.89}, // N. California
+		"us-west-2":      {45.52, -122.68}, // Oregon
+		"ca-central-1":   {45.50, -73.57},  // Montreal
+		"ca-west-1":      {51.05, -114.07}, // Calgary
+		"mx-central-1":   {20.59, -100.39}, // Querétaro
+		"sa-east-1":      {-23.55, -46.63}, // São Paulo
+		"eu-west-1":      {53.35, -6.26},   // Dublin
+		"eu-west-2":      {51.51, -0.13},   // London
+		"eu-west-3":      {48.86, 2.35},    // Paris
+		"eu-central-1":   {50.11, 8.68},    // Frankfurt
+		"eu-central-2":   {47.38, 8.54},    // Zurich
+		"eu-north-1":     {59.33, 18.07},   // Stockholm
+		"eu-south-1":     {45.46, 9.19},    // Milan
+		"eu-south-2":     {41.65, -0.89},   // Aragón
+		"il-central-1":   {32.09, 34.78},   // Tel Aviv
+		"me-south-1":     {26.07, 50.56},   // Bahrain
+		"me-central-1":   {25.20, 55.27},   // UAE
+		"af-south-1":     {-33.92, 18.42},  // Cape Town
+		"ap-south-1":     {19.08, 72.88},   // Mumbai
+		"ap-south-2":     {17.39, 78.49},   // Hyderabad
+		"ap-east-1":      {22.32, 114.17},  // Hong Kong
+		"ap-southeast-1": {1.35, 103.82},   // Singapore
+		"ap-southeast-2": {-33.87, 151.21}, // Sydney
+		"ap-southeast-3": {-6.21, 106.85},  // Jakarta
+		"ap-southeast-4": {-37.81, 144.96}, // Melbourne
+		"ap-southeast-5": {3.14, 101.69},   // Malaysia
+		"ap-southeast-7": {13.76, 100.50},  // Thailand
+		"ap-northeast-1": {35.68, 139.69},  // Tokyo
+		"ap-northeast-2": {37.57, 126.98},  // Seoul
+		"ap-northeast-3": {34.69, 135.50},  // Osaka
+		"cn-north-1":     {39.90, 116.40},  // Beijing
+		"cn-northwest-1": {38.47, 106.27},  // Ningxia
+	},
+	"Azure": {
+		"eastus":             {37.37, -79.82},  // Virginia
+		"eastus2":            {36.68, -78.39},  // Virginia
+		"centralus":          {41.59, -93.62},  // Iowa
+		"northcentralus":     {41.88, -87.63},  // Illinois
+		"southcentralus":     {29.42, -98.49},  // Texas
+		"westcentralus":      {41.14, -104.82}, // Wyoming
+		"westus":             {37.78, -122.42}, // California
+		"westus2":            {47.23, -119.85}, // Washington
+		"westus3":            {33.45, -112.07}, // Arizona
+		"canadacentral":      {43.65, -79.38},  // Toronto
+		"canadaeast":         {46.82, -71.22},  // Quebec City
+		"mexicocentral":      {20.59, -100.39}, // Querétaro
+		"brazilsouth":        {-23.55, -46.63}, // São Paulo
+		"northeurope":        {53.35, -6.26},   // Ireland
+		"westeurope":         {52.37, 4.90},    // Netherlands
+		"uksouth":            {51.51, -0.13},   // London
+		"ukwest":             {51.48, -3.18},   // Cardiff
+		"francecentral":      {48.86, 2.35},    // Paris
+		"germanywestcentral": {50.11, 8.68},    // Frankfurt
+		"switzerlandnorth":   {47.38, 8.54},    // Zurich
+		"norwayeast":         {59.91, 10.75},   // Oslo
+		"swedencentral":      {60.67, 17.14},   // Gävle
+		"polandcentral":      {52.23, 21.01},   // Warsaw
+		"italynorth":         {45.46, 9.19},    // Milan
+		"spaincentral":       {40.42, -3.70},   // Madrid
+		"israelcentral":      {32.09, 34.78},   // Israel
+		"uaenorth":           {25.27, 55.30},   // Dubai
+		"qatarcentral":       {25.29, 51.53},   // Doha
+		"southafricanorth":   {-26.20, 28.05},  // Johannesburg
+		"centralindia":       {18.52, 73.86},   // Pune
+		"southindia":         {13.08, 80.27},   // Chennai
+		"westindia":          {19.08, 72.88},   // Mumbai
+		"southeastasia":      {1.35, 103.82},   // Singapore
+		"eastasia":           {22.32, 114.17},  // Hong Kong
+		"japaneast":          {35.68, 139.69},  // Tokyo
+		"japanwest":          {34.69, 135.50},  // Osaka
+		"koreacentral":       {37.57, 126.98},  // Seoul
+		"australiaeast":      {-33.87, 151.21}, // New South Wales
+		"australiasoutheast": {-37.81, 144.96}, // Victoria
+		"australiacentral":   {-35.28, 149.13}, // Canberra
+	},
+	"GCP": {
+		"us-east1":                {33.20, -80.01},  // South Carolina
+		"us-east4":                {39.04, -77.49},  // N. Virginia
+		"us-east5":                {39.96, -83.00},  // Columbus
+		"us-central1":             {41.26, -95.86},  // Iowa
+		"us-south1":               {32.78, -96.80},  // Dallas
+		"us-west1":                {45.59, -121.18}, // Oregon
+		"us-west2":                {34.05, -118.24}, // Los Angeles
+		"us-west3":                {40.76, -111.89}, // Salt Lake City
+		"us-west4":                {36.17, -115.14}, // Las Vegas
+		"northamerica-northeast1": {45.50, -73.57},  // Montréal
+		"northamerica-northeast2": {43.65, -79.38},  // Toronto
+		"northamerica-south1":     {20.59, -100.39}, // Querétaro
+		"southamerica-east1":      {-23.55, -46.63}, // São Paulo
+		"southamerica-west1":      {-33.45, -70.67}, // Santiago
+		"europe-west1":            {50.45, 3.82},    // Belgium
+		"europe-west2":            {51.51, -0.13},   // London
+		"europe-west3":            {50.11, 8.68},    // Frankfurt
+		"europe-west4":            {53.44, 6.83},    // Netherlands
+		"europe-west6":            {47.38, 8.54},    // Zurich
+		"europe-west8":            {45.46, 9.19},    // Milan
+		"europe-west9":            {48.86, 2.35},    // Paris
+		"europe-west10":           {52.52, 13.40},   // Berlin
+		"europe-west12":           {45.07, 7.69},    // Turin
+		"europe-north1":           {60.57, 27.20},   // Finland
+		"europe-central2":         {52.23, 21.01},   // Warsaw
+		"europe-southwest1":       {40.42, -3.70},   // Madrid
+		"me-west1":                {32.09, 34.78},   // Tel Aviv
+		"me-central1":             {25.29, 51.53},   // Doha
+		"me-central2":             {26.43, 50.10},   // Dammam
+		"africa-south1":           {-26.20, 28.05},  // Johannesburg
+		"asia-south1":             {19.08, 72.88},   // Mumbai
+		"asia-south2":             {28.61, 77.21},   // Delhi
+		"asia-southeast1":         {1.35, 103.82},   // Singapore
+		"asia-southeast2":         {-6.21, 106.85},  // Jakarta
+		"asia-east1":              {24.08, 120.54},  // Taiwan
+		"asia-east2":              {22.32, 114.17},  // Hong Kong
+		"asia-northeast1":         {35.68, 139.69},  // Tokyo
+		"asia-northeast2":         {34.69, 135.50},  // Osaka
+		"asia-northeast3":         {37.57, 126.98},  // Seoul
+		"australia-southeast1":    {-33.87, 151.21}, // Sydney
+		"australia-southeast2":    {-37.81, 144.96}, // Melbourne
+	},
+}
+
+// regionCoordinatesFor looks up a known provider region, returning nil for
+// regions not in the table
+func regionCoordinatesFor(provider, region string) *Coordinates {
+	coordinates, ok := regionCoordinates[provider][strings.ToLower(region)]
+	if !ok {
+		return nil
 	}
+	return &coordinates
+}
 
-	// Define cloud endpoints to test - All AWS S3 regional endpoints
-	endpoints := []CloudEndpoint{
+// defaultEndpoints lists the cloud endpoints to test - All AWS S3 regional endpoints
+func defaultEndpoints() []CloudEndpoint {
+	return []CloudEndpoint{
 		// Africa
-		{Location: "Cape Town, ZA", Region: "af-south-1", Provider: "AWS", Hostname: "s3.af-south-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Cape Town, ZA", Region: "af-south-1", Provider: "AWS", Hostname: "s3.af-south-1.amazonaws.com", Probes: defaultProbes},
 
 		// South America
-		{Location: "São Paulo, BR", Region: "sa-east-1", Provider: "AWS", Hostname: "s3.sa-east-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "São Paulo, BR", Region: "sa-east-1", Provider: "AWS", Hostname: "s3.sa-east-1.amazonaws.com", Probes: defaultProbes},
 
 		// Europe
-		{Location: "Paris, FR", Region: "eu-west-3", Provider: "AWS", Hostname: "s3.eu-west-3.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Frankfurt, DE", Region: "eu-central-1", Provider: "AWS", Hostname: "s3.eu-central-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "London, UK", Region: "eu-west-2", Provider: "AWS", Hostname: "s3.eu-west-2.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Stockholm, SE", Region: "eu-north-1", Provider: "AWS", Hostname: "s3.eu-north-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Milan, IT", Region: "eu-south-1", Provider: "AWS", Hostname: "s3.eu-south-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Paris, FR", Region: "eu-west-3", Provider: "AWS", Hostname: "s3.eu-west-3.amazonaws.com", Probes: defaultProbes},
+		{Location: "Frankfurt, DE", Region: "eu-central-1", Provider: "AWS", Hostname: "s3.eu-central-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "London, UK", Region: "eu-west-2", Provider: "AWS", Hostname: "s3.eu-west-2.amazonaws.com", Probes: defaultProbes},
+		{Location: "Stockholm, SE", Region: "eu-north-1", Provider: "AWS", Hostname: "s3.eu-north-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Milan, IT", Region: "eu-south-1", Provider: "AWS", Hostname: "s3.eu-south-1.amazonaws.com", Probes: defaultProbes},
 
 		// Middle East
-		{Location: "Dubai, AE", Region: "me-south-1", Provider: "AWS", Hostname: "s3.me-south-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Riyadh, SA", Region: "me-central-1", Provider: "AWS", Hostname: "s3.me-central-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Dubai, AE", Region: "me-south-1", Provider: "AWS", Hostname: "s3.me-south-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Riyadh, SA", Region: "me-central-1", Provider: "AWS", Hostname: "s3.me-central-1.amazonaws.com", Probes: defaultProbes},
 
 		// Asia - South
-		{Location: "Mumbai, IN", Region: "ap-south-1", Provider: "AWS", Hostname: "s3.ap-south-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Hyderabad, IN", Region: "ap-south-2", Provider: "AWS", Hostname: "s3.ap-south-2.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Mumbai, IN", Region: "ap-south-1", Provider: "AWS", Hostname: "s3.ap-south-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Hyderabad, IN", Region: "ap-south-2", Provider: "AWS", Hostname: "s3.ap-south-2.amazonaws.com", Probes: defaultProbes},
 
 		// Asia - Southeast
-		{Location: "Singapore, SG", Region: "ap-southeast-1", Provider: "AWS", Hostname: "s3.ap-southeast-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Jakarta, ID", Region: "ap-southeast-3", Provider: "AWS", Hostname: "s3.ap-southeast-3.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Singapore, SG", Region: "ap-southeast-1", Provider: "AWS", Hostname: "s3.ap-southeast-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Jakarta, ID", Region: "ap-southeast-3", Provider: "AWS", Hostname: "s3.ap-southeast-3.amazonaws.com", Probes: defaultProbes},
 
 		// Asia - East
-		{Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS", Hostname: "s3.ap-northeast-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Seoul, KR", Region: "ap-northeast-2", Provider: "AWS", Hostname: "s3.ap-northeast-2.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Osaka, JP", Region: "ap-northeast-3", Provider: "AWS", Hostname: "s3.ap-northeast-3.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS", Hostname: "s3.ap-northeast-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Seoul, KR", Region: "ap-northeast-2", Provider: "AWS", Hostname: "s3.ap-northeast-2.amazonaws.com", Probes: defaultProbes},
+		{Location: "Osaka, JP", Region: "ap-northeast-3", Provider: "AWS", Hostname: "s3.ap-northeast-3.amazonaws.com", Probes: defaultProbes},
 
 		// Oceania
-		{Location: "Sydney, AU", Region: "ap-southeast-2", Provider: "AWS", Hostname: "s3.ap-southeast-2.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Melbourne, AU", Region: "ap-southeast-4", Provider: "AWS", Hostname: "s3.ap-southeast-4.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Sydney, AU", Region: "ap-southeast-2", Provider: "AWS", Hostname: "s3.ap-southeast-2.amazonaws.com", Probes: defaultProbes},
+		{Location: "Melbourne, AU", Region: "ap-southeast-4", Provider: "AWS", Hostname: "s3.ap-southeast-4.amazonaws.com", Probes: defaultProbes},
 
 		// North America - East
-		{Location: "Ashburn, VA", Region: "us-east-1", Provider: "AWS", Hostname: "s3.us-east-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Columbus, OH", Region: "us-east-2", Provider: "AWS", Hostname: "s3.us-east-2.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Ashburn, VA", Region: "us-east-1", Provider: "AWS", Hostname: "s3.us-east-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Columbus, OH", Region: "us-east-2", Provider: "AWS", Hostname: "s3.us-east-2.amazonaws.com", Probes: defaultProbes},
 
 		// North America - West
-		{Location: "San Jose, CA", Region: "us-west-1", Provider: "AWS", Hostname: "s3.us-west-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
-		{Location: "Portland, OR", Region: "us-west-2", Provider: "AWS", Hostname: "s3.us-west-2.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "San Jose, CA", Region: "us-west-1", Provider: "AWS", Hostname: "s3.us-west-1.amazonaws.com", Probes: defaultProbes},
+		{Location: "Portland, OR", Region: "us-west-2", Provider: "AWS", Hostname: "s3.us-west-2.amazonaws.com", Probes: defaultProbes},
 
 		// Canada
-		{Location: "Montreal, CA", Region: "ca-central-1", Provider: "AWS", Hostname: "s3.ca-central-1.amazonaws.com", TestPing: true, TestDNS: true, TestHTTP: true},
+		{Location: "Montreal, CA", Region: "ca-central-1", Provider: "AWS", Hostname: "s3.ca-central-1.amazonaws.com", Probes: defaultProbes},
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "probes" {
+		printProbers()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import-logs" {
+		if err := runImportLogsCommand(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "silence" {
+		if err := runSilenceCommand(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("%s=== CLOUD INFRASTRUCTURE LATENCY MONITOR ===%s\n", ColorCyan, ColorReset)
+	fmt.Println("Testing AWS regional S3 endpoints")
+	fmt.Println("Press Ctrl+C to stop monitoring")
+
+	// Initialize history store
+	history := NewHistoryStore()
+	if err := history.LoadFromFile("latency_history.json"); err != nil {
+		fmt.Printf("%sWarning: Could not load history: %v%s\n", ColorYellow, err, ColorReset)
+	} else {
+		fmt.Printf("%sLoaded historical data from: latency_history.json%s\n", ColorYellow, ColorReset)
+	}
+
+	// Load optional configuration
+	config, err := loadConfig("monitor_config.json")
+	if err != nil {
+		fmt.Printf("%sWarning: Could not load config: %v%s\n", ColorYellow, err, ColorReset)
+	}
+	if config.Vantage != nil && !config.Vantage.Valid() {
+		fmt.Printf("%sWarning: vantage point coordinates %.4f, %.4f are out of range%s\n", ColorYellow,
+			config.Vantage.Latitude, config.Vantage.Longitude, ColorReset)
+	}
+
+	// Initialize alerting
+	alerts, problems := NewAlertEngine(config.Alerts, config.SLOs)
+	for _, err := range problems {
+		fmt.Printf("%sWarning: %v, skipping%s\n", ColorYellow, err, ColorReset)
+	}
+	if len(alerts.rules) > 0 {
+		fmt.Printf("%sAlerting: %d rules, %d notifiers%s\n", ColorYellow,
+			len(alerts.rules), len(alerts.notifiers), ColorReset)
+		if err := alerts.LoadFromFile("alert_state.json"); err != nil {
+			fmt.Printf("%sWarning: Could not load alert state: %v%s\n", ColorYellow, err, ColorReset)
+		}
+	}
+
+	// Initialize long-term archive
+	archive, err := NewHistoryArchive(config.History)
+	if err != nil {
+		fmt.Printf("%sWarning: Could not open history archive: %v%s\n", ColorYellow, err, ColorReset)
+		archive = nil
+	}
+
+	// Initialize SLOs, replaying the archive so error budgets survive restarts
+	slos, err := NewSLOTracker(config.SLOs)
+	if err != nil {
+		fmt.Printf("%sWarning: SLOs disabled: %v%s\n", ColorYellow, err, ColorReset)
+		slos = nil
+	} else if len(config.SLOs) > 0 {
+		if err := slos.LoadFromArchive(archive); err != nil {
+			fmt.Printf("%sWarning: Could not replay archive for SLOs: %v%s\n", ColorYellow, err, ColorReset)
+		}
+		fmt.Printf("%sSLOs: %d defined%s\n", ColorYellow, len(config.SLOs), ColorReset)
+	}
+
+	// Initialize maintenance windows and silences
+	maintenance, err := NewMaintenanceSchedule(config.Maintenance, "silences.json")
+	if err != nil {
+		fmt.Printf("%sWarning: Maintenance windows disabled: %v%s\n", ColorYellow, err, ColorReset)
+		maintenance, _ = NewMaintenanceSchedule(nil, "silences.json")
+	}
+
+	// Serve Prometheus metrics
+	var metrics *Metrics
+	if config.Metrics.Listen != "" {
+		metrics = NewMetrics(config.Metrics)
+		startMetricsServer(config.Metrics, metrics)
+		metricsPath := config.Metrics.Path
+		if metricsPath == "" {
+			metricsPath = "/metrics"
+		}
+		fmt.Printf("%sMetrics: http://%s%s%s\n", ColorYellow, config.Metrics.Listen, metricsPath, ColorReset)
+	}
+
+	// Console, log file, history, alerting and exporters all receive each cycle
+	// through the sink pipeline
+	sinks := NewSinkPipeline(config.Sinks)
+	sinks.Add(ConsoleSink{})
+	sinks.Add(&HistorySink{history: history, filename: "latency_history.json", metrics: metrics})
+	if archive != nil {
+		sinks.Add(&ArchiveSink{archive: archive, metrics: metrics})
+	}
+	if slos != nil && len(config.SLOs) > 0 {
+		sinks.Add(&SLOStatusSink{filename: "slo_status.json"})
+	}
+	if len(alerts.rules) > 0 {
+		sinks.Add(&AlertSink{alerts: alerts, filename: "alert_state.json", metrics: metrics})
+	}
+	if metrics != nil {
+		sinks.Add(metrics)
+		metrics.WatchSinks(sinks)
+	}
+
+	// Stream results to the dashboard
+	var live *LiveFeed
+	if config.Live.Listen != "" {
+		live = NewLiveFeed(config.Live)
+		if err := startLiveServer(config.Live, live); err != nil {
+			fmt.Printf("%sWarning: Live feed disabled: %v%s\n", ColorYellow, err, ColorReset)
+			live = nil
+		} else {
+			sinks.Add(live)
+			fmt.Printf("%sLive feed: %s%s\n", ColorYellow, config.Live.Listen, ColorReset)
+		}
+	}
+
+	// Initialize OpenTelemetry export
+	otlp, err := NewOTLPExporter(config.OTLP)
+	if err != nil {
+		fmt.Printf("%sWarning: OTLP export disabled: %v%s\n", ColorYellow, err, ColorReset)
+		otlp = nil
+	} else if otlp != nil {
+		sinks.Add(otlp)
+		fmt.Printf("%sOTLP: exporting to %s (%s)%s\n", ColorYellow, otlp.config.Endpoint, otlp.config.Protocol, ColorReset)
+	}
+
+	// Initialize Influx and Graphite outputs
+	if config.Influx.URL != "" {
+		if output, err := NewInfluxOutput(config.Influx); err != nil {
+			fmt.Printf("%sWarning: Influx output disabled: %v%s\n", ColorYellow, err, ColorReset)
+		} else {
+			sinks.Add(output)
+			fmt.Printf("%sInflux: writing to %s%s\n", ColorYellow, config.Influx.URL, ColorReset)
+		}
+	}
+	if config.Graphite.Address != "" {
+		if output, err := NewGraphiteOutput(config.Graphite); err != nil {
+			fmt.Printf("%sWarning: Graphite output disabled: %v%s\n", ColorYellow, err, ColorReset)
+		} else {
+			sinks.Add(output)
+			fmt.Printf("%sGraphite: writing to %s%s\n", ColorYellow, config.Graphite.Address, ColorReset)
+		}
 	}
 
+	// Open log file
+	switch config.Log.Format {
+	case LogFormatText, LogFormatJSON, LogFormatLogfmt:
+	default:
+		fmt.Printf("%sWarning: Unknown log format %q, using text%s\n", ColorYellow, config.Log.Format, ColorReset)
+		config.Log.Format = LogFormatText
+	}
+	logFile, err := OpenRotatingLog(config.Log)
+	if err != nil {
+		fmt.Printf("%sWarning: Could not open log file: %v%s\n",
+			ColorYellow, err, ColorReset)
+	} else {
+		defer logFile.Close()
+		sinks.Add(&LogFileSink{file: logFile, format: config.Log.Format})
+		fmt.Printf("%sLogging to: %s (%s)%s\n\n", ColorYellow, logFile.path, config.Log.Format, ColorReset)
+	}
+
+	endpoints := defaultEndpoints()
+
+	// Add the home network endpoints; they share the history and trend logic
+	if config.Home.Enabled {
+		if config.Home.Only {
+			endpoints = nil
+		}
+		home := homeEndpoints(config.Home, config.LocalChecks.Gateway)
+		endpoints = append(endpoints, home...)
+		fmt.Printf("%sHome network: %d endpoints%s\n", ColorYellow, len(home), ColorReset)
+	}
+
+	endpoints = validateEndpoints(append(endpoints, config.Endpoints...))
+
 	interval := 30 * time.Second
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Let the sinks finish writing the last cycle on Ctrl+C
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
 	fmt.Printf("%s[%s] Starting cloud latency test cycle...%s\n",
 		ColorCyan, time.Now().Format("15:04:05"), ColorReset)
-	runHealthCheck(endpoints, logFile, history)
+	runHealthCheck(endpoints, history, maintenance, config.LocalChecks, slos, metrics, live, sinks)
 
-	for range ticker.C {
-		fmt.Printf("\n%s[%s] Starting cloud latency test cycle...%s\n",
-			ColorCyan, time.Now().Format("15:04:05"), ColorReset)
-		runHealthCheck(endpoints, logFile, history)
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Printf("\n%s[%s] Starting cloud latency test cycle...%s\n",
+				ColorCyan, time.Now().Format("15:04:05"), ColorReset)
+			runHealthCheck(endpoints, history, maintenance, config.LocalChecks, slos, metrics, live, sinks)
+		case <-stop:
+			fmt.Printf("\n%sStopping: flushing sinks...%s\n", ColorYellow, ColorReset)
+			sinks.Close()
+			return
+		}
 	}
 }
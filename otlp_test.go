@@ -0,0 +1,487 @@
+package main
+
+// OTLP exporter tests decode what a local stand-in collector receives:
+//
+//	go test main.go otlp_test.go
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var otlpTestStart = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// otlpTestResults is one cycle with a successful HTTP test and a failed ping
+func otlpTestResults() []TestResult {
+	endpoint := CloudEndpoint{Location: "Tokyo, JP", Region: "ap-northeast-1", Provider: "AWS",
+		Hostname: "s3.ap-northeast-1.amazonaws.com", Labels: map[string]string{"tier": "gold"}}
+	return []TestResult{
+		{
+			Endpoint: endpoint, TestType: TestTypeHTTP, Online: true,
+			ResponseTime: 120 * time.Millisecond, ResolvedIP: "52.219.0.1", StatusCode: 200,
+			Timestamp: otlpTestStart, Elapsed: 130 * time.Millisecond,
+			Phases: []ProbePhase{
+				{Name: "dns", Start: otlpTestStart, Duration: 10 * time.Millisecond},
+				{Name: "connect", Start: otlpTestStart.Add(10 * time.Millisecond), Duration: 40 * time.Millisecond},
+				{Name: "tls", Start: otlpTestStart.Add(50 * time.Millisecond), Duration: 50 * time.Millisecond},
+				{Name: "ttfb", Start: otlpTestStart.Add(100 * time.Millisecond), Duration: 20 * time.Millisecond},
+			},
+		},
+		{
+			Endpoint: endpoint, TestType: TestTypePing, Online: false,
+			Error: "100% packet loss", Loss: 100, Timestamp: otlpTestStart, Elapsed: 3 * time.Second,
+		},
+	}
+}
+
+// collectorStandIn accepts OTLP/HTTP exports and returns the decompressed
+// body received on each path
+func collectorStandIn(t *testing.T, wantContentType string) (*httptest.Server, map[string][]byte) {
+	t.Helper()
+	received := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Type"); got != wantContentType {
+			t.Errorf("%s Content-Type = %q, want %q", r.URL.Path, got, wantContentType)
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "secret" {
+			t.Errorf("%s X-Api-Key = %q, want the configured header", r.URL.Path, got)
+		}
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("%s: %v", r.URL.Path, err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		received[r.URL.Path] = data
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// export sends the test cycle through an exporter pointed at url
+func export(t *testing.T, config OTLPConfig) {
+	t.Helper()
+	config.Headers = map[string]string{"X-Api-Key": "secret"}
+	exporter, err := NewOTLPExporter(config)
+	if err != nil {
+		t.Fatalf("NewOTLPExporter: %v", err)
+	}
+	end := otlpTestStart.Add(4 * time.Second)
+	if err := exporter.Export(otlpTestStart, end, CycleNormal, otlpTestResults()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+}
+
+// OTLP/JSON mirrors of the exported messages
+type jsonKeyValue struct {
+	Key   string
+	Value map[string]interface{}
+}
+
+type jsonDataPoint struct {
+	Attributes        []jsonKeyValue
+	StartTimeUnixNano string
+	TimeUnixNano      string
+	AsDouble          float64
+}
+
+type jsonTraceRequest struct {
+	ResourceSpans []struct {
+		Resource   struct{ Attributes []jsonKeyValue }
+		ScopeSpans []struct {
+			Scope struct{ Name string }
+			Spans []struct {
+				TraceID, SpanID, ParentSpanID string
+				Name                          string
+				Kind                          int
+				StartTimeUnixNano             string
+				EndTimeUnixNano               string
+				Attributes                    []jsonKeyValue
+				Status                        struct {
+					Code    int
+					Message string
+				}
+			}
+		}
+	}
+}
+
+type jsonMetricsRequest struct {
+	ResourceMetrics []struct {
+		Resource     struct{ Attributes []jsonKeyValue }
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Name, Unit string
+				Gauge      *struct{ DataPoints []jsonDataPoint }
+				Sum        *struct {
+					DataPoints             []jsonDataPoint
+					AggregationTemporality int
+					IsMonotonic            bool
+				}
+			}
+		}
+	}
+}
+
+// jsonAttribute returns the value of key, or nil when it is missing
+func jsonAttribute(attributes []jsonKeyValue, key string) interface{} {
+	for _, kv := range attributes {
+		if kv.Key != key {
+			continue
+		}
+		for _, value := range kv.Value {
+			return value
+		}
+	}
+	return nil
+}
+
+func TestOTLPJSONTraces(t *testing.T) {
+	server, received := collectorStandIn(t, "application/json")
+	export(t, OTLPConfig{Endpoint: server.URL, Protocol: OTLPProtocolJSON, DisableMetrics: true})
+
+	if _, ok := received["/v1/metrics"]; ok {
+		t.Error("metrics were exported with DisableMetrics set")
+	}
+	var request jsonTraceRequest
+	if err := json.Unmarshal(received["/v1/traces"], &request); err != nil {
+		t.Fatalf("traces payload: %v", err)
+	}
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("want one resource and scope, got %+v", request)
+	}
+	resource := request.ResourceSpans[0].Resource
+	if got := jsonAttribute(resource.Attributes, "service.name"); got != "cloud-latency-monitor" {
+		t.Errorf("service.name = %v", got)
+	}
+
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	// root, two probes and four HTTP phases
+	if len(spans) != 7 {
+		t.Fatalf("got %d spans, want 7", len(spans))
+	}
+	root := spans[0]
+	if root.Name != "health_check_cycle" || root.ParentSpanID != "" {
+		t.Errorf("root span = %+v", root)
+	}
+	if id, err := hex.DecodeString(root.TraceID); err != nil || len(id) != 16 {
+		t.Errorf("traceId %q is not 16 hex-encoded bytes", root.TraceID)
+	}
+	if got := jsonAttribute(root.Attributes, "cycle.failed"); got != "1" {
+		t.Errorf("cycle.failed = %v, want the int64 string \"1\"", got)
+	}
+	if root.StartTimeUnixNano != "1792324800000000000" {
+		t.Errorf("startTimeUnixNano = %q", root.StartTimeUnixNano)
+	}
+
+	web := spans[1]
+	if web.Name != "probe http" || web.Kind != otlpSpanKindClient || web.ParentSpanID != root.SpanID {
+		t.Errorf("HTTP span = %+v", web)
+	}
+	for key, want := range map[string]interface{}{
+		"endpoint.location":   "Tokyo, JP",
+		"endpoint.provider":   "AWS",
+		"endpoint.label.tier": "gold",
+		"test.online":         true,
+		"net.peer.ip":         "52.219.0.1",
+		"http.status_code":    "200",
+	} {
+		if got := jsonAttribute(web.Attributes, key); got != want {
+			t.Errorf("HTTP span %s = %v, want %v", key, got, want)
+		}
+	}
+	if got := jsonAttribute(web.Attributes, "test.response_time_ms"); got != 120.0 {
+		t.Errorf("test.response_time_ms = %v, want 120", got)
+	}
+	for i, phase := range []string{"dns", "connect", "tls", "ttfb"} {
+		span := spans[2+i]
+		if span.Name != phase || span.ParentSpanID != web.SpanID || span.TraceID != root.TraceID {
+			t.Errorf("phase span %d = %+v, want %s under the HTTP span", i, span, phase)
+		}
+	}
+
+	ping := spans[6]
+	if ping.Name != "probe ping" || ping.Status.Code != otlpStatusError || ping.Status.Message != "100% packet loss" {
+		t.Errorf("ping span = %+v", ping)
+	}
+	if got := jsonAttribute(ping.Attributes, "error.category"); got != ErrorCategoryNoReply {
+		t.Errorf("error.category = %v", got)
+	}
+}
+
+func TestOTLPJSONMetrics(t *testing.T) {
+	server, received := collectorStandIn(t, "application/json")
+	export(t, OTLPConfig{Endpoint: server.URL, Protocol: OTLPProtocolJSON, DisableTraces: true})
+
+	if _, ok := received["/v1/traces"]; ok {
+		t.Error("traces were exported with DisableTraces set")
+	}
+	var request jsonMetricsRequest
+	if err := json.Unmarshal(received["/v1/metrics"], &request); err != nil {
+		t.Fatalf("metrics payload: %v", err)
+	}
+	if len(request.ResourceMetrics) != 1 || len(request.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("want one resource and scope, got %+v", request)
+	}
+
+	points := make(map[string][]jsonDataPoint)
+	for _, metric := range request.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		switch {
+		case metric.Gauge != nil:
+			points[metric.Name] = metric.Gauge.DataPoints
+		case metric.Sum != nil:
+			if metric.Sum.AggregationTemporality != otlpTemporalityCumulative || !metric.Sum.IsMonotonic {
+				t.Errorf("%s is not a cumulative monotonic sum", metric.Name)
+			}
+			points[metric.Name] = metric.Sum.DataPoints
+		}
+	}
+
+	if got := points["cloud_latency.response_time"]; len(got) != 1 || got[0].AsDouble != 0.12 {
+		t.Errorf("response_time points = %+v, want one at 0.12s", got)
+	}
+	up := points["cloud_latency.up"]
+	if len(up) != 2 || up[0].AsDouble != 1 || up[1].AsDouble != 0 {
+		t.Errorf("up points = %+v, want 1 for HTTP and 0 for PING", up)
+	}
+	if got := jsonAttribute(up[0].Attributes, "test.type"); got != "HTTP" {
+		t.Errorf("up test.type = %v", got)
+	}
+	if got := points["cloud_latency.packet_loss"]; len(got) != 1 || got[0].AsDouble != 1 {
+		t.Errorf("packet_loss points = %+v, want a ratio of 1", got)
+	}
+	if got := points["cloud_latency.cycle.duration"]; len(got) != 1 || got[0].AsDouble != 4 {
+		t.Errorf("cycle.duration points = %+v, want 4s", got)
+	}
+	errors := points["cloud_latency.check.errors"]
+	if len(errors) != 1 || errors[0].AsDouble != 1 || errors[0].StartTimeUnixNano == "" {
+		t.Fatalf("check.errors points = %+v, want one failed PING", errors)
+	}
+	if got := jsonAttribute(errors[0].Attributes, "error.category"); got != ErrorCategoryNoReply {
+		t.Errorf("check.errors error.category = %v", got)
+	}
+}
+
+// protoField is one decoded protobuf field; varint and fixed64 values are
+// kept in value, length-delimited ones in data
+type protoField struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+// decodeProto splits a protobuf message into its fields
+func decodeProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad field tag in %x", b)
+		}
+		b = b[n:]
+		field := protoField{number: int(tag >> 3)}
+		switch tag & 7 {
+		case protoVarint:
+			field.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("bad varint in field %d", field.number)
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				t.Fatalf("short fixed64 in field %d", field.number)
+			}
+			field.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				t.Fatalf("bad length in field %d", field.number)
+			}
+			field.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d in field %d", tag&7, field.number)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// protoMessages decodes every embedded message stored in field number
+func protoMessages(t *testing.T, fields []protoField, number int) [][]protoField {
+	t.Helper()
+	var messages [][]protoField
+	for _, field := range fields {
+		if field.number == number {
+			messages = append(messages, decodeProto(t, field.data))
+		}
+	}
+	return messages
+}
+
+// protoScalar returns the first field with number, which must exist
+func protoScalar(t *testing.T, fields []protoField, number int) protoField {
+	t.Helper()
+	for _, field := range fields {
+		if field.number == number {
+			return field
+		}
+	}
+	t.Fatalf("field %d missing", number)
+	return protoField{}
+}
+
+// protoAttributes decodes KeyValue messages into their string, bool, int or double values
+func protoAttributes(t *testing.T, fields []protoField, number int) map[string]interface{} {
+	t.Helper()
+	attributes := make(map[string]interface{})
+	for _, kv := range protoMessages(t, fields, number) {
+		key := string(protoScalar(t, kv, 1).data)
+		value := decodeProto(t, protoScalar(t, kv, 2).data)
+		if len(value) != 1 {
+			t.Fatalf("attribute %s has %d values", key, len(value))
+		}
+		switch v := value[0]; v.number {
+		case 1:
+			attributes[key] = string(v.data)
+		case 2:
+			attributes[key] = v.value == 1
+		case 3:
+			attributes[key] = int64(v.value)
+		case 4:
+			attributes[key] = math.Float64frombits(v.value)
+		}
+	}
+	return attributes
+}
+
+func TestOTLPProtobufTraces(t *testing.T) {
+	server, received := collectorStandIn(t, "application/x-protobuf")
+	export(t, OTLPConfig{Endpoint: server.URL, Compression: "gzip", ServiceName: "edge-probe", DisableMetrics: true})
+
+	request := decodeProto(t, received["/v1/traces"])
+	resourceSpans := protoMessages(t, request, 1)
+	if len(resourceSpans) != 1 {
+		t.Fatalf("got %d ResourceSpans, want 1", len(resourceSpans))
+	}
+	resource := protoMessages(t, resourceSpans[0], 1)[0]
+	if got := protoAttributes(t, resource, 1)["service.name"]; got != "edge-probe" {
+		t.Errorf("service.name = %v", got)
+	}
+
+	scopeSpans := protoMessages(t, resourceSpans[0], 2)[0]
+	scope := protoMessages(t, scopeSpans, 1)[0]
+	if got := string(protoScalar(t, scope, 1).data); got != "cloud-latency-monitor" {
+		t.Errorf("scope name = %q", got)
+	}
+
+	spans := protoMessages(t, scopeSpans, 2)
+	if len(spans) != 7 {
+		t.Fatalf("got %d spans, want 7", len(spans))
+	}
+	root := spans[0]
+	if got := string(protoScalar(t, root, 5).data); got != "health_check_cycle" {
+		t.Errorf("root name = %q", got)
+	}
+	if got := protoScalar(t, root, 1).data; len(got) != 16 {
+		t.Errorf("trace_id is %d bytes, want 16", len(got))
+	}
+	if got := protoScalar(t, root, 7).value; got != uint64(otlpTestStart.UnixNano()) {
+		t.Errorf("start_time_unix_nano = %d", got)
+	}
+
+	web := spans[1]
+	if got := string(protoScalar(t, web, 5).data); got != "probe http" {
+		t.Errorf("span name = %q", got)
+	}
+	if got, want := protoScalar(t, web, 4).data, protoScalar(t, root, 2).data; string(got) != string(want) {
+		t.Errorf("HTTP span parent %x, want the root span %x", got, want)
+	}
+	if got := protoScalar(t, web, 6).value; got != otlpSpanKindClient {
+		t.Errorf("span kind = %d", got)
+	}
+	attributes := protoAttributes(t, web, 9)
+	for key, want := range map[string]interface{}{
+		"endpoint.region":       "ap-northeast-1",
+		"test.online":           true,
+		"http.status_code":      int64(200),
+		"test.response_time_ms": 120.0,
+	} {
+		if attributes[key] != want {
+			t.Errorf("HTTP span %s = %v, want %v", key, attributes[key], want)
+		}
+	}
+
+	status := decodeProto(t, protoScalar(t, spans[6], 15).data)
+	if got := protoScalar(t, status, 3).value; got != otlpStatusError {
+		t.Errorf("ping status code = %d", got)
+	}
+	if got := string(protoScalar(t, status, 2).data); got != "100% packet loss" {
+		t.Errorf("ping status message = %q", got)
+	}
+}
+
+func TestOTLPProtobufMetrics(t *testing.T) {
+	server, received := collectorStandIn(t, "application/x-protobuf")
+	export(t, OTLPConfig{Endpoint: server.URL, DisableTraces: true})
+
+	request := decodeProto(t, received["/v1/metrics"])
+	resourceMetrics := protoMessages(t, request, 1)[0]
+	scopeMetrics := protoMessages(t, resourceMetrics, 2)[0]
+
+	metrics := make(map[string][]protoField)
+	for _, metric := range protoMessages(t, scopeMetrics, 2) {
+		metrics[string(protoScalar(t, metric, 1).data)] = metric
+	}
+
+	up := protoMessages(t, protoMessages(t, metrics["cloud_latency.up"], 5)[0], 1)
+	if len(up) != 2 {
+		t.Fatalf("got %d up points, want 2", len(up))
+	}
+	for i, want := range []float64{1, 0} {
+		// as_double is written even when it is zero
+		if got := math.Float64frombits(protoScalar(t, up[i], 4).value); got != want {
+			t.Errorf("up point %d = %v, want %v", i, got, want)
+		}
+		if got := protoScalar(t, up[i], 3).value; got != uint64(otlpTestStart.UnixNano()) {
+			t.Errorf("up point %d time = %d", i, got)
+		}
+	}
+	if got := protoAttributes(t, up[1], 7)["test.type"]; got != "PING" {
+		t.Errorf("up point test.type = %v, want PING", got)
+	}
+
+	sum := protoMessages(t, metrics["cloud_latency.check.errors"], 7)
+	if len(sum) != 1 {
+		t.Fatal("check.errors is not a sum")
+	}
+	if got := protoScalar(t, sum[0], 2).value; got != otlpTemporalityCumulative {
+		t.Errorf("aggregation temporality = %d", got)
+	}
+	if got := protoScalar(t, sum[0], 3).value; got != 1 {
+		t.Error("check.errors is not monotonic")
+	}
+	point := protoMessages(t, sum[0], 1)[0]
+	if got := protoAttributes(t, point, 7)["error.category"]; got != ErrorCategoryNoReply {
+		t.Errorf("error.category = %v", got)
+	}
+}
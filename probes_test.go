@@ -0,0 +1,207 @@
+package main
+
+// Probe configuration and plugin output tests:
+//
+//	go test main.go probes_test.go
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// probeKeys validates one endpoint with the given probes and returns the
+// service key of each probe that was kept
+func probeKeys(t *testing.T, probes ...ProbeSpec) []string {
+	t.Helper()
+	endpoint := CloudEndpoint{Location: "Office VPN", Provider: "Internal", Hostname: "vpn.example.com", Probes: probes}
+	var keys []string
+	for _, validated := range validateEndpoints([]CloudEndpoint{endpoint}) {
+		for _, spec := range validated.Probes {
+			keys = append(keys, serviceKeyFor(validated, spec.Type, spec.Target))
+		}
+	}
+	return keys
+}
+
+func TestValidateEndpointsKeepsFirstProbeKey(t *testing.T) {
+	tcp := func(port string) ProbeSpec {
+		return ProbeSpec{Type: "tcp", Params: map[string]string{"port": port}}
+	}
+
+	tests := []struct {
+		name   string
+		probes []ProbeSpec
+		want   []string
+	}{
+		{"single probe", []ProbeSpec{tcp("443")}, []string{"Office VPN [Internal] - TCP"}},
+		{"second probe added", []ProbeSpec{tcp("443"), tcp("1194")}, []string{"Office VPN [Internal] - TCP", "Office VPN [Internal] - TCP:1194"}},
+		{"third probe added", []ProbeSpec{tcp("443"), tcp("1194"), tcp("8443")},
+			[]string{"Office VPN [Internal] - TCP", "Office VPN [Internal] - TCP:1194", "Office VPN [Internal] - TCP:8443"}},
+		{"explicit target", []ProbeSpec{tcp("443"), {Type: "TCP", Target: "admin", Params: map[string]string{"port": "8443"}}},
+			[]string{"Office VPN [Internal] - TCP", "Office VPN [Internal] - TCP:admin"}},
+		{"duplicate target", []ProbeSpec{tcp("443"), tcp("1194"), tcp("1194")}, []string{"Office VPN [Internal] - TCP", "Office VPN [Internal] - TCP:1194"}},
+	}
+	for _, test := range tests {
+		got := probeKeys(t, test.probes...)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: keys = %q, want %q", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: keys = %q, want %q", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDiagnoseEndpointUsesFailingHTTPProbe(t *testing.T) {
+	endpoint := CloudEndpoint{Location: "Office VPN", Provider: "Internal", Hostname: "vpn.example.com"}
+	results := []TestResult{
+		{Endpoint: endpoint, TestType: TestTypeDNS, Online: true},
+		{Endpoint: endpoint, TestType: TestTypeHTTP, Online: false, Error: "context deadline exceeded",
+			Phases: []ProbePhase{{Name: "dns"}, {Name: "connect"}, {Name: "tls"}, {Name: "ttfb", Error: "context deadline exceeded"}}},
+		{Endpoint: endpoint, TestType: TestTypeHTTP, Target: "https://vpn.example.com/health", Online: true, StatusCode: 200},
+	}
+
+	diagnosis := diagnoseEndpoint(endpoint, results, CycleNormal)
+	if diagnosis.Verdict != DiagnosisService || !strings.HasPrefix(diagnosis.Reason, "service not responding") {
+		t.Errorf("verdict = %s (%s), want %s from the failing HTTP probe", diagnosis.Verdict, diagnosis.Reason, DiagnosisService)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"-H {hostname}  -p 443", []string{"-H", "{hostname}", "-p", "443"}},
+		{`-s "GET / HTTP/1.0" -e '200 OK'`, []string{"-s", "GET / HTTP/1.0", "-e", "200 OK"}},
+		{`'it''s'`, []string{"its"}},
+		{`-x ""`, []string{"-x", ""}},
+	}
+	for _, test := range tests {
+		got := splitArgs(test.line)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") || len(got) != len(test.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParsePluginOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		text   string
+		want   []perfValue
+	}{
+		{"no perfdata", "OK - all good\n", "OK - all good", nil},
+		{"units", "OK - 3 rows | time=0.052s;1;2;0; rows=3 size=512KB used=85%",
+			"OK - 3 rows", []perfValue{{"time", 0.052, "s"}, {"rows", 3, ""}, {"size", 512, "KB"}, {"used", 85, "%"}}},
+		{"quoted labels", "WARNING - slow | 'query time'=120ms;100;200 \"db size\"=1.5e3MB",
+			"WARNING - slow", []perfValue{{"query time", 120, "ms"}, {"db size", 1500, "MB"}}},
+		{"long text perfdata", "OK - replication\nlag is low | lag=2s\nseconds behind | behind=0",
+			"OK - replication", []perfValue{{"lag", 2, "s"}, {"behind", 0, ""}}},
+		{"unparsable values", "OK | a=U b= =3 c=12", "OK", []perfValue{{"c", 12, ""}}},
+	}
+	for _, test := range tests {
+		text, values := parsePluginOutput(test.output)
+		if text != test.text {
+			t.Errorf("%s: text = %q, want %q", test.name, text, test.text)
+		}
+		if len(values) != len(test.want) {
+			t.Errorf("%s: perfdata = %v, want %v", test.name, values, test.want)
+			continue
+		}
+		for i := range values {
+			if values[i] != test.want[i] {
+				t.Errorf("%s: perfdata = %v, want %v", test.name, values, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPerfFieldName(t *testing.T) {
+	tests := map[string]string{
+		"time":        "perf_time",
+		"Query Time":  "perf_query_time",
+		"/var/log":    "perf_var_log",
+		"up":          "perf_up",
+		"status_code": "perf_status_code",
+		"%%":          "",
+	}
+	for label, want := range tests {
+		if got := perfFieldName(label); got != want {
+			t.Errorf("perfFieldName(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+// runScript runs a shell script through the EXEC probe
+func runScript(t *testing.T, script string, params map[string]string) ProbeOutcome {
+	t.Helper()
+	all := map[string]string{"command": "/bin/sh", "args": "-c '" + script + "'"}
+	for key, value := range params {
+		all[key] = value
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	endpoint := CloudEndpoint{Location: "Office VPN", Provider: "Internal", Hostname: "vpn.example.com"}
+	return execProber{}.Probe(ctx, endpoint, all)
+}
+
+func TestExecProbeExitCodes(t *testing.T) {
+	tests := []struct {
+		script   string
+		online   bool
+		degraded bool
+		error    string
+	}{
+		{"echo OK - fine; exit 0", true, false, ""},
+		{"echo WARNING - slow; exit 1", true, true, ""},
+		{"echo CRITICAL - down; exit 2", false, false, "CRITICAL - down"},
+		{"echo UNKNOWN - no data; exit 3", false, false, "UNKNOWN - no data (exit 3)"},
+		{"exit 2", false, false, "CRITICAL"},
+		{"exit 3", false, false, "UNKNOWN (exit 3)"},
+	}
+	for _, test := range tests {
+		outcome := runScript(t, test.script, nil)
+		if outcome.Online != test.online || outcome.Degraded != test.degraded || outcome.Error != test.error {
+			t.Errorf("%q: online=%v degraded=%v error=%q, want online=%v degraded=%v error=%q", test.script,
+				outcome.Online, outcome.Degraded, outcome.Error, test.online, test.degraded, test.error)
+		}
+	}
+}
+
+func TestExecProbeLatency(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		params map[string]string
+		want   time.Duration
+		fields map[string]float64
+	}{
+		{"first time value", "echo \"OK | rows=3 connect=0.02s total=120ms\"", nil, 20 * time.Millisecond,
+			map[string]float64{"perf_rows": 3, "perf_connect": 20, "perf_total": 120, "exit_code": 0}},
+		{"latency override", "echo \"OK | connect=0.02s total=120ms\"", map[string]string{"latency": "total"}, 120 * time.Millisecond, nil},
+		{"JSON output", `echo "{\"message\": \"OK\", \"latency_ms\": 42, \"metrics\": {\"Queue Depth\": 7}}"`, nil, 42 * time.Millisecond,
+			map[string]float64{"perf_queue_depth": 7, "exit_code": 0}},
+		{"JSON latency override", `echo "{\"latency_ms\": 42, \"metrics\": {\"p99\": 80}}"`, map[string]string{"latency": "p99"}, 80 * time.Millisecond, nil},
+	}
+	for _, test := range tests {
+		outcome := runScript(t, test.script, test.params)
+		if !outcome.Online || outcome.ResponseTime != test.want {
+			t.Errorf("%s: online=%v response time %s, want %s (%s)", test.name, outcome.Online, outcome.ResponseTime, test.want, outcome.Error)
+		}
+		for name, want := range test.fields {
+			if got, ok := outcome.Fields[name]; !ok || got != want {
+				t.Errorf("%s: field %s = %v, want %v (fields %v)", test.name, name, got, want, outcome.Fields)
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+// Log rotation tests:
+//
+//	go test main.go rotation_test.go
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backupNames lists the rotated files next to path
+func backupNames(t *testing.T, path string) []string {
+	t.Helper()
+	ext := filepath.Ext(path)
+	matches, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, match := range matches {
+		names = append(names, filepath.Base(match))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readBackup returns the contents of a rotated file, gunzipping it if needed
+func readBackup(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return data
+}
+
+func TestPruneBackupsOrdersByStampAndIndex(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"cloud_latency-20260101-120000.log",
+		"cloud_latency-20260101-120000.1.log",
+		"cloud_latency-20260101-120000.2.log.gz",
+		"cloud_latency-20260101-120000.10.log",
+		"cloud_latency-20251231-235959.log.gz",
+		"cloud_latency-notes.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rl := &RotatingLog{maxBackups: 2}
+	rl.pruneBackups(filepath.Join(dir, "cloud_latency"), ".log")
+
+	want := []string{"cloud_latency-20260101-120000.10.log", "cloud_latency-20260101-120000.2.log.gz", "cloud_latency-notes.log"}
+	if got := backupNames(t, filepath.Join(dir, "cloud_latency.log")); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("kept %q, want %q", got, want)
+	}
+}
+
+func TestRotatingLogRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cloud_latency.log")
+	rl, err := OpenRotatingLog(LogConfig{File: path, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+
+	// Each chunk fills most of the file, so every write after the first rotates;
+	// the rotations may fall within the same second
+	chunk := 700 * 1024
+	for i := 0; i < 4; i++ {
+		if _, err := rl.Write(bytes.Repeat([]byte{byte('a' + i)}, chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups := backupNames(t, path)
+	if len(backups) != 2 {
+		t.Fatalf("backups = %q, want the newest 2", backups)
+	}
+	var kept []byte
+	for _, name := range backups {
+		kept = append(kept, readBackup(t, filepath.Join(filepath.Dir(path), name))[0])
+	}
+	if string(kept) != "bc" {
+		t.Errorf("backups hold chunks %q, want the 2nd and 3rd (\"bc\")", kept)
+	}
+	if current, _ := os.ReadFile(path); len(current) != chunk || current[0] != 'd' {
+		t.Errorf("current file holds %d bytes starting %q, want the last chunk", len(current), current[:1])
+	}
+}
+
+func TestRotatingLogRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cloud_latency.log")
+	old := time.Now().Add(-26 * time.Hour).Format("2006-01-02 15:04:05")
+	line := old + " | [UP] Frankfurt, DE [AWS]                 | Test: DNS | Response: 56ms | Trend: BASELINE\n"
+	if err := os.WriteFile(path, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rl, err := OpenRotatingLog(LogConfig{File: path, MaxAge: Duration(24 * time.Hour), Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+	if _, err := rl.Write([]byte("fresh\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rl.Write([]byte("fresher\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := backupNames(t, path)
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("backups = %q, want one gzipped backup", backups)
+	}
+	if got := string(readBackup(t, filepath.Join(filepath.Dir(path), backups[0]))); got != line {
+		t.Errorf("backup = %q, want the old line", got)
+	}
+	if current, _ := os.ReadFile(path); string(current) != "fresh\nfresher\n" {
+		t.Errorf("current file = %q, want the new lines only", current)
+	}
+}
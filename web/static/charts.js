@@ -0,0 +1,370 @@
+// charts.js - a small canvas chart library for the dashboard, so it needs no
+// CDN. Supports bar, horizontal bar ("hbar"), doughnut and line charts with
+// hover tooltips. Line charts use a linear x axis (usually epoch milliseconds).
+(function (global) {
+    'use strict';
+
+    const FONT = '12px -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif';
+    const GRID = '#e0e0e0';
+    const TEXT = '#666';
+
+    // niceStep rounds a raw tick interval to 1, 2 or 5 times a power of ten
+    function niceStep(raw) {
+        if (raw <= 0) return 1;
+        const magnitude = Math.pow(10, Math.floor(Math.log10(raw)));
+        const residual = raw / magnitude;
+        if (residual > 5) return 10 * magnitude;
+        if (residual > 2) return 5 * magnitude;
+        if (residual > 1) return 2 * magnitude;
+        return magnitude;
+    }
+
+    // valueTicks returns evenly spaced ticks from 0 covering max
+    function valueTicks(max, count) {
+        const step = niceStep((max || 1) / count);
+        const ticks = [];
+        for (let v = 0; v <= max + step / 2; v += step) ticks.push(v);
+        if (ticks.length < 2) ticks.push(step);
+        return ticks;
+    }
+
+    function formatNumber(v) {
+        return Math.abs(v) >= 100 ? Math.round(v).toString() : (Math.round(v * 10) / 10).toString();
+    }
+
+    function MiniChart(canvas, config) {
+        this.canvas = canvas;
+        this.config = config;
+        this.hover = null;
+        this.plot = null;
+
+        this._onMove = e => { this.hover = { x: e.offsetX, y: e.offsetY }; this.draw(); };
+        this._onLeave = () => { this.hover = null; this.draw(); };
+        this._onResize = () => this.draw();
+        canvas.addEventListener('mousemove', this._onMove);
+        canvas.addEventListener('mouseleave', this._onLeave);
+        global.addEventListener('resize', this._onResize);
+        this.draw();
+    }
+
+    MiniChart.prototype.destroy = function () {
+        this.canvas.removeEventListener('mousemove', this._onMove);
+        this.canvas.removeEventListener('mouseleave', this._onLeave);
+        global.removeEventListener('resize', this._onResize);
+        const ctx = this.canvas.getContext('2d');
+        ctx.setTransform(1, 0, 0, 1, 0, 0);
+        ctx.clearRect(0, 0, this.canvas.width, this.canvas.height);
+    };
+
+    // update redraws after the caller changed config.datasets, labels or axes
+    MiniChart.prototype.update = function () {
+        this.draw();
+    };
+
+    // valueForPixel converts a CSS pixel offset into an x value (line charts only)
+    MiniChart.prototype.valueForPixel = function (px) {
+        const p = this.plot;
+        if (!p) return null;
+        const ratio = Math.min(1, Math.max(0, (px - p.left) / (p.right - p.left)));
+        return p.xMin + ratio * (p.xMax - p.xMin);
+    };
+
+    MiniChart.prototype.draw = function () {
+        const canvas = this.canvas;
+        const width = canvas.parentElement.clientWidth || 600;
+        const height = this.config.height || 300;
+        const dpr = global.devicePixelRatio || 1;
+        if (canvas.width !== Math.round(width * dpr) || canvas.height !== Math.round(height * dpr)) {
+            canvas.width = Math.round(width * dpr);
+            canvas.height = Math.round(height * dpr);
+            canvas.style.width = width + 'px';
+            canvas.style.height = height + 'px';
+        }
+        const ctx = canvas.getContext('2d');
+        ctx.setTransform(dpr, 0, 0, dpr, 0, 0);
+        ctx.clearRect(0, 0, width, height);
+        ctx.font = FONT;
+        ctx.textBaseline = 'middle';
+
+        switch (this.config.type) {
+            case 'bar': this.drawBars(ctx, width, height, false); break;
+            case 'hbar': this.drawBars(ctx, width, height, true); break;
+            case 'doughnut': this.drawDoughnut(ctx, width, height); break;
+            default: this.drawLine(ctx, width, height);
+        }
+    };
+
+    MiniChart.prototype.drawTooltip = function (ctx, x, y, lines, width) {
+        if (!lines || lines.length === 0) return;
+        ctx.font = FONT;
+        const boxWidth = Math.max.apply(null, lines.map(l => ctx.measureText(l).width)) + 16;
+        const boxHeight = lines.length * 16 + 10;
+        let left = x + 12;
+        if (left + boxWidth > width) left = x - boxWidth - 12;
+        const top = Math.max(0, y - boxHeight / 2);
+        ctx.fillStyle = 'rgba(0, 0, 0, 0.8)';
+        ctx.fillRect(left, top, boxWidth, boxHeight);
+        ctx.fillStyle = 'white';
+        ctx.textAlign = 'left';
+        lines.forEach((line, i) => ctx.fillText(line, left + 8, top + 13 + i * 16));
+    };
+
+    MiniChart.prototype.drawBars = function (ctx, width, height, horizontal) {
+        const labels = this.config.labels || [];
+        const dataset = this.config.datasets[0] || { data: [] };
+        const values = dataset.data;
+        const max = Math.max.apply(null, values.concat([0]));
+        const ticks = valueTicks(max, 5);
+        const top = ticks[ticks.length - 1];
+
+        let left, right, bottom, plotTop = 10;
+        if (horizontal) {
+            left = Math.max.apply(null, labels.map(l => ctx.measureText(l).width).concat([0])) + 16;
+            right = width - 20;
+            bottom = height - 24;
+        } else {
+            left = ctx.measureText(formatNumber(top)).width + 16;
+            right = width - 10;
+            const longest = Math.max.apply(null, labels.map(l => ctx.measureText(l).width).concat([0]));
+            bottom = height - Math.min(120, longest * 0.71 + 16);
+        }
+
+        // Value grid
+        ctx.strokeStyle = GRID;
+        ctx.fillStyle = TEXT;
+        ticks.forEach(t => {
+            ctx.beginPath();
+            if (horizontal) {
+                const x = left + (t / top) * (right - left);
+                ctx.moveTo(x, plotTop);
+                ctx.lineTo(x, bottom);
+                ctx.textAlign = 'center';
+                ctx.fillText(formatNumber(t), x, bottom + 12);
+            } else {
+                const y = bottom - (t / top) * (bottom - plotTop);
+                ctx.moveTo(left, y);
+                ctx.lineTo(right, y);
+                ctx.textAlign = 'right';
+                ctx.fillText(formatNumber(t), left - 6, y);
+            }
+            ctx.stroke();
+        });
+
+        const band = ((horizontal ? bottom - plotTop : right - left) / Math.max(1, labels.length));
+        let hovered = -1;
+        labels.forEach((label, i) => {
+            const value = values[i] || 0;
+            ctx.fillStyle = (dataset.colors && dataset.colors[i]) || dataset.color || 'rgba(102, 126, 234, 0.8)';
+            let x, y, w, h;
+            if (horizontal) {
+                y = plotTop + i * band + band * 0.15;
+                h = band * 0.7;
+                x = left;
+                w = (value / top) * (right - left);
+                ctx.fillRect(x, y, w, h);
+                ctx.fillStyle = TEXT;
+                ctx.textAlign = 'right';
+                ctx.fillText(label, left - 8, y + h / 2);
+            } else {
+                x = left + i * band + band * 0.15;
+                w = band * 0.7;
+                h = (value / top) * (bottom - plotTop);
+                y = bottom - h;
+                ctx.fillRect(x, y, w, h);
+                ctx.save();
+                ctx.translate(x + w / 2, bottom + 8);
+                ctx.rotate(-Math.PI / 4);
+                ctx.fillStyle = TEXT;
+                ctx.textAlign = 'right';
+                ctx.fillText(label, 0, 0);
+                ctx.restore();
+            }
+            const hv = this.hover;
+            if (hv && (horizontal ? hv.y >= plotTop + i * band && hv.y < plotTop + (i + 1) * band
+                                  : hv.x >= left + i * band && hv.x < left + (i + 1) * band)) {
+                hovered = i;
+            }
+        });
+
+        if (hovered >= 0) {
+            const unit = this.config.unit || '';
+            this.drawTooltip(ctx, this.hover.x, this.hover.y,
+                [labels[hovered] + ': ' + formatNumber(values[hovered] || 0) + unit], width);
+        }
+    };
+
+    MiniChart.prototype.drawDoughnut = function (ctx, width, height) {
+        const labels = this.config.labels || [];
+        const dataset = this.config.datasets[0] || { data: [] };
+        const values = dataset.data;
+        const total = values.reduce((a, b) => a + (b || 0), 0);
+        const legendHeight = 30;
+        const cx = width / 2;
+        const cy = (height - legendHeight) / 2;
+        const radius = Math.max(10, Math.min(cx, cy) - 10);
+
+        let angle = -Math.PI / 2;
+        let hovered = -1;
+        const hv = this.hover;
+        values.forEach((value, i) => {
+            const sweep = total > 0 ? (value / total) * 2 * Math.PI : 0;
+            ctx.beginPath();
+            ctx.moveTo(cx, cy);
+            ctx.arc(cx, cy, radius, angle, angle + sweep);
+            ctx.closePath();
+            ctx.fillStyle = dataset.colors[i % dataset.colors.length];
+            ctx.fill();
+            if (hv) {
+                const dx = hv.x - cx, dy = hv.y - cy;
+                const dist = Math.sqrt(dx * dx + dy * dy);
+                let a = Math.atan2(dy, dx);
+                if (a < -Math.PI / 2) a += 2 * Math.PI;
+                if (dist <= radius && dist >= radius * 0.5 && a >= angle && a < angle + sweep) hovered = i;
+            }
+            angle += sweep;
+        });
+        ctx.beginPath();
+        ctx.arc(cx, cy, radius * 0.5, 0, 2 * Math.PI);
+        ctx.fillStyle = 'white';
+        ctx.fill();
+
+        // Legend
+        const items = labels.map(l => ctx.measureText(l).width + 26);
+        let x = cx - items.reduce((a, b) => a + b, 0) / 2;
+        labels.forEach((label, i) => {
+            ctx.fillStyle = dataset.colors[i % dataset.colors.length];
+            ctx.fillRect(x, height - legendHeight / 2 - 6, 12, 12);
+            ctx.fillStyle = TEXT;
+            ctx.textAlign = 'left';
+            ctx.fillText(label, x + 16, height - legendHeight / 2);
+            x += items[i];
+        });
+
+        if (hovered >= 0) {
+            this.drawTooltip(ctx, hv.x, hv.y, [labels[hovered] + ': ' + formatNumber(values[hovered]) + (this.config.unit || '')], width);
+        }
+    };
+
+    MiniChart.prototype.drawLine = function (ctx, width, height) {
+        const config = this.config;
+        const datasets = config.datasets;
+        const xAxis = config.x || {};
+        const yAxis = config.y || {};
+
+        let xMin = xAxis.min, xMax = xAxis.max, yMax = 0;
+        datasets.forEach(d => d.data.forEach(p => {
+            if (xMin === undefined || p.x < xMin) xMin = p.x;
+            if (xMax === undefined || p.x > xMax) xMax = p.x;
+            if (p.y !== null && p.y > yMax) yMax = p.y;
+        }));
+        if (xMin === undefined) { xMin = 0; xMax = 1; }
+        if (xMax === xMin) xMax = xMin + 1;
+
+        const ticks = valueTicks(yMax, 5);
+        const yTop = ticks[ticks.length - 1];
+        const legendHeight = config.legend ? 24 : 0;
+        const left = ctx.measureText(formatNumber(yTop)).width + (yAxis.title ? 30 : 14);
+        const right = width - 12;
+        const top = 10 + legendHeight;
+        const bottom = height - 26;
+        this.plot = { left, right, top, bottom, xMin, xMax };
+
+        const px = x => left + ((x - xMin) / (xMax - xMin)) * (right - left);
+        const py = y => bottom - (y / yTop) * (bottom - top);
+
+        // Grid and axes
+        ctx.strokeStyle = GRID;
+        ctx.fillStyle = TEXT;
+        ctx.textAlign = 'right';
+        ticks.forEach(t => {
+            ctx.beginPath();
+            ctx.moveTo(left, py(t));
+            ctx.lineTo(right, py(t));
+            ctx.stroke();
+            ctx.fillText(formatNumber(t), left - 6, py(t));
+        });
+        if (yAxis.title) {
+            ctx.save();
+            ctx.translate(10, (top + bottom) / 2);
+            ctx.rotate(-Math.PI / 2);
+            ctx.textAlign = 'center';
+            ctx.fillText(yAxis.title, 0, 0);
+            ctx.restore();
+        }
+        const xTicks = xAxis.ticks || 6;
+        ctx.textAlign = 'center';
+        for (let i = 0; i <= xTicks; i++) {
+            const value = xMin + (i / xTicks) * (xMax - xMin);
+            const label = xAxis.format ? xAxis.format(value) : formatNumber(value);
+            ctx.fillText(label, Math.min(right - 20, Math.max(left + 20, px(value))), bottom + 14);
+        }
+
+        // Series: lines break at null values
+        ctx.save();
+        ctx.beginPath();
+        ctx.rect(left, top - 5, right - left, bottom - top + 10);
+        ctx.clip();
+        datasets.forEach(d => {
+            ctx.strokeStyle = d.color;
+            ctx.lineWidth = 2;
+            ctx.beginPath();
+            let drawing = false;
+            d.data.forEach(p => {
+                if (p.y === null) { drawing = false; return; }
+                if (drawing) ctx.lineTo(px(p.x), py(p.y));
+                else ctx.moveTo(px(p.x), py(p.y));
+                drawing = true;
+            });
+            ctx.stroke();
+            if (config.point) {
+                d.data.forEach(p => {
+                    const style = config.point(p, d);
+                    if (!style || !style.radius) return;
+                    ctx.fillStyle = style.color || d.color;
+                    ctx.beginPath();
+                    ctx.arc(px(p.x), p.y === null ? bottom : py(p.y), style.radius, 0, 2 * Math.PI);
+                    ctx.fill();
+                });
+            }
+        });
+        ctx.restore();
+        ctx.lineWidth = 1;
+
+        // Legend
+        if (config.legend) {
+            let x = left;
+            datasets.forEach(d => {
+                ctx.fillStyle = d.color;
+                ctx.fillRect(x, 6, 12, 12);
+                ctx.fillStyle = TEXT;
+                ctx.textAlign = 'left';
+                ctx.fillText(d.label, x + 16, 12);
+                x += ctx.measureText(d.label).width + 32;
+            });
+        }
+
+        // Tooltip for the points nearest the cursor
+        const hv = this.hover;
+        if (!hv || hv.x < left || hv.x > right) return;
+        const target = this.valueForPixel(hv.x);
+        const lines = [];
+        let nearestX = null;
+        datasets.forEach(d => {
+            let best = null;
+            d.data.forEach(p => { if (best === null || Math.abs(p.x - target) < Math.abs(best.x - target)) best = p; });
+            if (best === null) return;
+            if (nearestX === null) nearestX = best.x;
+            lines.push(config.tooltip ? config.tooltip(best, d) : d.label + ': ' + (best.y === null ? '-' : formatNumber(best.y)));
+        });
+        if (nearestX === null) return;
+        ctx.strokeStyle = 'rgba(0, 0, 0, 0.3)';
+        ctx.beginPath();
+        ctx.moveTo(px(nearestX), top);
+        ctx.lineTo(px(nearestX), bottom);
+        ctx.stroke();
+        lines.unshift(xAxis.tooltip ? xAxis.tooltip(nearestX) : formatNumber(nearestX));
+        this.drawTooltip(ctx, px(nearestX), hv.y, lines, width);
+    };
+
+    global.MiniChart = MiniChart;
+})(window);
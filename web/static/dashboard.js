@@ -0,0 +1,132 @@
+// dashboard.js - charts and live updates for the main dashboard page.
+// Chart data is computed by the server and embedded as JSON in #chart-data.
+(function () {
+    'use strict';
+
+    const charts = JSON.parse(document.getElementById('chart-data').textContent);
+    const labels = bars => bars.map(b => b.Label);
+    const values = bars => bars.map(b => Math.round(b.Value));
+
+    new MiniChart(document.getElementById('regionChart'), {
+        type: 'bar',
+        unit: 'ms',
+        labels: labels(charts.Region),
+        datasets: [{ data: values(charts.Region), color: 'rgba(102, 126, 234, 0.8)' }],
+    });
+
+    new MiniChart(document.getElementById('testTypeChart'), {
+        type: 'doughnut',
+        unit: 'ms',
+        labels: labels(charts.Layers),
+        datasets: [{ data: values(charts.Layers), colors: ['rgba(33,150,243,0.8)', 'rgba(76,175,80,0.8)', 'rgba(255,152,0,0.8)'] }],
+    });
+
+    let zoomed = false;
+    const onZoom = () => { zoomed = true; };
+
+    const liveCharts = [
+        seriesChart(document.getElementById('series-endpoint'), value => [value], { api: 'api/series', onZoom }),
+        seriesChart(document.getElementById('series-layers'), value => ['PING', 'DNS', 'HTTP'].map(layer => value + ' - ' + layer), { api: 'api/series', onZoom }),
+    ];
+
+    function formatAge(ms) {
+        const s = Math.max(0, Math.round(ms / 1000));
+        if (s < 60) return s + 's ago';
+        if (s < 3600) return Math.floor(s / 60) + 'm ago';
+        if (s < 86400) return Math.floor(s / 3600) + 'h ago';
+        return Math.floor(s / 86400) + 'd ago';
+    }
+
+    // Ages tick every second; anything not heard from in two minutes is stale
+    function updateAges() {
+        const now = Date.now();
+        document.querySelectorAll('[data-seen]').forEach(cell => {
+            const seen = Date.parse(cell.dataset.seen);
+            if (isNaN(seen)) return;
+            cell.textContent = formatAge(now - seen);
+            cell.classList.toggle('stale', now - seen > 120e3);
+        });
+    }
+    updateAges();
+    setInterval(updateAges, 1000);
+
+    function rowFor(service) {
+        return document.querySelector('tr[data-service="' + CSS.escape(service) + '"]');
+    }
+
+    // A map marker turns dark while any of its layers is down
+    function updateMarker(entry) {
+        const split = entry.service.lastIndexOf(' - ');
+        if (split < 0) return;
+        const marker = document.querySelector('.map-marker[data-endpoint="' + CSS.escape(entry.service.slice(0, split)) + '"]');
+        if (!marker) return;
+        const down = new Set(marker.dataset.down ? marker.dataset.down.split(',') : []);
+        const layer = entry.service.slice(split + 3);
+        if (entry.online) down.delete(layer); else down.add(layer);
+        marker.dataset.down = [...down].join(',');
+        marker.classList.toggle('marker-down', down.size > 0);
+        marker.classList.toggle('marker-up', down.size === 0 && !marker.classList.contains('marker-degraded'));
+    }
+
+    function applyResult(entry) {
+        const row = rowFor(entry.service);
+        if (row) {
+            row.querySelector('.latest').textContent = entry.online ? Math.round(entry.response_ms) : 'DOWN';
+            row.querySelector('.seen').dataset.seen = entry.time;
+            row.classList.toggle('row-down', !entry.online);
+            row.classList.toggle('row-degraded', !!entry.degraded);
+            const health = row.querySelector('.health');
+            const state = !entry.online ? 'DOWN' : entry.degraded ? 'DEGRADED' : 'UP';
+            health.textContent = state;
+            health.className = 'status-badge health layer-' + state.toLowerCase();
+            row.classList.add('flash');
+            requestAnimationFrame(() => requestAnimationFrame(() => row.classList.remove('flash')));
+        }
+        updateMarker(entry);
+        liveCharts.forEach(onResult => onResult(entry));
+    }
+
+    function applyCycle(cycle) {
+        (cycle.diagnoses || []).forEach(d => {
+            document.querySelectorAll('tr[data-service^="' + CSS.escape(d.location + ' [' + d.provider + '] - ') + '"]').forEach(row => {
+                const cell = row.querySelector('.diagnosis');
+                cell.textContent = '';
+                const badge = document.createElement('span');
+                badge.className = 'verdict verdict-' + d.verdict.toLowerCase();
+                badge.textContent = d.verdict;
+                badge.title = d.reason;
+                cell.appendChild(badge);
+            });
+        });
+    }
+
+    // Filters apply as soon as a value is picked; the form still works
+    // without JavaScript through its submit button
+    const filters = document.querySelector('form.filters');
+    filters.querySelectorAll('select').forEach(select => select.addEventListener('change', () => filters.requestSubmit()));
+    // Leave unset filters out of the URL so shared links stay short
+    filters.addEventListener('submit', () => {
+        filters.querySelectorAll('input, select').forEach(field => { field.disabled = !field.value; });
+    });
+
+    if (document.body.dataset.live === 'true') {
+        const indicator = document.getElementById('live-indicator');
+        const events = new EventSource('api/events');
+        events.addEventListener('result', e => applyResult(JSON.parse(e.data)));
+        events.addEventListener('cycle', e => applyCycle(JSON.parse(e.data)));
+        events.addEventListener('status', e => {
+            const connected = JSON.parse(e.data).connected;
+            indicator.classList.toggle('connected', connected);
+            indicator.textContent = connected ? '● Live' : '○ Monitor offline';
+        });
+        events.onerror = () => {
+            indicator.classList.remove('connected');
+            indicator.textContent = '○ Reconnecting…';
+        };
+    } else {
+        // A full reload would throw away a zoomed view
+        setTimeout(() => {
+            if (!zoomed) location.reload();
+        }, 30000);
+    }
+})();
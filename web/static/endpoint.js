@@ -0,0 +1,8 @@
+// endpoint.js - the time-series chart on /endpoint/{id}, overlaying every
+// layer of the endpoint
+(function () {
+    'use strict';
+
+    const services = JSON.parse(document.getElementById('endpoint-services').textContent) || [];
+    seriesChart(document.getElementById('endpoint-series'), () => services, { api: '../api/series' });
+})();
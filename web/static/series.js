@@ -0,0 +1,111 @@
+// series.js - latency-over-time charts backed by /api/series, with range
+// buttons, drag-to-zoom and live updates. Requires charts.js.
+(function (global) {
+    'use strict';
+
+    const rangeSpans = { '1h': 3600e3, '24h': 86400e3, '7d': 7 * 86400e3, '30d': 30 * 86400e3 };
+    const layerColors = { ping: '#2196f3', dns: '#4caf50', http: '#ff9800' };
+
+    function formatTick(ms, span) {
+        const d = new Date(ms);
+        if (span <= 2 * 86400e3) return d.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+        return d.toLocaleDateString([], { month: 'short', day: 'numeric' }) + ' ' + d.toLocaleTimeString([], { hour: '2-digit' });
+    }
+
+    // seriesChart wires a chart card to /api/series. services maps the
+    // selected option to the service keys to plot; options.api is the URL of
+    // /api/series relative to the page and options.onZoom is called on zoom.
+    // The returned function appends a live result to the chart.
+    global.seriesChart = function (card, services, options) {
+        const select = card.querySelector('.series-select');
+        const canvas = card.querySelector('canvas');
+        const box = card.querySelector('.zoom-box');
+        const reset = card.querySelector('.reset-zoom');
+        const active = card.querySelector('[data-range].active');
+        const state = { range: active ? active.dataset.range : '24h', from: null, to: null };
+        let chart = null;
+
+        async function load() {
+            const keys = services(select ? select.value : null);
+            if (keys.length === 0) return;
+            const to = state.to || Date.now();
+            const from = state.from || to - rangeSpans[state.range];
+            const params = new URLSearchParams();
+            keys.forEach(k => params.append('service', k));
+            params.set('from', new Date(from).toISOString());
+            params.set('to', new Date(to).toISOString());
+            const resp = await fetch(options.api + '?' + params);
+            if (!resp.ok) return;
+            const data = await resp.json();
+            const datasets = data.Series.map(s => ({
+                service: s.Service,
+                label: s.TestType.toUpperCase(),
+                color: keys.length > 1 ? (layerColors[s.TestType] || '#667eea') : '#667eea',
+                data: s.Points.map(p => ({ x: Date.parse(p.Time), y: p.Count > 0 ? Math.round(p.AvgMs * 10) / 10 : null, failures: p.Failures })),
+            }));
+            const span = to - from;
+            if (chart) chart.destroy();
+            chart = new MiniChart(canvas, {
+                type: 'line',
+                legend: keys.length > 1,
+                datasets,
+                x: { min: from, max: to, format: v => formatTick(v, span), tooltip: v => new Date(v).toLocaleString() },
+                y: { title: 'ms (avg per ' + data.Step + ')' },
+                point: p => p.failures > 0 ? { radius: 4, color: '#f44336' } : null,
+                tooltip: (p, d) => d.label + ': ' + (p.y === null ? 'no successful checks' : p.y + 'ms') +
+                    (p.failures ? ' (' + p.failures + ' failed)' : ''),
+            });
+        }
+
+        // Drag across the chart to zoom into that time range
+        let dragStart = null;
+        canvas.addEventListener('mousedown', e => { dragStart = e.offsetX; });
+        canvas.addEventListener('mousemove', e => {
+            if (dragStart === null) return;
+            box.style.display = 'block';
+            box.style.left = Math.min(dragStart, e.offsetX) + 'px';
+            box.style.width = Math.abs(e.offsetX - dragStart) + 'px';
+        });
+        canvas.addEventListener('mouseup', e => {
+            box.style.display = 'none';
+            if (dragStart === null || !chart) return;
+            const x1 = chart.valueForPixel(Math.min(dragStart, e.offsetX));
+            const x2 = chart.valueForPixel(Math.max(dragStart, e.offsetX));
+            dragStart = null;
+            if (x2 - x1 < 60e3) return;
+            state.from = x1;
+            state.to = x2;
+            if (options.onZoom) options.onZoom();
+            reset.style.display = '';
+            load();
+        });
+        canvas.addEventListener('mouseleave', () => { dragStart = null; box.style.display = 'none'; });
+
+        reset.addEventListener('click', () => {
+            state.from = state.to = null;
+            reset.style.display = 'none';
+            load();
+        });
+        card.querySelectorAll('[data-range]').forEach(button => button.addEventListener('click', () => {
+            card.querySelectorAll('[data-range]').forEach(b => b.classList.toggle('active', b === button));
+            state.range = button.dataset.range;
+            state.from = state.to = null;
+            reset.style.display = 'none';
+            load();
+        }));
+        if (select) select.addEventListener('change', load);
+        load();
+
+        // Append a live result to the matching line unless the view is zoomed into the past
+        return function onResult(entry) {
+            if (!chart || state.to !== null) return;
+            const dataset = chart.config.datasets.find(d => d.service === entry.service);
+            if (!dataset) return;
+            const x = Date.parse(entry.time);
+            dataset.data.push({ x, y: entry.online ? Math.round(entry.response_ms * 10) / 10 : null, failures: entry.online ? 0 : 1 });
+            chart.config.x.max = x;
+            chart.config.x.min = x - rangeSpans[state.range];
+            chart.update();
+        };
+    };
+})(window);